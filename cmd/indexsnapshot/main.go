@@ -0,0 +1,43 @@
+// Command indexsnapshot parses the quote scripts and writes an index
+// snapshot the server can load at startup instead of parsing them. It runs
+// from go generate in internal/quote:
+//
+//	go generate ./internal/quote
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"umineko_quote/internal/quote"
+)
+
+func main() {
+	scripts := flag.String("scripts", "data", "directory holding the scripts")
+	out := flag.String("out", "data/index.snapshot", "snapshot file to write")
+	flag.Parse()
+
+	// Write beside the target and rename, so a server never reads half a
+	// snapshot.
+	tmp, err := os.CreateTemp(filepath.Dir(*out), ".index-*.snapshot")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := quote.WriteIndexSnapshot(tmp, os.DirFS(*scripts)); err != nil {
+		log.Fatalf("building index snapshot: %v", err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		log.Fatal(err)
+	}
+	if err := tmp.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Rename(tmp.Name(), *out); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %s", *out)
+}
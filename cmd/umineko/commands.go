@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"umineko_quote/internal/lexar"
+	"umineko_quote/internal/quote"
+)
+
+// filters are the quote filters the HTTP API takes as query parameters.
+type filters struct {
+	lang      string
+	character string
+	episode   int
+	truth     string
+}
+
+func (f *filters) register(fs *flag.FlagSet, withCharacter bool) {
+	fs.StringVar(&f.lang, "lang", "en", "language code")
+	if withCharacter {
+		fs.StringVar(&f.character, "character", "", "only quotes by this character ID")
+	}
+	fs.IntVar(&f.episode, "episode", 0, "only quotes from this episode (1-8)")
+	fs.StringVar(&f.truth, "truth", "", "only quotes with red or blue truth")
+}
+
+func (f *filters) truthFilter() quote.Truth {
+	return quote.TruthAll.Parse(f.truth)
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("umineko "+name, flag.ContinueOnError)
+	format := fs.String("format", string(formatTable), "output format: table, json or text")
+	return fs, format
+}
+
+// parseFlags parses args, in which flags may follow positional arguments,
+// and returns the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errFlags
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		// Everything after -- is positional, even if it looks like a flag.
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func runSearch(args []string) error {
+	fs, format := newFlagSet("search")
+	var f filters
+	f.register(fs, true)
+	limit := fs.Int("limit", 30, "results per page")
+	offset := fs.Int("offset", 0, "results to skip")
+	mode := fs.String("mode", "", "set to fuzzy to match misspelt words")
+	regex := fs.Bool("regex", false, "treat the query as a regular expression")
+	bgm := fs.String("bgm", "", "only quotes spoken over this BGM track or title")
+	present := fs.String("present", "", "only quotes with these comma-separated character IDs on screen")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return usageErrorf("a query is required")
+	}
+	out, err := parseFormat(*format)
+	if err != nil {
+		return err
+	}
+	svc, err := loadQuotes(f.lang)
+	if err != nil {
+		return err
+	}
+
+	query := strings.Join(positional, " ")
+	var onScreen []string
+	if *present != "" {
+		onScreen = strings.Split(*present, ",")
+	}
+	var response quote.SearchResponse
+	if *regex {
+		response, err = svc.SearchRegex(query, f.lang, *limit, *offset, f.character, f.episode, f.truthFilter(), *bgm, onScreen, false)
+		if err != nil {
+			return err
+		}
+	} else {
+		response = svc.Search(query, f.lang, *limit, *offset, f.character, f.episode, f.truthFilter(), *bgm, onScreen, quote.SearchModeExact.Parse(*mode))
+	}
+
+	if out == formatJSON {
+		return writeJSON(os.Stdout, response)
+	}
+	quotes := make([]quote.ParsedQuote, len(response.Results))
+	for i, r := range response.Results {
+		quotes[i] = r.Quote
+	}
+	if err := writeQuotes(os.Stdout, out, quotes, -1); err != nil {
+		return err
+	}
+	if len(response.Suggestions) > 0 {
+		fmt.Fprintf(os.Stderr, "Did you mean: %s\n", strings.Join(response.Suggestions, ", "))
+	}
+	return writePageSummary(out, len(quotes), response.Offset, response.Total)
+}
+
+func runRandom(args []string) error {
+	fs, format := newFlagSet("random")
+	var f filters
+	f.register(fs, true)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	out, err := parseFormat(*format)
+	if err != nil {
+		return err
+	}
+	svc, err := loadQuotes(f.lang)
+	if err != nil {
+		return err
+	}
+
+	q := svc.Random(f.lang, f.character, f.episode, f.truthFilter())
+	if q == nil {
+		return errors.New("no quotes match")
+	}
+	if out == formatJSON {
+		return writeJSON(os.Stdout, q)
+	}
+	return writeQuotes(os.Stdout, out, []quote.ParsedQuote{*q}, -1)
+}
+
+func runContext(args []string) error {
+	fs, format := newFlagSet("context")
+	lang := fs.String("lang", "en", "language code")
+	lines := fs.Int("lines", 5, "quotes to show either side (at most 20)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErrorf("one audio ID or quote ID is required")
+	}
+	out, err := parseFormat(*format)
+	if err != nil {
+		return err
+	}
+	svc, err := loadQuotes(*lang)
+	if err != nil {
+		return err
+	}
+
+	result := svc.GetContext(*lang, positional[0], *lines)
+	if result == nil {
+		return fmt.Errorf("quote %s not found", positional[0])
+	}
+	if out == formatJSON {
+		return writeJSON(os.Stdout, result)
+	}
+	quotes := append(append(append([]quote.ParsedQuote{}, result.Before...), result.Quote), result.After...)
+	return writeQuotes(os.Stdout, out, quotes, len(result.Before))
+}
+
+func runCharacter(args []string) error {
+	fs, format := newFlagSet("character")
+	var f filters
+	f.register(fs, false)
+	limit := fs.Int("limit", 50, "quotes per page")
+	offset := fs.Int("offset", 0, "quotes to skip")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErrorf("one character ID is required")
+	}
+	out, err := parseFormat(*format)
+	if err != nil {
+		return err
+	}
+	svc, err := loadQuotes(f.lang)
+	if err != nil {
+		return err
+	}
+
+	response := svc.GetByCharacter(f.lang, positional[0], *limit, *offset, f.episode, f.truthFilter())
+	if out == formatJSON {
+		return writeJSON(os.Stdout, response)
+	}
+	if err := writeQuotes(os.Stdout, out, response.Quotes, -1); err != nil {
+		return err
+	}
+	return writePageSummary(out, len(response.Quotes), response.Offset, response.Total)
+}
+
+func runStats(args []string) error {
+	fs, format := newFlagSet("stats")
+	episode := fs.Int("episode", 0, "only this episode (1-8)")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	out, err := parseFormat(*format)
+	if err != nil {
+		return err
+	}
+	svc, err := loadQuotes("")
+	if err != nil {
+		return err
+	}
+
+	stats := svc.GetStats().Compute(*episode)
+	if out == formatJSON {
+		return writeJSON(os.Stdout, stats)
+	}
+	return writeStats(os.Stdout, out, stats)
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("umineko export", flag.ContinueOnError)
+	format := fs.String("format", string(quote.ExportJSONL), "output format: jsonl, csv, tsv, columnar, table, json or text")
+	var f filters
+	f.register(fs, true)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	exportFormat, exportErr := quote.ParseExportFormat(*format)
+	out, err := parseFormat(*format)
+	if exportErr != nil && err != nil {
+		return usageErrorf("unknown format %q; want jsonl, csv, tsv, columnar, table, json or text", *format)
+	}
+	svc, err := loadQuotes(f.lang)
+	if err != nil {
+		return err
+	}
+
+	response := svc.Browse(f.lang, f.character, math.MaxInt, 0, f.episode, f.truthFilter())
+	if exportErr == nil {
+		return quote.WriteExport(os.Stdout, exportFormat, response.Quotes)
+	}
+	if out == formatJSON {
+		return writeJSON(os.Stdout, response.Quotes)
+	}
+	return writeQuotes(os.Stdout, out, response.Quotes, -1)
+}
+
+// lintDiagnostic is a diagnostic as lint prints it in JSON.
+type lintDiagnostic struct {
+	File    string               `json:"file"`
+	Line    int                  `json:"line"`
+	Column  int                  `json:"column"`
+	Kind    lexar.DiagnosticKind `json:"kind"`
+	Message string               `json:"message"`
+}
+
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("umineko lint", flag.ContinueOnError)
+	format := fs.String("format", string(formatText), "output format: text or json")
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return usageErrorf("at least one script is required")
+	}
+	out := outputFormat(*format)
+	if out != formatText && out != formatJSON {
+		return usageErrorf("unknown format %q; want text or json", *format)
+	}
+
+	found := []lintDiagnostic{}
+	for _, file := range files {
+		var data []byte
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return err
+		}
+		_, diags := lexar.ParseWithDiagnostics(string(data))
+		for _, d := range diags {
+			found = append(found, lintDiagnostic{File: file, Line: d.Pos.Line, Column: d.Pos.Column, Kind: d.Kind, Message: d.Message})
+		}
+	}
+
+	if out == formatJSON {
+		if err := writeJSON(os.Stdout, found); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range found {
+			fmt.Fprintf(os.Stdout, "%s:%d:%d: %s (%s)\n", d.File, d.Line, d.Column, d.Message, d.Kind)
+		}
+	}
+	if len(found) > 0 {
+		return fmt.Errorf("%d problems found", len(found))
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"umineko_quote/internal/quote"
+)
+
+// TestMain quiets loading the scripts, as main does without -v.
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestParseFlags(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		args       []string
+		positional []string
+		limit      int
+		verbose    bool
+		err        error
+	}{
+		{name: "none", limit: 30},
+		{name: "flags first", args: []string{"-limit", "5", "gold"}, positional: []string{"gold"}, limit: 5},
+		{name: "flags after positional", args: []string{"golden", "-limit", "5", "witch", "-v"}, positional: []string{"golden", "witch"}, limit: 5, verbose: true},
+		{name: "double dash", args: []string{"-v", "--", "-limit", "5"}, positional: []string{"-limit", "5"}, limit: 30, verbose: true},
+		{name: "double dash after positional", args: []string{"gold", "--", "-v"}, positional: []string{"gold", "-v"}, limit: 30},
+		{name: "help", args: []string{"gold", "-h"}, err: flag.ErrHelp},
+		{name: "unknown flag", args: []string{"gold", "-nope"}, err: errFlags},
+		{name: "bad value", args: []string{"-limit", "many"}, err: errFlags},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		limit := fs.Int("limit", 30, "")
+		verbose := fs.Bool("v", false, "")
+
+		positional, err := parseFlags(fs, tc.args)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: got error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if tc.err != nil {
+			continue
+		}
+		if !slices.Equal(positional, tc.positional) {
+			t.Errorf("%s: got positional %q, want %q", tc.name, positional, tc.positional)
+		}
+		if *limit != tc.limit || *verbose != tc.verbose {
+			t.Errorf("%s: got -limit %d -v %t, want %d %t", tc.name, *limit, *verbose, tc.limit, tc.verbose)
+		}
+	}
+}
+
+// testScript has two English lines by Battler, one with red truth, and a
+// line by Beatrice between them.
+const testScript = "new_episode 1\n" +
+	`d [lv 0*"10"*"10100001"]"The golden witch does not exist."[\]` + "\n" +
+	`d [lv 0*"27"*"12700001"]"{p:1:The culprit is human}."[\]` + "\n" +
+	`d [lv 0*"10"*"10100002"]"Then show me the gold."[\]` + "\n"
+
+// useTestScripts points the commands at testScript.
+func useTestScripts(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"english.txt", "japanese.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(testScript), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("SCRIPT_DIR", dir)
+	t.Setenv("INDEX_SNAPSHOT", "")
+}
+
+// runJSON runs a command with -format json and decodes what it prints into
+// v. A command may print its output and still fail, as lint does.
+func runJSON(t *testing.T, run func([]string) error, v any, args ...string) error {
+	t.Helper()
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	runErr := run(append(args, "-format", "json"))
+	os.Stdout = stdout
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("output is not JSON (%v): %s", err, data)
+	}
+	return runErr
+}
+
+func quoteIDs(quotes []quote.ParsedQuote) []string {
+	ids := make([]string, len(quotes))
+	for i, q := range quotes {
+		ids[i] = q.AudioID
+	}
+	return ids
+}
+
+func TestRunSearch(t *testing.T) {
+	useTestScripts(t)
+	var response quote.SearchResponse
+	if err := runJSON(t, runSearch, &response, "gold", "-character", "10"); err != nil {
+		t.Fatal(err)
+	}
+	if response.Total != 2 || len(response.Results) != 2 {
+		t.Fatalf("got %d of %d results, want 2", len(response.Results), response.Total)
+	}
+	for _, r := range response.Results {
+		if r.Quote.CharacterID != "10" {
+			t.Errorf("got a quote by %s, want only 10", r.Quote.CharacterID)
+		}
+	}
+}
+
+func TestRunRandom(t *testing.T) {
+	useTestScripts(t)
+	var q quote.ParsedQuote
+	if err := runJSON(t, runRandom, &q, "-truth", "red"); err != nil {
+		t.Fatal(err)
+	}
+	if q.AudioID != "12700001" || !q.HasRedTruth {
+		t.Errorf("got %+v, want the red truth 12700001", q)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	useTestScripts(t)
+	var result quote.ContextResponse
+	if err := runJSON(t, runContext, &result, "12700001", "-lines", "1"); err != nil {
+		t.Fatal(err)
+	}
+	got := quoteIDs(append(append(result.Before, result.Quote), result.After...))
+	if want := []string{"10100001", "12700001", "10100002"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRunCharacter(t *testing.T) {
+	useTestScripts(t)
+	var response quote.CharacterResponse
+	if err := runJSON(t, runCharacter, &response, "10", "-limit", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if response.CharacterID != "10" || response.Total != 2 || len(response.Quotes) != 1 {
+		t.Errorf("got %s with %d of %d quotes, want 10 with 1 of 2", response.CharacterID, len(response.Quotes), response.Total)
+	}
+}
+
+func TestRunStats(t *testing.T) {
+	useTestScripts(t)
+	var stats struct {
+		TopSpeakers []struct {
+			CharacterID string `json:"characterId"`
+			Count       int    `json:"count"`
+		} `json:"topSpeakers"`
+	}
+	if err := runJSON(t, runStats, &stats, "-episode", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.TopSpeakers) != 2 || stats.TopSpeakers[0].CharacterID != "10" || stats.TopSpeakers[0].Count != 2 {
+		t.Errorf("got top speakers %+v, want 10 first with 2 lines", stats.TopSpeakers)
+	}
+}
+
+func TestRunExport(t *testing.T) {
+	useTestScripts(t)
+	var quotes []quote.ParsedQuote
+	if err := runJSON(t, runExport, &quotes, "-lang", "ja"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := quoteIDs(quotes), []string{"10100001", "12700001", "10100002"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRunLint(t *testing.T) {
+	dir := t.TempDir()
+	clean := filepath.Join(dir, "clean.txt")
+	broken := filepath.Join(dir, "broken.txt")
+	if err := os.WriteFile(clean, []byte(`d [lv 0*"10"*"10100001"]"Clean line."[\]`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(broken, []byte(`d [lv 0*"10"*"10100001"]"{p:1:Unclosed red truth."[\]`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var found []lintDiagnostic
+	if err := runJSON(t, runLint, &found, clean); err != nil || len(found) != 0 {
+		t.Errorf("clean script: got %v and %+v, want no problems", err, found)
+	}
+	if err := runJSON(t, runLint, &found, clean, broken); err == nil {
+		t.Error("expected an error for a broken script")
+	}
+	if len(found) == 0 {
+		t.Fatal("expected diagnostics for the broken script")
+	}
+	for _, d := range found {
+		if d.File != broken || d.Line != 1 || d.Kind == "" || d.Message == "" {
+			t.Errorf("unexpected diagnostic %+v", d)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Command umineko queries the quote scripts offline, without running the
+// server:
+//
+//	umineko search -lang ja -episode 3 "黄金"
+//	umineko random -character 10 -format json
+//	umineko context 10100001 -lines 3
+//	umineko character 27 -truth red -format table
+//	umineko stats -episode 5
+//	umineko export -lang en -character 10
+//
+// It reads the scripts embedded in the binary, or those in SCRIPT_DIR, and
+// the index snapshot at INDEX_SNAPSHOT when one is set, as the server does.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"umineko_quote/internal/quote"
+)
+
+// errFlags reports flags the flag package has already complained about.
+var errFlags = errors.New("invalid flags")
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"search", "search [flags] <query>", "Search quotes", runSearch},
+	{"random", "random [flags]", "Print a random quote", runRandom},
+	{"context", "context [flags] <audioId>", "Print a quote with the dialogue around it", runContext},
+	{"character", "character [flags] <characterId>", "List a character's quotes", runCharacter},
+	{"stats", "stats [flags]", "Print script statistics", runStats},
+	{"export", "export [flags]", "Print every quote matching the filters", runExport},
+	{"lint", "lint [flags] <script>...", "Check scripts for malformed lines", runLint},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: umineko [-v] <command> [flags] [args]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun umineko <command> -h for a command's flags.\n")
+}
+
+func main() {
+	verbose := flag.Bool("v", false, "log while loading the scripts")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	name := flag.Arg(0)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		if err := c.run(flag.Args()[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(0)
+			}
+			if errors.Is(err, errFlags) {
+				os.Exit(2)
+			}
+			var usageErr usageError
+			if errors.As(err, &usageErr) {
+				fmt.Fprintf(os.Stderr, "umineko %s: %v\nUsage: umineko %s\n", c.name, err, c.usage)
+				os.Exit(2)
+			}
+			fmt.Fprintf(os.Stderr, "umineko %s: %v\n", c.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "umineko: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+// loadQuotes loads the scripts the way the server does, and checks lang is
+// among them.
+func loadQuotes(lang string) (quote.Service, error) {
+	var svc quote.ReloadableService
+	snapshot := os.Getenv("INDEX_SNAPSHOT")
+	if dir := os.Getenv("SCRIPT_DIR"); dir != "" {
+		var err error
+		if svc, err = quote.NewServiceFromDir(dir, nil, snapshot); err != nil {
+			return nil, err
+		}
+	} else {
+		svc = quote.NewService(nil, snapshot)
+	}
+	if lang != "" && !svc.HasLanguage(lang) {
+		return nil, usageErrorf("unknown language %q", lang)
+	}
+	return svc.Snapshot(), nil
+}
+
+// usageError reports a command invoked with bad arguments.
+type usageError struct {
+	error
+}
+
+func usageErrorf(format string, args ...any) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"umineko_quote/internal/quote"
+)
+
+type outputFormat string
+
+const (
+	formatTable outputFormat = "table"
+	formatJSON  outputFormat = "json"
+	formatText  outputFormat = "text"
+)
+
+func parseFormat(s string) (outputFormat, error) {
+	switch f := outputFormat(s); f {
+	case formatTable, formatJSON, formatText:
+		return f, nil
+	}
+	return "", usageErrorf("unknown format %q; want table, json or text", s)
+}
+
+// tableTextWidth is where quote text is cut off in tables.
+const tableTextWidth = 100
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// writeQuotes prints quotes as a table, or as "Character: text" lines. The
+// quote at focus, if any, is marked.
+func writeQuotes(w io.Writer, format outputFormat, quotes []quote.ParsedQuote, focus int) error {
+	if format == formatText {
+		for i, q := range quotes {
+			prefix := ""
+			if focus >= 0 {
+				prefix = "  "
+				if i == focus {
+					prefix = "> "
+				}
+			}
+			if _, err := fmt.Fprintf(w, "%s%s: %s\n", prefix, q.Character, oneLine(q.Text)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEP\tCHARACTER\tTEXT")
+	for i, q := range quotes {
+		id := q.AudioID
+		if id == "" {
+			id = q.ID
+		}
+		if i == focus {
+			id = "> " + id
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", id, q.Episode, q.Character, truncate(oneLine(q.Text), tableTextWidth))
+	}
+	return tw.Flush()
+}
+
+// writePageSummary notes under a table which page of results it shows.
+func writePageSummary(format outputFormat, shown int, offset int, total int) error {
+	if format != formatTable {
+		return nil
+	}
+	if shown == 0 {
+		_, err := fmt.Fprintf(os.Stdout, "\nNo results (%d total)\n", total)
+		return err
+	}
+	_, err := fmt.Fprintf(os.Stdout, "\n%d-%d of %d\n", offset+1, offset+shown, total)
+	return err
+}
+
+// statsView is the part of the stats response shown outside JSON output.
+type statsView struct {
+	TopSpeakers []struct {
+		CharacterID string `json:"characterId"`
+		Name        string `json:"name"`
+		Count       int    `json:"count"`
+	} `json:"topSpeakers"`
+	TruthPerEpisode []struct {
+		Episode int `json:"episode"`
+		Red     int `json:"red"`
+		Blue    int `json:"blue"`
+	} `json:"truthPerEpisode"`
+}
+
+// writeStats prints the top speakers and the truths per episode. The stats
+// are read back through their JSON form, which is what the API promises.
+func writeStats(w io.Writer, format outputFormat, stats any) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	var view statsView
+	if err := json.Unmarshal(data, &view); err != nil {
+		return err
+	}
+
+	if format == formatText {
+		for _, s := range view.TopSpeakers {
+			fmt.Fprintf(w, "%s: %d\n", s.Name, s.Count)
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RANK\tID\tCHARACTER\tLINES")
+	for i, s := range view.TopSpeakers {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\n", i+1, s.CharacterID, s.Name, s.Count)
+	}
+	if len(view.TruthPerEpisode) > 0 {
+		fmt.Fprintln(tw, "\nEPISODE\tRED\tBLUE\t")
+		for _, t := range view.TruthPerEpisode {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t\n", t.Episode, t.Red, t.Blue)
+		}
+	}
+	return tw.Flush()
+}
+
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func truncate(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -19,13 +19,54 @@ export function buildQueryString(params: Record<string, string | number | undefi
     return qs ? `?${qs}` : "";
 }
 
+// Safari on iOS cannot play Ogg Vorbis, so ask the server for WAV there.
+const needsWav =
+    typeof Audio !== "undefined" && new Audio().canPlayType('audio/ogg; codecs="vorbis"') === "";
+
 export function audioUrl(charId: string, audioId: string): string {
-    return `${API_BASE}/audio/${charId}/${audioId}`;
+    const url = `${API_BASE}/audio/${charId}/${audioId}`;
+    return needsWav ? `${url}?format=wav` : url;
+}
+
+interface CombinedOptions {
+    gapMs?: number;
+    crossfadeMs?: number;
+    normalize?: boolean;
 }
 
-export function combinedAudioUrl(segments: Array<{ charId: string; audioId: string }>): string {
+function combinedQuery(segments: Array<{ charId: string; audioId: string }>, options?: CombinedOptions): string {
     const param = segments.map(s => `${s.charId}:${s.audioId}`).join(",");
-    return `${API_BASE}/audio/combined?segments=${param}`;
+    let query = `segments=${param}`;
+    if (options?.gapMs) {
+        query += `&gap=${options.gapMs}`;
+    }
+    if (options?.crossfadeMs) {
+        query += `&crossfade=${options.crossfadeMs}`;
+    }
+    if (options?.normalize) {
+        query += "&normalize=true";
+    }
+    return query;
+}
+
+export function combinedAudioUrl(
+    segments: Array<{ charId: string; audioId: string }>,
+    options?: CombinedOptions,
+): string {
+    let url = `${API_BASE}/audio/combined?${combinedQuery(segments, options)}`;
+    if (needsWav) {
+        url += "&format=wav";
+    }
+    return url;
+}
+
+// Subtitles timed to the audio combinedAudioUrl returns for the same segments and options.
+export function combinedSubtitlesUrl(
+    segments: Array<{ charId: string; audioId: string }>,
+    options?: CombinedOptions,
+    format: "vtt" | "srt" = "vtt",
+): string {
+    return `${API_BASE}/audio/combined.${format}?${combinedQuery(segments, options)}`;
 }
 
 export function resolveCharId(audioId: string, defaultCharId: string, audioCharMap?: Record<string, string>): string {
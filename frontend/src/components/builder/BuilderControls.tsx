@@ -1,6 +1,6 @@
 import { useCallback, useRef, useState } from "react";
 import { AudioControls } from "../audio/AudioControls";
-import type { VoiceBuilder } from "../../hooks/useVoiceBuilder";
+import { GAP_OPTIONS_MS, type VoiceBuilder } from "../../hooks/useVoiceBuilder";
 import type { AudioPlayer } from "../../hooks/useAudioPlayer";
 
 interface BuilderControlsProps {
@@ -8,6 +8,20 @@ interface BuilderControlsProps {
     audioPlayer: AudioPlayer;
 }
 
+// Combined audio is Ogg unless the server mixed or transcoded it to WAV.
+function extensionFor(contentType: string): string {
+    if (contentType.startsWith("audio/wav")) {
+        return "wav";
+    }
+    if (contentType.startsWith("application/x-subrip")) {
+        return "srt";
+    }
+    if (contentType.startsWith("text/vtt")) {
+        return "vtt";
+    }
+    return "ogg";
+}
+
 export function BuilderControls({ builder, audioPlayer }: BuilderControlsProps) {
     const [downloading, setDownloading] = useState(false);
     const [copied, setCopied] = useState(false);
@@ -24,13 +38,10 @@ export function BuilderControls({ builder, audioPlayer }: BuilderControlsProps)
         audioPlayer.play(builder.combinedUrl, "builder-combined");
     }, [builder.combinedUrl, audioPlayer]);
 
-    const handleDownload = useCallback(async () => {
-        if (!builder.combinedUrl) {
-            return;
-        }
+    const downloadFile = useCallback(async (url: string, baseName: string) => {
         setDownloading(true);
         try {
-            const response = await fetch(builder.combinedUrl);
+            const response = await fetch(url);
             if (!response.ok) {
                 throw new Error(`Download failed: ${response.status}`);
             }
@@ -38,7 +49,7 @@ export function BuilderControls({ builder, audioPlayer }: BuilderControlsProps)
             const blobUrl = URL.createObjectURL(blob);
             const a = document.createElement("a");
             a.href = blobUrl;
-            a.download = "voice-build.ogg";
+            a.download = `${baseName}.${extensionFor(blob.type)}`;
             document.body.appendChild(a);
             a.click();
             document.body.removeChild(a);
@@ -48,7 +59,19 @@ export function BuilderControls({ builder, audioPlayer }: BuilderControlsProps)
         } finally {
             setDownloading(false);
         }
-    }, [builder.combinedUrl]);
+    }, []);
+
+    const handleDownload = useCallback(() => {
+        if (builder.combinedUrl) {
+            downloadFile(builder.combinedUrl, "voice-build");
+        }
+    }, [builder.combinedUrl, downloadFile]);
+
+    const handleDownloadSubtitles = useCallback(() => {
+        if (builder.subtitlesUrl) {
+            downloadFile(builder.subtitlesUrl, "voice-build");
+        }
+    }, [builder.subtitlesUrl, downloadFile]);
 
     const handleShare = useCallback(() => {
         if (!builder.shareUrl) {
@@ -71,6 +94,27 @@ export function BuilderControls({ builder, audioPlayer }: BuilderControlsProps)
     return (
         <div className="builder-controls">
             <AudioControls audioPlayer={audioPlayer} isVisible={isCombinedActive} />
+            <div className="builder-options">
+                <select
+                    className="builder-filter-select"
+                    value={builder.gapMs}
+                    onChange={e => builder.setGapMs(Number(e.target.value))}
+                >
+                    {GAP_OPTIONS_MS.map(ms => (
+                        <option key={ms} value={ms}>
+                            {ms === 0 ? "No pause between lines" : `${ms} ms pause between lines`}
+                        </option>
+                    ))}
+                </select>
+                <select
+                    className="builder-filter-select"
+                    value={builder.normalize ? "even" : "original"}
+                    onChange={e => builder.setNormalize(e.target.value === "even")}
+                >
+                    <option value="original">Original volume</option>
+                    <option value="even">Even out volume</option>
+                </select>
+            </div>
             <div className="builder-controls-buttons">
                 <button
                     className="builder-control-btn builder-play-combined"
@@ -86,6 +130,13 @@ export function BuilderControls({ builder, audioPlayer }: BuilderControlsProps)
                 >
                     {downloading ? "Downloading..." : "\u2913 Download Audio"}
                 </button>
+                <button
+                    className="builder-control-btn builder-download"
+                    disabled={isEmpty || downloading}
+                    onClick={handleDownloadSubtitles}
+                >
+                    {"\u2913 Download Subtitles"}
+                </button>
                 <button className="builder-control-btn builder-share" disabled={isEmpty} onClick={handleShare}>
                     {copied ? "Link Copied!" : "\u2197 Share Link"}
                 </button>
@@ -1,5 +1,5 @@
 import { useCallback, useMemo, useState } from "react";
-import { combinedAudioUrl, resolveCharId } from "../api/client";
+import { combinedAudioUrl, combinedSubtitlesUrl, resolveCharId } from "../api/client";
 import { getQuoteByAudioId } from "../api/endpoints";
 import type { Quote } from "../types/api";
 import type { Language } from "../types/app";
@@ -16,6 +16,8 @@ export interface BuilderSegment {
 
 const MAX_SEGMENTS = 20;
 const STORAGE_KEY = "uminekoVoiceBuilder";
+// Pauses offered between lines; 0 splices the clips back to back.
+export const GAP_OPTIONS_MS = [0, 150, 300, 500, 1000];
 
 function loadFromStorage(): BuilderSegment[] {
     try {
@@ -52,6 +54,9 @@ export function segmentFromQuote(quote: Quote, audioId: string): BuilderSegment
 
 export function useVoiceBuilder() {
     const [segments, setSegments] = useState<BuilderSegment[]>(loadFromStorage);
+    const [gapMs, setGapMs] = useState(0);
+    // Normalizing mixes the clips to WAV, so it is off unless asked for.
+    const [normalize, setNormalize] = useState(false);
 
     const canAdd = segments.length < MAX_SEGMENTS;
     const segmentCount = segments.length;
@@ -108,8 +113,22 @@ export function useVoiceBuilder() {
         if (segments.length === 0) {
             return null;
         }
-        return combinedAudioUrl(segments.map(s => ({ charId: s.charId, audioId: s.audioId })));
-    }, [segments]);
+        return combinedAudioUrl(
+            segments.map(s => ({ charId: s.charId, audioId: s.audioId })),
+            { gapMs, normalize },
+        );
+    }, [segments, gapMs, normalize]);
+
+    const subtitlesUrl = useMemo(() => {
+        if (segments.length === 0) {
+            return null;
+        }
+        return combinedSubtitlesUrl(
+            segments.map(s => ({ charId: s.charId, audioId: s.audioId })),
+            { gapMs, normalize },
+            "srt",
+        );
+    }, [segments, gapMs, normalize]);
 
     const shareUrl = useMemo(() => {
         if (segments.length === 0) {
@@ -174,7 +193,12 @@ export function useVoiceBuilder() {
         removeSegment,
         reorderSegments,
         clearAll,
+        gapMs,
+        setGapMs,
+        normalize,
+        setNormalize,
         combinedUrl,
+        subtitlesUrl,
         shareUrl,
         loadFromUrl,
     };
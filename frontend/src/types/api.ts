@@ -8,6 +8,13 @@ export interface Quote {
     audioId?: string;
     audioCharMap?: Record<string, string>;
     audioTextMap?: Record<string, string>;
+    audioMeta?: Record<string, AudioMeta>;
+}
+
+export interface AudioMeta {
+    durationMs: number;
+    sampleRate: number;
+    channels: number;
 }
 
 export interface SearchResult {
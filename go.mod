@@ -6,6 +6,7 @@ require (
 	github.com/fogleman/gg v1.3.0
 	github.com/gofiber/fiber/v2 v2.52.11
 	golang.org/x/image v0.35.0
+	golang.org/x/text v0.33.0
 )
 
 require (
@@ -21,5 +22,4 @@ require (
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.69.0 // indirect
 	golang.org/x/sys v0.40.0 // indirect
-	golang.org/x/text v0.33.0 // indirect
 )
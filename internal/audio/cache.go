@@ -0,0 +1,196 @@
+package audio
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache keeps transcoded and combined audio on disk, evicting the least
+// recently used entries once it grows past its size limit.
+type Cache interface {
+	// Open returns the cached file for key, which the caller closes, and
+	// its content type.
+	Open(key string) (*os.File, string, bool)
+	Put(key string, data []byte, contentType string)
+}
+
+type diskCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	name string // cacheName of the key, without extension
+	ext  string
+	size int64
+}
+
+func (e cacheEntry) file() string {
+	return e.name + e.ext
+}
+
+// cacheExts maps content types to the extensions that record them on disk.
+var cacheExts = map[string]string{
+	ContentTypeOgg: ".ogg",
+	ContentTypeWAV: ".wav",
+}
+
+func extContentType(ext string) (string, bool) {
+	for contentType, e := range cacheExts {
+		if e == ext {
+			return contentType, true
+		}
+	}
+	return "", false
+}
+
+// NewDiskCache opens a cache in dir, creating it if needed. Files already
+// there are kept, oldest first in line for eviction.
+func NewDiskCache(dir string, maxBytes int64) (Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &diskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type existing struct {
+		entry   cacheEntry
+		modTime time.Time
+	}
+	var found []existing
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if ext == ".tmp" {
+			// Left over from a write that was interrupted.
+			_ = os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		if _, ok := extContentType(ext); !ok {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		found = append(found, existing{cacheEntry{strings.TrimSuffix(f.Name(), ext), ext, info.Size()}, info.ModTime()})
+	}
+	slices.SortFunc(found, func(a, b existing) int {
+		return b.modTime.Compare(a.modTime)
+	})
+	for _, f := range found {
+		c.entries[f.entry.name] = c.order.PushBack(f.entry)
+		c.size += f.entry.size
+	}
+
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// cacheName maps a key to a file name that is safe on any file system.
+func cacheName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *diskCache) Open(key string) (*os.File, string, bool) {
+	name := cacheName(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[name]
+	if !ok {
+		return nil, "", false
+	}
+	entry := elem.Value.(cacheEntry)
+	path := filepath.Join(c.dir, entry.file())
+	// Opening under the lock keeps eviction from racing the open; an entry
+	// evicted afterwards stays readable through the open file.
+	f, err := os.Open(path)
+	if err != nil {
+		c.remove(elem)
+		return nil, "", false
+	}
+	c.order.MoveToFront(elem)
+	// Touch the file so the order survives a restart. This also changes the
+	// file's validators, which is harmless since the content is unchanged.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	contentType, _ := extContentType(entry.ext)
+	return f, contentType, true
+}
+
+func (c *diskCache) Put(key string, data []byte, contentType string) {
+	ext, ok := cacheExts[contentType]
+	if !ok || int64(len(data)) > c.maxBytes {
+		return
+	}
+	name := cacheName(key)
+	path := filepath.Join(c.dir, name+ext)
+
+	tmp, err := os.CreateTemp(c.dir, name+"-*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[name]; ok {
+		if old := elem.Value.(cacheEntry); old.ext != ext {
+			_ = os.Remove(filepath.Join(c.dir, old.file()))
+		}
+		c.size -= elem.Value.(cacheEntry).size
+		c.order.Remove(elem)
+	}
+	c.entries[name] = c.order.PushFront(cacheEntry{name, ext, int64(len(data))})
+	c.size += int64(len(data))
+	c.evict()
+}
+
+// evict drops least recently used entries until the cache fits. The caller
+// holds mu.
+func (c *diskCache) evict() {
+	for c.size > c.maxBytes && c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *diskCache) remove(elem *list.Element) {
+	entry := elem.Value.(cacheEntry)
+	c.order.Remove(elem)
+	delete(c.entries, entry.name)
+	c.size -= entry.size
+	_ = os.Remove(filepath.Join(c.dir, entry.file()))
+}
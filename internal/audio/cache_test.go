@@ -0,0 +1,126 @@
+package audio
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, dir string, maxBytes int64) *diskCache {
+	t.Helper()
+	c, err := NewDiskCache(dir, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*diskCache)
+}
+
+// cached reports whether key is in c, checking the file's contents against
+// want unless it is nil.
+func cached(t *testing.T, c *diskCache, key string, want []byte) bool {
+	t.Helper()
+	f, _, ok := c.Open(key)
+	if !ok {
+		return false
+	}
+	defer f.Close()
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want != nil && !bytes.Equal(got, want) {
+		t.Errorf("%s: got %q, want %q", key, got, want)
+	}
+	return true
+}
+
+// checkSize checks that the cache's size matches both want and the files in
+// its directory.
+func checkSize(t *testing.T, c *diskCache, want int64) {
+	t.Helper()
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk int64
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		onDisk += info.Size()
+	}
+	if c.size != want || onDisk != want {
+		t.Errorf("size: got %d, %d on disk, want %d", c.size, onDisk, want)
+	}
+}
+
+func TestDiskCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newTestCache(t, t.TempDir(), 10)
+	c.Put("a", []byte("aaaa"), ContentTypeOgg)
+	c.Put("b", []byte("bbbb"), ContentTypeOgg)
+	// Reading a makes b the least recently used.
+	if !cached(t, c, "a", []byte("aaaa")) {
+		t.Fatal("a: not cached")
+	}
+	c.Put("c", []byte("cccc"), ContentTypeWAV)
+
+	if cached(t, c, "b", nil) {
+		t.Error("b: expected it to be evicted")
+	}
+	if !cached(t, c, "a", []byte("aaaa")) || !cached(t, c, "c", []byte("cccc")) {
+		t.Error("expected a and c to stay cached")
+	}
+	checkSize(t, c, 8)
+}
+
+func TestDiskCache_SizeAccounting(t *testing.T) {
+	c := newTestCache(t, t.TempDir(), 10)
+	c.Put("a", []byte("aaaa"), ContentTypeOgg)
+	c.Put("a", []byte("aaaaaa"), ContentTypeWAV)
+	checkSize(t, c, 6)
+	f, contentType, ok := c.Open("a")
+	if !ok || contentType != ContentTypeWAV {
+		t.Fatalf("replaced entry: got %q, %v", contentType, ok)
+	}
+	f.Close()
+
+	c.Put("big", bytes.Repeat([]byte("x"), 11), ContentTypeOgg)
+	c.Put("unknown", []byte("x"), "audio/mpeg")
+	if cached(t, c, "big", nil) || cached(t, c, "unknown", nil) {
+		t.Error("expected entries over the limit or of unknown type to be turned away")
+	}
+	checkSize(t, c, 6)
+}
+
+func TestNewDiskCache_AdoptsExisting(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCache(t, dir, 100)
+	c.Put("old", []byte("oooo"), ContentTypeOgg)
+	c.Put("middle", []byte("mmmm"), ContentTypeOgg)
+	c.Put("new", []byte("nnnn"), ContentTypeWAV)
+	base := time.Now().Add(-time.Hour)
+	for i, key := range []string{"old", "middle", "new"} {
+		entry := c.entries[cacheName(key)].Value.(cacheEntry)
+		stamp := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, entry.file()), stamp, stamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "partial-1.tmp"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopened with room for two entries, the oldest goes first.
+	c = newTestCache(t, dir, 8)
+	if cached(t, c, "old", nil) {
+		t.Error("old: expected it to be evicted")
+	}
+	if !cached(t, c, "middle", []byte("mmmm")) || !cached(t, c, "new", []byte("nnnn")) {
+		t.Error("expected middle and new to be adopted")
+	}
+	checkSize(t, c, 8)
+}
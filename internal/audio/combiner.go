@@ -1,48 +1,192 @@
 package audio
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"time"
 )
 
-type FilePathResolver func(charId, audioId string) string
-
 type AudioSegment struct {
 	CharID  string
 	AudioID string
+	// Gap is the silence inserted after the segment. It is ignored on the
+	// last segment.
+	Gap time.Duration
+}
+
+func (s AudioSegment) name() string {
+	return s.CharID + "/" + s.AudioID
 }
 
 type Combiner interface {
-	CombineOgg(segments []AudioSegment, resolve FilePathResolver) ([]byte, error)
+	// Combine joins the segments as opts asks. It splices the Ogg streams
+	// when they share codec settings and neither a crossfade nor
+	// normalization is asked for, and otherwise decodes them, resamples
+	// them to the first clip's format and mixes them into a WAV file. It
+	// returns the data with its content type.
+	Combine(segments []AudioSegment, open ClipOpener, opts CombineOptions) ([]byte, string, error)
+	// Timeline places each segment in the audio Combine returns for the
+	// same arguments, from the samples it emits rather than the requested
+	// gaps, which splicing rounds up to whole Vorbis blocks.
+	Timeline(segments []AudioSegment, open ClipOpener, opts CombineOptions) ([]Span, error)
+}
+
+type CombineOptions struct {
+	// Crossfade overlaps neighbouring segments that have no gap.
+	Crossfade time.Duration
+	// Normalize brings every segment to the same integrated loudness.
+	Normalize bool
 }
 
-type combiner struct{}
+const (
+	ContentTypeOgg = "audio/ogg"
+	ContentTypeWAV = "audio/wav"
+)
+
+// maxLoudnessEntries bounds how many clip measurements a combiner keeps.
+const maxLoudnessEntries = 4096
+
+type combiner struct {
+	loudness *loudnessCache
+}
 
 func NewCombiner() (Combiner, error) {
-	return &combiner{}, nil
+	return &combiner{loudness: newLoudnessCache(maxLoudnessEntries)}, nil
 }
 
-func (c *combiner) CombineOgg(segments []AudioSegment, resolve FilePathResolver) ([]byte, error) {
-	var allFilePages [][]oggPage
+// clipLoudness measures a decoded segment, remembering the result so each
+// version of a clip is analysed once.
+func (c *combiner) clipLoudness(key string, clip *pcmAudio) loudness {
+	if l, ok := c.loudness.get(key); ok {
+		return l
+	}
+	l := measureLoudness(clip)
+	c.loudness.put(key, l)
+	return l
+}
 
+// loadSegments reads and validates every segment's clip. Alongside the
+// streams it returns a key for each that changes when the clip is replaced.
+func (c *combiner) loadSegments(segments []AudioSegment, open ClipOpener) ([]*vorbisStream, []string, error) {
+	if len(segments) == 0 {
+		return nil, nil, fmt.Errorf("no audio files to combine")
+	}
+	streams := make([]*vorbisStream, 0, len(segments))
+	keys := make([]string, 0, len(segments))
 	for i := 0; i < len(segments); i++ {
-		filePath := resolve(segments[i].CharID, segments[i].AudioID)
-		if filePath == "" {
-			return nil, fmt.Errorf("audio file not found: %s/%s", segments[i].CharID, segments[i].AudioID)
+		clip, err := open(segments[i].CharID, segments[i].AudioID)
+		if errors.Is(err, ErrAudioNotFound) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrAudioNotFound, segments[i].name())
 		}
-		data, err := os.ReadFile(filePath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read audio file: %s", segments[i].AudioID)
+			return nil, nil, fmt.Errorf("failed to read audio file: %s", segments[i].AudioID)
 		}
-		pages, err := parseOggPages(data)
+		key := fmt.Sprintf("%s@%d.%d", segments[i].name(), clip.Size(), clip.ModTime().UnixNano())
+		data, err := ReadClip(clip)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse OGG file %s: %v", segments[i].AudioID, err)
+			return nil, nil, fmt.Errorf("failed to read audio file: %s", segments[i].AudioID)
+		}
+		stream, err := validateStream(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", segments[i].name(), err)
+		}
+		streams = append(streams, stream)
+		keys = append(keys, key)
+	}
+	return streams, keys, nil
+}
+
+// checkSegmentsCompatible returns the first reason a segment cannot be
+// spliced after the first one.
+func checkSegmentsCompatible(segments []AudioSegment, streams []*vorbisStream) error {
+	for i := 1; i < len(streams); i++ {
+		if err := checkCompatible(streams[0], streams[i], segments[0].name(), segments[i].name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *combiner) Combine(segments []AudioSegment, open ClipOpener, opts CombineOptions) ([]byte, string, error) {
+	streams, keys, err := c.loadSegments(segments, open)
+	if err != nil {
+		return nil, "", err
+	}
+	if splices(segments, streams, opts) {
+		return c.spliceOgg(segments, streams), ContentTypeOgg, nil
+	}
+	data, err := c.mixPCM(segments, streams, keys, opts)
+	return data, ContentTypeWAV, err
+}
+
+func (c *combiner) Timeline(segments []AudioSegment, open ClipOpener, opts CombineOptions) ([]Span, error) {
+	streams, _, err := c.loadSegments(segments, open)
+	if err != nil {
+		return nil, err
+	}
+	if splices(segments, streams, opts) {
+		return spliceTimeline(segments, streams), nil
+	}
+	return mixTimeline(segments, streams, opts), nil
+}
+
+// splices reports whether Combine splices the streams rather than mixing
+// them.
+func splices(segments []AudioSegment, streams []*vorbisStream, opts CombineOptions) bool {
+	return opts.Crossfade <= 0 && !opts.Normalize && checkSegmentsCompatible(segments, streams) == nil
+}
+
+// spliceTimeline follows spliceOgg: every stream runs to its final granule
+// and is followed by the silence gapSilence codes.
+func spliceTimeline(segments []AudioSegment, streams []*vorbisStream) []Span {
+	rate := streams[0].setup.sampleRate
+	spans := make([]Span, len(streams))
+	var offset int64
+	for i, stream := range streams {
+		frames := lastGranule(stream.pages)
+		spans[i] = frameSpan(offset, offset+frames, rate)
+		offset += frames
+		if i < len(streams)-1 && segments[i].Gap > 0 {
+			_, silence := gapSilence(segments, streams, i)
+			offset += silence
 		}
-		allFilePages = append(allFilePages, pages)
 	}
+	return spans
+}
+
+// mixTimeline follows mixPCM, which decodes each stream to its final
+// granule and resamples it to the first stream's rate.
+func mixTimeline(segments []AudioSegment, streams []*vorbisStream, opts CombineOptions) []Span {
+	rate := streams[0].setup.sampleRate
+	spans := make([]Span, len(streams))
+	var end int
+	for i, stream := range streams {
+		frames := resampledFrames(int(lastGranule(stream.pages)), stream.setup.sampleRate, rate)
+		start := end
+		if i > 0 {
+			if gap := segments[i-1].Gap; gap > 0 {
+				start += framesFor(gap, rate)
+			} else {
+				start -= min(framesFor(opts.Crossfade, rate), end/2, frames/2)
+			}
+		}
+		end = start + frames
+		spans[i] = frameSpan(int64(start), int64(end), rate)
+	}
+	return spans
+}
+
+func frameSpan(start, end int64, rate int) Span {
+	return Span{
+		Start: time.Duration(start) * time.Second / time.Duration(rate),
+		End:   time.Duration(end) * time.Second / time.Duration(rate),
+	}
+}
 
-	if len(allFilePages) == 0 {
-		return nil, fmt.Errorf("no audio files to combine")
+func (c *combiner) spliceOgg(segments []AudioSegment, streams []*vorbisStream) []byte {
+	allFilePages := make([][]oggPage, len(streams))
+	for i, stream := range streams {
+		allFilePages[i] = stream.pages
 	}
 
 	serialNumber := allFilePages[0][0].serialNumber
@@ -55,13 +199,7 @@ func (c *combiner) CombineOgg(segments []AudioSegment, resolve FilePathResolver)
 		isFirst := fileIdx == 0
 		isLast := fileIdx == len(allFilePages)-1
 
-		var fileLastGranule int64
-		for i := len(pages) - 1; i >= 0; i-- {
-			if pages[i].granulePos > 0 {
-				fileLastGranule = pages[i].granulePos
-				break
-			}
-		}
+		fileLastGranule := lastGranule(pages)
 
 		headersDone := isFirst
 		firstIncluded := false
@@ -106,7 +244,80 @@ func (c *combiner) CombineOgg(segments []AudioSegment, resolve FilePathResolver)
 		}
 
 		granuleOffset += fileLastGranule
+
+		if isLast || segments[fileIdx].Gap <= 0 {
+			continue
+		}
+		silence, frames := gapSilence(segments, streams, fileIdx)
+		half := frames / int64(len(silence))
+		for len(silence) > 0 {
+			n := min(len(silence), 255)
+			granuleOffset += half * int64(n)
+			page := packetsPage(silence[:n], serialNumber, granuleOffset)
+			page.sequenceNumber = sequenceNum
+			result = append(result, page.serialize()...)
+			sequenceNum++
+			silence = silence[n:]
+		}
+	}
+
+	return result
+}
+
+// gapSilence codes the silence spliced after segment i, which is at least
+// its gap and ends on a whole block.
+func gapSilence(segments []AudioSegment, streams []*vorbisStream, i int) ([][]byte, int64) {
+	setup := streams[0].setup
+	return setup.silentPackets(
+		framesFor(segments[i].Gap, setup.sampleRate),
+		setup.isLongBlock(streams[i].lastAudioPacket()),
+		setup.isLongBlock(streams[i+1].firstAudioPacket()),
+	)
+}
+
+func (s *vorbisStream) lastAudioPacket() []byte {
+	if len(s.audio) == 0 {
+		return nil
+	}
+	return s.audio[len(s.audio)-1]
+}
+
+func (s *vorbisStream) firstAudioPacket() []byte {
+	if len(s.audio) == 0 {
+		return nil
 	}
+	return s.audio[0]
+}
+
+// mixPCM decodes every file, converts it to the first file's sample rate
+// and channel count and lays them end to end, separated by each segment's
+// gap or overlapped by the crossfade when it has none. With Normalize set,
+// each clip's gain is adjusted before mixing, its measurement remembered
+// under the clip's key.
+func (c *combiner) mixPCM(segments []AudioSegment, streams []*vorbisStream, keys []string, opts CombineOptions) ([]byte, error) {
+	var out *pcmAudio
+	for i, stream := range streams {
+		clip, err := stream.decode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode OGG file %s: %v", segments[i].AudioID, err)
+		}
+		if opts.Normalize {
+			clip.applyGain(c.clipLoudness(keys[i], clip).normalizeGain())
+		}
+		if out == nil {
+			out = &pcmAudio{sampleRate: clip.sampleRate, samples: make([][]float32, clip.channels())}
+			out.append(clip, 0)
+			continue
+		}
+		clip = clip.convert(out.sampleRate, out.channels())
 
-	return result, nil
+		if gap := segments[i-1].Gap; gap > 0 {
+			out.appendSilence(framesFor(gap, out.sampleRate))
+			out.append(clip, 0)
+			continue
+		}
+		overlap := min(framesFor(opts.Crossfade, out.sampleRate), out.frames()/2, clip.frames()/2)
+		out.append(clip, overlap)
+	}
+	return out.encodeWAV(), nil
 }
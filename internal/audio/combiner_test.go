@@ -0,0 +1,145 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// openTestClips opens every segment as testdata/clip.ogg.
+func openTestClips(t *testing.T) ClipOpener {
+	t.Helper()
+	data := readTestClip(t)
+	return func(charID, audioID string) (Clip, error) {
+		return readerClip{bytes.NewReader(data), int64(len(data)), time.Time{}}, nil
+	}
+}
+
+func newTestCombiner(t *testing.T) Combiner {
+	t.Helper()
+	c, err := NewCombiner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCombine_Splice(t *testing.T) {
+	segments := []AudioSegment{{CharID: "10", AudioID: "a"}, {CharID: "10", AudioID: "b"}}
+	data, contentType, err := newTestCombiner(t).Combine(segments, openTestClips(t), CombineOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != ContentTypeOgg {
+		t.Fatalf("got %s, want a spliced Ogg stream", contentType)
+	}
+	stream, err := validateStream(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lastGranule(stream.pages); got != 2*44100 {
+		t.Errorf("final granule: got %d, want %d", got, 2*44100)
+	}
+}
+
+func TestCombine_SpliceWithGap(t *testing.T) {
+	clip := testClipStream(t)
+	gap := 300 * time.Millisecond
+	_, silence := clip.setup.silentPackets(
+		framesFor(gap, 44100),
+		clip.setup.isLongBlock(clip.lastAudioPacket()),
+		clip.setup.isLongBlock(clip.firstAudioPacket()),
+	)
+	// 300ms is 13230 frames, rounded up to whole halves of a long block.
+	if silence != 13312 {
+		t.Fatalf("silence: got %d frames, want 13312", silence)
+	}
+
+	segments := []AudioSegment{
+		{CharID: "10", AudioID: "a", Gap: gap},
+		{CharID: "10", AudioID: "b", Gap: gap},
+		{CharID: "10", AudioID: "c", Gap: gap},
+	}
+	data, contentType, err := newTestCombiner(t).Combine(segments, openTestClips(t), CombineOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != ContentTypeOgg {
+		t.Fatalf("got %s, want a spliced Ogg stream", contentType)
+	}
+	stream, err := validateStream(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The gap after the last segment is ignored.
+	want := 3*44100 + 2*silence
+	if got := lastGranule(stream.pages); got != want {
+		t.Errorf("final granule: got %d, want %d", got, want)
+	}
+	pcm, err := stream.decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(pcm.frames()) != want {
+		t.Errorf("decoded %d frames, want %d", pcm.frames(), want)
+	}
+}
+
+func TestTimeline_MatchesCombine(t *testing.T) {
+	gap := 300 * time.Millisecond
+	for _, tc := range []struct {
+		name     string
+		segments []AudioSegment
+		opts     CombineOptions
+	}{
+		{"splice", []AudioSegment{{AudioID: "a"}, {AudioID: "b"}}, CombineOptions{}},
+		// Splicing rounds the 300ms gaps up to whole blocks.
+		{"splice with gaps", []AudioSegment{{AudioID: "a", Gap: gap}, {AudioID: "b", Gap: gap}, {AudioID: "c"}}, CombineOptions{}},
+		{"mix with gaps", []AudioSegment{{AudioID: "a", Gap: gap}, {AudioID: "b", Gap: gap}, {AudioID: "c"}}, CombineOptions{Normalize: true}},
+		// The one second clips overlap by half a second, not the whole
+		// crossfade.
+		{"crossfade clamped", []AudioSegment{{AudioID: "a"}, {AudioID: "b"}, {AudioID: "c", Gap: gap}}, CombineOptions{Crossfade: 800 * time.Millisecond}},
+	} {
+		c := newTestCombiner(t)
+		spans, err := c.Timeline(tc.segments, openTestClips(t), tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		data, contentType, err := c.Combine(tc.segments, openTestClips(t), tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		var frames int64
+		if contentType == ContentTypeOgg {
+			stream, err := validateStream(data)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			frames = lastGranule(stream.pages)
+		} else {
+			frames = int64(len(data)-44) / 2
+		}
+		if want := time.Duration(frames) * time.Second / 44100; spans[len(spans)-1].End != want {
+			t.Errorf("%s: timeline ends at %v, audio at %v", tc.name, spans[len(spans)-1].End, want)
+		}
+		for i, span := range spans {
+			if span.End-span.Start != time.Second {
+				t.Errorf("%s: span %d lasts %v, want the clip's 1s", tc.name, i, span.End-span.Start)
+			}
+		}
+	}
+}
+
+func TestTimeline_SpliceGapsRounded(t *testing.T) {
+	gap := 300 * time.Millisecond
+	segments := []AudioSegment{{AudioID: "a", Gap: gap}, {AudioID: "b"}}
+	spans, err := newTestCombiner(t).Timeline(segments, openTestClips(t), CombineOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 44100 frames of the first clip and 13312 of silence, not 13230.
+	if want := time.Duration(44100+13312) * time.Second / 44100; spans[1].Start != want {
+		t.Errorf("second segment starts at %v, want %v", spans[1].Start, want)
+	}
+}
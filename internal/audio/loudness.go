@@ -0,0 +1,218 @@
+package audio
+
+import (
+	"container/list"
+	"math"
+	"sync"
+)
+
+const (
+	// normalizeTarget is the integrated loudness, in LUFS, that normalized
+	// clips are brought to. It sits between EBU R128's -23 and the louder
+	// levels streaming services use, which suits speech on the web.
+	normalizeTarget = -18.0
+	// maxNormalizeGain keeps quiet or near-silent clips from being boosted
+	// into noise.
+	maxNormalizeGain = 20.0
+	// normalizeCeiling is the highest sample peak, in dBFS, that gain may
+	// push a clip to.
+	normalizeCeiling = -1.0
+)
+
+// loudness is a clip's EBU R128 measurement.
+type loudness struct {
+	integrated float64 // LUFS; -Inf when the clip is silent
+	peak       float64 // dBFS, sample peak
+}
+
+// loudnessCache remembers clip measurements, forgetting the least recently
+// used once it holds max of them.
+type loudnessCache struct {
+	max int
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type loudnessEntry struct {
+	key      string
+	loudness loudness
+}
+
+func newLoudnessCache(max int) *loudnessCache {
+	return &loudnessCache{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *loudnessCache) get(key string) (loudness, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return loudness{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(loudnessEntry).loudness, true
+}
+
+func (c *loudnessCache) put(key string, l loudness) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = loudnessEntry{key, l}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(loudnessEntry{key, l})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(loudnessEntry).key)
+	}
+}
+
+// biquad is a second-order IIR filter in direct form I.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+func (f biquad) apply(x []float64) {
+	var x1, x2, y1, y2 float64
+	for i, v := range x {
+		y := f.b0*v + f.b1*x1 + f.b2*x2 - f.a1*y1 - f.a2*y2
+		x2, x1 = x1, v
+		y2, y1 = y1, y
+		x[i] = y
+	}
+}
+
+// kWeighting returns the two ITU-R BS.1770 K-weighting stages, a high
+// shelf modelling the head followed by a high-pass, designed for rate.
+func kWeighting(rate int) [2]biquad {
+	fs := float64(rate)
+
+	f0 := 1681.974450955533
+	gain := 3.999843853973347
+	q := 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0 = 38.13547087602444
+	q = 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return [2]biquad{shelf, highPass}
+}
+
+// measureLoudness computes the gated integrated loudness of p per EBU R128:
+// 400ms blocks overlapping by 75%, an absolute gate at -70 LUFS and a
+// relative gate 10 LU below the loudness of the blocks that pass it. Every
+// channel is weighted equally, which is right for mono and stereo clips.
+// A clip shorter than one block is measured as a single block.
+func measureLoudness(p *pcmAudio) loudness {
+	result := loudness{integrated: math.Inf(-1), peak: math.Inf(-1)}
+	frames := p.frames()
+	if frames == 0 {
+		return result
+	}
+
+	filters := kWeighting(p.sampleRate)
+	squares := make([]float64, frames)
+	var peak float64
+	for _, src := range p.samples {
+		x := make([]float64, frames)
+		for i, v := range src {
+			x[i] = float64(v)
+			peak = max(peak, math.Abs(x[i]))
+		}
+		filters[0].apply(x)
+		filters[1].apply(x)
+		for i, v := range x {
+			squares[i] += v * v
+		}
+	}
+	if peak > 0 {
+		result.peak = 20 * math.Log10(peak)
+	}
+
+	block := p.sampleRate * 400 / 1000
+	step := block / 4
+	block = min(block, frames)
+	var powers []float64
+	for start := 0; start+block <= frames; start += max(step, 1) {
+		var sum float64
+		for _, v := range squares[start : start+block] {
+			sum += v
+		}
+		powers = append(powers, sum/float64(block))
+	}
+
+	gated := func(threshold float64) (float64, int) {
+		var sum float64
+		var n int
+		for _, power := range powers {
+			if blockLoudness(power) > threshold {
+				sum += power
+				n++
+			}
+		}
+		return sum, n
+	}
+	sum, n := gated(-70)
+	if n == 0 {
+		return result
+	}
+	sum, n = gated(blockLoudness(sum/float64(n)) - 10)
+	if n == 0 {
+		return result
+	}
+	result.integrated = blockLoudness(sum / float64(n))
+	return result
+}
+
+func blockLoudness(power float64) float64 {
+	return -0.691 + 10*math.Log10(power)
+}
+
+// normalizeGain is the linear gain that brings a clip to normalizeTarget,
+// limited so it neither boosts by more than maxNormalizeGain nor pushes the
+// peak over normalizeCeiling. Silent clips are left alone.
+func (l loudness) normalizeGain() float32 {
+	if math.IsInf(l.integrated, -1) {
+		return 1
+	}
+	gain := min(normalizeTarget-l.integrated, maxNormalizeGain, normalizeCeiling-l.peak)
+	return float32(math.Pow(10, gain/20))
+}
+
+// applyGain scales every sample of p in place.
+func (p *pcmAudio) applyGain(gain float32) {
+	if gain == 1 {
+		return
+	}
+	for _, src := range p.samples {
+		for i := range src {
+			src[i] *= gain
+		}
+	}
+}
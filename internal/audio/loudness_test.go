@@ -0,0 +1,110 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns seconds of a 1 kHz tone peaking at level dBFS on every
+// channel.
+func sine(rate, channels int, seconds float64, level float64) *pcmAudio {
+	amplitude := math.Pow(10, level/20)
+	frames := int(float64(rate) * seconds)
+	p := &pcmAudio{sampleRate: rate, samples: make([][]float32, channels)}
+	for ch := range p.samples {
+		p.samples[ch] = make([]float32, frames)
+		for i := range p.samples[ch] {
+			p.samples[ch][i] = float32(amplitude * math.Sin(2*math.Pi*1000*float64(i)/float64(rate)))
+		}
+	}
+	return p
+}
+
+func TestMeasureLoudness(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		audio *pcmAudio
+		want  float64 // LUFS
+		peak  float64 // dBFS
+	}{
+		// BS.1770 calibrates K-weighting so a full scale 1 kHz sine on
+		// one channel reads -3.01 LUFS.
+		{"full scale", sine(48000, 1, 2, 0), -3.01, 0},
+		{"-20 dBFS", sine(48000, 1, 2, -20), -23.01, -20},
+		{"-20 dBFS at 44.1 kHz", sine(44100, 1, 2, -20), -23.01, -20},
+		{"-20 dBFS stereo", sine(48000, 2, 2, -20), -20, -20},
+		{"shorter than a block", sine(48000, 1, 0.2, -20), -23.01, -20},
+		{"silence", sine(48000, 1, 2, math.Inf(-1)), math.Inf(-1), math.Inf(-1)},
+		{"below the absolute gate", sine(48000, 1, 2, -75), math.Inf(-1), -75},
+		{"empty", &pcmAudio{sampleRate: 48000, samples: make([][]float32, 1)}, math.Inf(-1), math.Inf(-1)},
+	} {
+		got := measureLoudness(tc.audio)
+		if !near(got.integrated, tc.want, 0.5) {
+			t.Errorf("%s: integrated %f LUFS, want %f", tc.name, got.integrated, tc.want)
+		}
+		if !near(got.peak, tc.peak, 0.01) {
+			t.Errorf("%s: peak %f dBFS, want %f", tc.name, got.peak, tc.peak)
+		}
+	}
+}
+
+func near(got, want, tolerance float64) bool {
+	if math.IsInf(want, 0) {
+		return got == want
+	}
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestMeasureLoudness_RelativeGate(t *testing.T) {
+	// A second of tone followed by ten of a tone 30 dB quieter: the quiet
+	// part falls below the relative gate, leaving the loud second and the
+	// blocks straddling the change, which pull it down a little. Ungated,
+	// it would read -33.4 LUFS.
+	loud := sine(48000, 1, 1, -20)
+	quiet := sine(48000, 1, 10, -50)
+	loud.samples[0] = append(loud.samples[0], quiet.samples[0]...)
+	if got := measureLoudness(loud).integrated; !near(got, -23.01, 1) {
+		t.Errorf("integrated %f LUFS, want -23.01", got)
+	}
+}
+
+func TestNormalizeGain(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		l    loudness
+		want float64 // dB
+	}{
+		{"to target", loudness{integrated: -24, peak: -12}, 6},
+		{"limited by peak", loudness{integrated: -24, peak: -3}, 2},
+		{"limited boost", loudness{integrated: -50, peak: -40}, maxNormalizeGain},
+		{"attenuated", loudness{integrated: -10, peak: -1}, -8},
+		{"silent", loudness{integrated: math.Inf(-1), peak: math.Inf(-1)}, 0},
+	} {
+		got := 20 * math.Log10(float64(tc.l.normalizeGain()))
+		if !near(got, tc.want, 1e-4) {
+			t.Errorf("%s: gain %f dB, want %f", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLoudnessCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLoudnessCache(2)
+	c.put("a", loudness{integrated: -1})
+	c.put("b", loudness{integrated: -2})
+	if l, ok := c.get("a"); !ok || l.integrated != -1 {
+		t.Fatalf("a: got %v, %v", l, ok)
+	}
+	c.put("c", loudness{integrated: -3})
+
+	if _, ok := c.get("b"); ok {
+		t.Error("b: expected it to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("%s: expected it to stay cached", key)
+		}
+	}
+	if c.order.Len() != 2 || len(c.entries) != 2 {
+		t.Errorf("holds %d entries, %d indexed, want 2", c.order.Len(), len(c.entries))
+	}
+}
@@ -0,0 +1,141 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Meta describes a voice clip.
+type Meta struct {
+	Duration   time.Duration
+	SampleRate int
+	Channels   int
+}
+
+// maxOggPage is the largest possible Ogg page: a 27 byte header, 255 lacing
+// values and 255 segments of 255 bytes.
+const maxOggPage = 27 + 255 + 255*255
+
+// metaWindow is how much ReadMeta reads from each end of a clip at first.
+// The identification page and the last page of a voice clip almost always
+// fit, so most clips take two small reads; the rest are read again with
+// windows of maxOggPage.
+const metaWindow = 8 << 10
+
+// ReadMeta reads a clip's sample rate and channel count from its
+// identification header and its duration from the granule position of the
+// last page. Only the first and last pages are read, so it is cheap enough
+// to run over every clip at startup.
+func ReadMeta(clip Clip) (Meta, error) {
+	size := clip.Size()
+	head, err := readSection(clip, 0, min(size, metaWindow))
+	if err != nil {
+		return Meta{}, err
+	}
+	if _, ok := pageSize(head); !ok && int64(len(head)) < size {
+		if head, err = readSection(clip, 0, min(size, maxOggPage)); err != nil {
+			return Meta{}, err
+		}
+	}
+	first, err := firstPage(head)
+	if err != nil {
+		return Meta{}, fmt.Errorf("%w: %v", ErrInvalidAudio, err)
+	}
+	// The identification header always fits in the first page.
+	id, err := parseVorbisIdent(first.data)
+	if err != nil {
+		return Meta{}, fmt.Errorf("%w: %v", ErrInvalidAudio, err)
+	}
+
+	tailSize := min(size, metaWindow)
+	tail, err := readSection(clip, size-tailSize, tailSize)
+	if err != nil {
+		return Meta{}, err
+	}
+	granule, err := finalGranule(tail, tailSize < size)
+	if err != nil && tailSize < size {
+		tailSize = min(size, maxOggPage)
+		if tail, err = readSection(clip, size-tailSize, tailSize); err != nil {
+			return Meta{}, err
+		}
+		granule, err = finalGranule(tail, false)
+	}
+	if err != nil {
+		return Meta{}, fmt.Errorf("%w: %v", ErrInvalidAudio, err)
+	}
+
+	return Meta{
+		Duration:   time.Duration(granule) * time.Second / time.Duration(id.sampleRate),
+		SampleRate: id.sampleRate,
+		Channels:   id.channels,
+	}, nil
+}
+
+func readSection(clip Clip, off, n int64) ([]byte, error) {
+	data := make([]byte, n)
+	if _, err := io.ReadFull(io.NewSectionReader(clip, off, n), data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// firstPage parses the page at the start of data, which may be followed by
+// a partial page.
+func firstPage(data []byte) (oggPage, error) {
+	size, ok := pageSize(data)
+	if !ok {
+		return oggPage{}, errors.New("truncated first page")
+	}
+	pages, err := parseOggPages(data[:size])
+	if err != nil {
+		return oggPage{}, err
+	}
+	return pages[0], nil
+}
+
+// pageSize returns the total length of the page at the start of data, if
+// its header is complete and it fits.
+func pageSize(data []byte) (int, bool) {
+	if len(data) < 27 || string(data[:4]) != "OggS" {
+		return 0, false
+	}
+	segments := int(data[26])
+	if len(data) < 27+segments {
+		return 0, false
+	}
+	size := 27 + segments
+	for _, lace := range data[27 : 27+segments] {
+		size += int(lace)
+	}
+	return size, size <= len(data)
+}
+
+// finalGranule scans backwards through the tail of a file for the last
+// complete page with a valid checksum and a granule position. With atEnd
+// set the page must end the tail, as a tail shorter than maxOggPage may
+// have cut the true last page short and left an earlier one whole.
+func finalGranule(tail []byte, atEnd bool) (int64, error) {
+	for offset := len(tail) - 27; offset >= 0; offset-- {
+		if string(tail[offset:offset+4]) != "OggS" {
+			continue
+		}
+		size, ok := pageSize(tail[offset:])
+		if !ok {
+			continue
+		}
+		if atEnd && offset+size != len(tail) {
+			continue
+		}
+		page := tail[offset : offset+size]
+		if crc(page, true) != binary.LittleEndian.Uint32(page[22:26]) {
+			continue
+		}
+		if granule := int64(binary.LittleEndian.Uint64(page[6:14])); granule > 0 {
+			return granule, nil
+		}
+	}
+	return 0, errors.New("no page with a granule position")
+}
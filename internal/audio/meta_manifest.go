@@ -0,0 +1,187 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ScanMeta reads the metadata of every listed clip in store, leaving out
+// clips that fail to open or parse. A store wrapped by WithMetaManifest
+// only opens clips that are new or changed since its last scan.
+func ScanMeta(store AudioStore, clips []ClipInfo) map[ClipRef]Meta {
+	if m, ok := store.(*manifestStore); ok {
+		return m.scanMeta(clips)
+	}
+	return scanMeta(store, clips)
+}
+
+// scanMeta opens the clips a few at a time, as a remote store spends most
+// of each read waiting.
+func scanMeta(store AudioStore, clips []ClipInfo) map[ClipRef]Meta {
+	meta := make(map[ClipRef]Meta)
+	if len(clips) == 0 {
+		return meta
+	}
+
+	refs := make(chan ClipRef)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Go(func() {
+			for ref := range refs {
+				clip, err := store.Open(ref.CharID, ref.AudioID)
+				if err != nil {
+					continue
+				}
+				m, err := ReadMeta(clip)
+				clip.Close()
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				meta[ref] = m
+				mu.Unlock()
+			}
+		})
+	}
+
+	for _, clip := range clips {
+		refs <- clip.ClipRef
+	}
+	close(refs)
+	wg.Wait()
+	return meta
+}
+
+// metaManifestVersion must be bumped whenever ReadMeta changes what it
+// reports, so manifests written before are read again from the clips.
+const metaManifestVersion = 1
+
+type (
+	// metaManifest is the file WithMetaManifest keeps.
+	metaManifest struct {
+		Version int                 `json:"version"`
+		Clips   []metaManifestEntry `json:"clips"`
+	}
+
+	// metaManifestEntry is a clip's metadata along with the version of the
+	// clip it was read from.
+	metaManifestEntry struct {
+		CharID     string        `json:"charId"`
+		AudioID    string        `json:"audioId"`
+		Size       int64         `json:"size"`
+		ModTime    int64         `json:"modTime"` // Unix nanoseconds
+		Duration   time.Duration `json:"duration"`
+		SampleRate int           `json:"sampleRate"`
+		Channels   int           `json:"channels"`
+	}
+)
+
+type manifestStore struct {
+	AudioStore
+	path string
+}
+
+// WithMetaManifest keeps the metadata ScanMeta reads from store in a JSON
+// file at path, so later scans open only the clips whose listed size or
+// modification time no longer match. A missing, stale or damaged manifest
+// is rebuilt from the clips.
+func WithMetaManifest(store AudioStore, path string) AudioStore {
+	return &manifestStore{AudioStore: store, path: path}
+}
+
+func (s *manifestStore) scanMeta(clips []ClipInfo) map[ClipRef]Meta {
+	saved := s.load()
+	meta := make(map[ClipRef]Meta, len(clips))
+	var changed []ClipInfo
+	for _, clip := range clips {
+		entry, ok := saved[clip.ClipRef]
+		if ok && entry.Size == clip.Size && entry.ModTime == clip.ModTime.UnixNano() {
+			meta[clip.ClipRef] = Meta{Duration: entry.Duration, SampleRate: entry.SampleRate, Channels: entry.Channels}
+			continue
+		}
+		changed = append(changed, clip)
+	}
+	reused := len(meta)
+	fresh := scanMeta(s.AudioStore, changed)
+	maps.Copy(meta, fresh)
+
+	// Clips that fail to parse are left out of the manifest and read again
+	// next time, without rewriting it unless something else changed.
+	if len(fresh) > 0 || len(saved) != reused {
+		if err := s.save(clips, meta); err != nil {
+			log.Printf("[audio] failed to save clip metadata to %s: %v", s.path, err)
+		}
+	}
+	return meta
+}
+
+func (s *manifestStore) load() map[ClipRef]metaManifestEntry {
+	saved := make(map[ClipRef]metaManifestEntry)
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[audio] failed to read clip metadata from %s: %v", s.path, err)
+		}
+		return saved
+	}
+	var manifest metaManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Version != metaManifestVersion {
+		return saved
+	}
+	for _, entry := range manifest.Clips {
+		saved[ClipRef{entry.CharID, entry.AudioID}] = entry
+	}
+	return saved
+}
+
+// save writes the manifest through a temporary file, so a crash never
+// leaves a partial one.
+func (s *manifestStore) save(clips []ClipInfo, meta map[ClipRef]Meta) error {
+	manifest := metaManifest{Version: metaManifestVersion}
+	for _, clip := range clips {
+		m, ok := meta[clip.ClipRef]
+		if !ok {
+			continue
+		}
+		manifest.Clips = append(manifest.Clips, metaManifestEntry{
+			CharID:     clip.CharID,
+			AudioID:    clip.AudioID,
+			Size:       clip.Size,
+			ModTime:    clip.ModTime.UnixNano(),
+			Duration:   m.Duration,
+			SampleRate: m.SampleRate,
+			Channels:   m.Channels,
+		})
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
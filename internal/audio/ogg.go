@@ -42,6 +42,9 @@ func parseOggPages(data []byte) ([]oggPage, error) {
 			return nil, fmt.Errorf("missing OggS capture pattern at offset %d", offset)
 		}
 
+		if version := data[offset+4]; version != 0 {
+			return nil, fmt.Errorf("unsupported Ogg version %d at offset %d", version, offset)
+		}
 		headerType := data[offset+5]
 		granulePos := int64(binary.LittleEndian.Uint64(data[offset+6 : offset+14]))
 		serialNumber := binary.LittleEndian.Uint32(data[offset+14 : offset+18])
@@ -66,6 +69,11 @@ func parseOggPages(data []byte) ([]oggPage, error) {
 			return nil, fmt.Errorf("truncated page data at offset %d", offset)
 		}
 
+		stored := binary.LittleEndian.Uint32(data[offset+22 : offset+26])
+		if computed := crc(data[offset:dataEnd], true); computed != stored {
+			return nil, fmt.Errorf("page CRC mismatch at offset %d: stored %08x, computed %08x", offset, stored, computed)
+		}
+
 		pageData := make([]byte, dataSize)
 		copy(pageData, data[segTableEnd:dataEnd])
 
@@ -94,21 +102,24 @@ func (p *oggPage) serialize() []byte {
 	binary.LittleEndian.PutUint64(buf[6:14], uint64(p.granulePos))
 	binary.LittleEndian.PutUint32(buf[14:18], p.serialNumber)
 	binary.LittleEndian.PutUint32(buf[18:22], p.sequenceNumber)
-	binary.LittleEndian.PutUint32(buf[22:26], 0)
 	buf[26] = byte(len(p.segmentTable))
 	copy(buf[27:headerSize], p.segmentTable)
 	copy(buf[headerSize:], p.data)
 
-	crc := p.oggCRC(buf)
-	binary.LittleEndian.PutUint32(buf[22:26], crc)
-
+	binary.LittleEndian.PutUint32(buf[22:26], crc(buf, false))
 	return buf
 }
 
-func (*oggPage) oggCRC(data []byte) uint32 {
-	var crc uint32
-	for _, b := range data {
-		crc = (crc << 8) ^ oggCRCTable[(crc>>24)^uint32(b)]
+// crc computes the checksum of a raw Ogg page. With skipChecksum set the
+// page's own checksum field is read as zero, which is how the stored value
+// was calculated, so a page can be checked against it.
+func crc(data []byte, skipChecksum bool) uint32 {
+	var sum uint32
+	for i, b := range data {
+		if skipChecksum && i >= 22 && i < 26 {
+			b = 0
+		}
+		sum = (sum << 8) ^ oggCRCTable[(sum>>24)^uint32(b)]
 	}
-	return crc
+	return sum
 }
@@ -0,0 +1,140 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// pcmAudio is decoded audio with one float slice per channel, nominally in
+// the range [-1, 1].
+type pcmAudio struct {
+	sampleRate int
+	samples    [][]float32
+}
+
+func (p *pcmAudio) channels() int {
+	return len(p.samples)
+}
+
+func (p *pcmAudio) frames() int {
+	if len(p.samples) == 0 {
+		return 0
+	}
+	return len(p.samples[0])
+}
+
+// framesFor converts a duration to a whole number of frames at rate.
+func framesFor(d time.Duration, rate int) int {
+	return int(d.Seconds() * float64(rate))
+}
+
+// resampledFrames is the number of frames convert turns frames at rate from
+// into at rate to.
+func resampledFrames(frames, from, to int) int {
+	if from == to || frames == 0 {
+		return frames
+	}
+	return int(float64(frames) / (float64(from) / float64(to)))
+}
+
+// convert returns p resampled to rate and remixed to the given channel
+// count. Resampling is linear, which is plenty for speech.
+func (p *pcmAudio) convert(rate, channels int) *pcmAudio {
+	out := p
+	if p.sampleRate != rate && p.frames() > 0 {
+		ratio := float64(p.sampleRate) / float64(rate)
+		frames := resampledFrames(p.frames(), p.sampleRate, rate)
+		out = &pcmAudio{sampleRate: rate, samples: make([][]float32, p.channels())}
+		for ch, src := range p.samples {
+			dst := make([]float32, frames)
+			for i := range dst {
+				pos := float64(i) * ratio
+				j := int(pos)
+				frac := float32(pos - float64(j))
+				a := src[min(j, len(src)-1)]
+				b := src[min(j+1, len(src)-1)]
+				dst[i] = a + (b-a)*frac
+			}
+			out.samples[ch] = dst
+		}
+	}
+	out.sampleRate = rate
+
+	if out.channels() == channels {
+		return out
+	}
+	mixed := &pcmAudio{sampleRate: rate, samples: make([][]float32, channels)}
+	if out.channels() == 1 {
+		for ch := range mixed.samples {
+			mixed.samples[ch] = out.samples[0]
+		}
+		return mixed
+	}
+	// Downmix by averaging every source channel into each output channel.
+	sum := make([]float32, out.frames())
+	for _, src := range out.samples {
+		for i, v := range src {
+			sum[i] += v / float32(out.channels())
+		}
+	}
+	for ch := range mixed.samples {
+		mixed.samples[ch] = sum
+	}
+	return mixed
+}
+
+// append adds clip to the end of p, which must share its sample rate and
+// channel count. The first overlap frames of clip are crossfaded with the
+// last overlap frames of p.
+func (p *pcmAudio) append(clip *pcmAudio, overlap int) {
+	start := p.frames() - overlap
+	for ch := range p.samples {
+		dst := p.samples[ch]
+		src := clip.samples[ch]
+		for i := 0; i < overlap; i++ {
+			fade := float32(i+1) / float32(overlap+1)
+			dst[start+i] = dst[start+i]*(1-fade) + src[i]*fade
+		}
+		p.samples[ch] = append(dst, src[overlap:]...)
+	}
+}
+
+func (p *pcmAudio) appendSilence(frames int) {
+	for ch := range p.samples {
+		p.samples[ch] = append(p.samples[ch], make([]float32, frames)...)
+	}
+}
+
+// encodeWAV renders p as a 16-bit PCM WAV file.
+func (p *pcmAudio) encodeWAV() []byte {
+	channels := p.channels()
+	frames := p.frames()
+	dataSize := frames * channels * 2
+	buf := make([]byte, 44+dataSize)
+
+	copy(buf[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(36+dataSize))
+	copy(buf[8:12], "WAVE")
+	copy(buf[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(buf[16:20], 16)
+	binary.LittleEndian.PutUint16(buf[20:22], 1)
+	binary.LittleEndian.PutUint16(buf[22:24], uint16(channels))
+	binary.LittleEndian.PutUint32(buf[24:28], uint32(p.sampleRate))
+	binary.LittleEndian.PutUint32(buf[28:32], uint32(p.sampleRate*channels*2))
+	binary.LittleEndian.PutUint16(buf[32:34], uint16(channels*2))
+	binary.LittleEndian.PutUint16(buf[34:36], 16)
+	copy(buf[36:40], "data")
+	binary.LittleEndian.PutUint32(buf[40:44], uint32(dataSize))
+
+	offset := 44
+	for i := 0; i < frames; i++ {
+		for ch := 0; ch < channels; ch++ {
+			v := math.Round(float64(p.samples[ch][i]) * 32767)
+			v = math.Max(-32768, math.Min(32767, v))
+			binary.LittleEndian.PutUint16(buf[offset:], uint16(int16(v)))
+			offset += 2
+		}
+	}
+	return buf
+}
@@ -0,0 +1,127 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrAudioNotFound is returned when a store has no clip for an ID.
+var ErrAudioNotFound = errors.New("audio file not found")
+
+// Clip is an opened voice clip. It can be read at any offset, so it can be
+// served in ranges without being read whole.
+type Clip interface {
+	io.ReaderAt
+	io.Closer
+	Size() int64
+	ModTime() time.Time
+}
+
+// ClipRef names a clip in a store.
+type ClipRef struct {
+	CharID  string
+	AudioID string
+}
+
+// ClipInfo is a listed clip. Its size and modification time tell versions
+// of the clip apart, as they do for an opened Clip.
+type ClipInfo struct {
+	ClipRef
+	Size    int64
+	ModTime time.Time
+}
+
+// AudioStore holds voice clips laid out as <characterID>/<audioID>.ogg.
+type AudioStore interface {
+	// Open returns a clip, or an error wrapping ErrAudioNotFound if the
+	// store has none by that ID.
+	Open(charID, audioID string) (Clip, error)
+	// List returns every clip in the store.
+	List() ([]ClipInfo, error)
+}
+
+// ClipOpener opens the clip for a segment, usually an AudioStore's Open.
+type ClipOpener func(charID, audioID string) (Clip, error)
+
+// ReadClip reads a whole clip into memory and closes it.
+func ReadClip(clip Clip) ([]byte, error) {
+	defer clip.Close()
+	data := make([]byte, clip.Size())
+	if _, err := io.ReadFull(io.NewSectionReader(clip, 0, clip.Size()), data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+type dirStore struct {
+	dir string
+}
+
+// NewDirStore serves clips unpacked under dir, as setup_audio.sh lays them
+// out.
+func NewDirStore(dir string) AudioStore {
+	return &dirStore{dir: dir}
+}
+
+type fileClip struct {
+	*os.File
+	info os.FileInfo
+}
+
+func (c fileClip) Size() int64 {
+	return c.info.Size()
+}
+
+func (c fileClip) ModTime() time.Time {
+	return c.info.ModTime()
+}
+
+func (s *dirStore) Open(charID, audioID string) (Clip, error) {
+	f, err := os.Open(filepath.Join(s.dir, charID, audioID+".ogg"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s/%s", ErrAudioNotFound, charID, audioID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s/%s", ErrAudioNotFound, charID, audioID)
+	}
+	return fileClip{f, info}, nil
+}
+
+func (s *dirStore) List() ([]ClipInfo, error) {
+	dirs, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var clips []ClipInfo
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(s.dir, dir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), ".ogg") {
+				continue
+			}
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+			ref := ClipRef{dir.Name(), strings.TrimSuffix(file.Name(), ".ogg")}
+			clips = append(clips, ClipInfo{ref, info.Size(), info.ModTime()})
+		}
+	}
+	return clips, nil
+}
@@ -0,0 +1,321 @@
+package audio
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3Config points an S3 store at a bucket on any S3-compatible service,
+// such as AWS S3, MinIO or Cloudflare R2. Requests are path-style, and are
+// signed with Signature Version 4 unless no access key is given.
+type S3Config struct {
+	// Endpoint is the service's base URL, such as http://localhost:9000.
+	Endpoint string
+	Bucket   string
+	// Region defaults to us-east-1, which MinIO expects.
+	Region string
+	// Prefix is prepended to <characterID>/<audioID>.ogg to form keys.
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// Client defaults to an http.Client with a 30 second timeout.
+	Client *http.Client
+}
+
+// s3ReadAhead is how much of a clip one request fetches once reads turn
+// sequential. It covers most clips whole, and sequential reads of longer
+// ones in few requests.
+const s3ReadAhead = 256 << 10
+
+// emptySHA256 is the payload hash of a request without a body.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+type s3Store struct {
+	S3Config
+	endpoint *url.URL
+}
+
+// NewS3Store serves clips from the bucket cfg describes. It makes no
+// requests until a clip is listed or opened.
+func NewS3Store(cfg S3Config) (AudioStore, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("S3 audio store needs an endpoint and a bucket")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if cfg.Prefix != "" && !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+	return &s3Store{S3Config: cfg, endpoint: endpoint}, nil
+}
+
+func (s *s3Store) key(charID, audioID string) string {
+	return s.Prefix + charID + "/" + audioID + ".ogg"
+}
+
+func (s *s3Store) Open(charID, audioID string) (Clip, error) {
+	key := s.key(charID, audioID)
+	resp, err := s.do(http.MethodHead, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s/%s", ErrAudioNotFound, charID, audioID)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("S3 HEAD %s: %s", key, resp.Status)
+	case resp.ContentLength < 0:
+		return nil, fmt.Errorf("S3 HEAD %s: no Content-Length", key)
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &s3Clip{store: s, key: key, size: resp.ContentLength, modTime: modTime}, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *s3Store) List() ([]ClipInfo, error) {
+	var clips []ClipInfo
+	query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix}}
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("S3 list %s: %s", s.Bucket, resp.Status)
+		} else {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			rest := strings.TrimPrefix(object.Key, s.Prefix)
+			charID, file, ok := strings.Cut(rest, "/")
+			if !ok || charID == "" || strings.Contains(file, "/") || !strings.HasSuffix(file, ".ogg") {
+				continue
+			}
+			ref := ClipRef{charID, strings.TrimSuffix(file, ".ogg")}
+			clips = append(clips, ClipInfo{ref, object.Size, object.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return clips, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// getRange fetches bytes first through last of an object.
+func (s *s3Store) getRange(key string, first, last int64) ([]byte, error) {
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", first, last)}}
+	resp, err := s.do(http.MethodGet, key, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return io.ReadAll(resp.Body)
+	case http.StatusOK:
+		// The service ignored the range and sent the whole object.
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if first >= int64(len(data)) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return data[first:min(last+1, int64(len(data)))], nil
+	}
+	return nil, fmt.Errorf("S3 GET %s: %s", key, resp.Status)
+}
+
+// do sends a signed request for key, or for the bucket when key is empty.
+func (s *s3Store) do(method string, key string, query url.Values, header http.Header) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = s.endpoint.Path + "/" + s.Bucket
+	u.RawPath = s3Escape(s.endpoint.Path, true) + "/" + s3Escape(s.Bucket, false)
+	if key != "" {
+		u.Path += "/" + key
+		u.RawPath += "/" + s3Escape(key, true)
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if s.AccessKey != "" {
+		s.sign(req, time.Now().UTC())
+	}
+	return s.Client.Do(req)
+}
+
+// sign adds AWS Signature Version 4 headers to req.
+func (s *s3Store) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + emptySHA256,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes everything but unreserved characters, and
+// slashes too unless keepSlash is set, as Signature Version 4 requires.
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery encodes query sorted by name, which is both the signed
+// form and what is sent.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Clip reads an object through ranged GETs, keeping the last window it
+// fetched. A read that carries on from where the last one ended fetches at
+// least s3ReadAhead bytes, so streaming costs one request per window; any
+// other read fetches only what it asks for, so reading the first and last
+// pages of a clip stays cheap.
+type s3Clip struct {
+	store   *s3Store
+	key     string
+	size    int64
+	modTime time.Time
+
+	mu          sync.Mutex
+	window      []byte
+	windowStart int64
+	next        int64 // where the last read ended
+}
+
+func (c *s3Clip) Size() int64 {
+	return c.size
+}
+
+func (c *s3Clip) ModTime() time.Time {
+	return c.modTime
+}
+
+func (c *s3Clip) Close() error {
+	return nil
+}
+
+func (c *s3Clip) ReadAt(p []byte, off int64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sequential := c.window != nil && off == c.next
+	n := 0
+	for n < len(p) && off < c.size {
+		if off < c.windowStart || off >= c.windowStart+int64(len(c.window)) {
+			want := int64(len(p) - n)
+			if sequential {
+				want = max(want, s3ReadAhead)
+			}
+			last := min(off+want, c.size) - 1
+			data, err := c.store.getRange(c.key, off, last)
+			if err != nil {
+				return n, err
+			}
+			if len(data) == 0 {
+				return n, io.ErrUnexpectedEOF
+			}
+			c.window, c.windowStart = data, off
+		}
+		copied := copy(p[n:], c.window[off-c.windowStart:])
+		n += copied
+		off += int64(copied)
+	}
+	c.next = off
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
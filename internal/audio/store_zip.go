@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+type zipStore struct {
+	archive *os.File
+	files   map[ClipRef]*zip.File
+}
+
+// NewZipStore serves clips straight from the voice archive, which holds
+// them as <anything>/<characterID>/<audioID>.ogg, without extracting it.
+// Stored entries are read in place; compressed ones are inflated on open.
+func NewZipStore(archivePath string) (AudioStore, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read voice archive %s: %w", archivePath, err)
+	}
+
+	s := &zipStore{archive: f, files: make(map[ClipRef]*zip.File)}
+	for _, file := range r.File {
+		name := file.Name
+		if file.FileInfo().IsDir() || path.Ext(name) != ".ogg" {
+			continue
+		}
+		charID := path.Base(path.Dir(name))
+		if charID == "." || charID == "/" {
+			continue
+		}
+		s.files[ClipRef{charID, strings.TrimSuffix(path.Base(name), ".ogg")}] = file
+	}
+	return s, nil
+}
+
+// readerClip is a clip backed by any io.ReaderAt, which it does not own.
+type readerClip struct {
+	io.ReaderAt
+	size    int64
+	modTime time.Time
+}
+
+func (c readerClip) Size() int64 {
+	return c.size
+}
+
+func (c readerClip) ModTime() time.Time {
+	return c.modTime
+}
+
+func (c readerClip) Close() error {
+	return nil
+}
+
+func (s *zipStore) Open(charID, audioID string) (Clip, error) {
+	file, ok := s.files[ClipRef{charID, audioID}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s", ErrAudioNotFound, charID, audioID)
+	}
+	size := int64(file.UncompressedSize64)
+	if file.Method == zip.Store {
+		offset, err := file.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		return readerClip{io.NewSectionReader(s.archive, offset, size), size, file.Modified}, nil
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inflate %s: %w", file.Name, err)
+	}
+	return readerClip{bytes.NewReader(data), int64(len(data)), file.Modified}, nil
+}
+
+func (s *zipStore) List() ([]ClipInfo, error) {
+	clips := make([]ClipInfo, 0, len(s.files))
+	for ref, file := range s.files {
+		clips = append(clips, ClipInfo{ref, int64(file.UncompressedSize64), file.Modified})
+	}
+	return clips, nil
+}
@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SubtitleFormat is a subtitle file format for combined audio.
+type SubtitleFormat string
+
+const (
+	SubtitlesVTT SubtitleFormat = "vtt"
+	SubtitlesSRT SubtitleFormat = "srt"
+)
+
+func (f SubtitleFormat) ContentType() string {
+	if f == SubtitlesSRT {
+		return "application/x-subrip; charset=utf-8"
+	}
+	return "text/vtt; charset=utf-8"
+}
+
+// Span is where a segment plays within combined audio.
+type Span struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// EstimateTimeline places each segment in the output of Combine, given the
+// duration of every clip, without reading the clips. Segments follow one
+// another after their gap, or overlap by the crossfade, limited to half of
+// either side as the mixer does, when they have none. Splicing rounds each
+// gap up to whole Vorbis blocks, so a spliced segment may start up to one
+// long block per earlier gap later; Combiner.Timeline is exact.
+func EstimateTimeline(segments []AudioSegment, durations []time.Duration, crossfade time.Duration) []Span {
+	spans := make([]Span, len(segments))
+	var end time.Duration
+	for i := range segments {
+		start := end
+		if i > 0 {
+			if gap := segments[i-1].Gap; gap > 0 {
+				start += gap
+			} else {
+				start -= min(crossfade, end/2, durations[i]/2)
+			}
+		}
+		spans[i] = Span{Start: start, End: start + durations[i]}
+		end = spans[i].End
+	}
+	return spans
+}
+
+// Cue is one subtitle.
+type Cue struct {
+	Span
+	// Speaker names who is talking; WebVTT marks it with a voice span.
+	Speaker string
+	Text    string
+}
+
+// WriteSubtitles renders cues as a WebVTT or SRT file.
+func WriteSubtitles(cues []Cue, format SubtitleFormat) []byte {
+	var b strings.Builder
+	if format != SubtitlesSRT {
+		b.WriteString("WEBVTT\n\n")
+	}
+	for i, cue := range cues {
+		fmt.Fprintf(&b, "%d\n", i+1)
+		if format == SubtitlesSRT {
+			fmt.Fprintf(&b, "%s --> %s\n%s\n\n", subtitleTime(cue.Start, ','), subtitleTime(cue.End, ','), cueText(cue.Text))
+			continue
+		}
+		text := vttEscape(cueText(cue.Text))
+		if cue.Speaker != "" {
+			text = "<v " + vttEscape(cue.Speaker) + ">" + text
+		}
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", subtitleTime(cue.Start, '.'), subtitleTime(cue.End, '.'), text)
+	}
+	return []byte(b.String())
+}
+
+// subtitleTime formats d as hh:mm:ss followed by sep and milliseconds.
+func subtitleTime(d time.Duration, sep byte) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// cueText drops blank lines, which would end the cue early.
+func cueText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func vttEscape(s string) string {
+	return vttEscaper.Replace(s)
+}
@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestEstimateTimeline(t *testing.T) {
+	second := time.Second
+	for _, tc := range []struct {
+		name      string
+		segments  []AudioSegment
+		durations []time.Duration
+		crossfade time.Duration
+		want      []Span
+	}{
+		{
+			name:      "back to back",
+			segments:  []AudioSegment{{}, {}},
+			durations: []time.Duration{2 * second, 3 * second},
+			want:      []Span{{0, 2 * second}, {2 * second, 5 * second}},
+		},
+		{
+			name:      "gap, ignored after the last segment",
+			segments:  []AudioSegment{{Gap: 500 * time.Millisecond}, {Gap: second}},
+			durations: []time.Duration{2 * second, 3 * second},
+			want:      []Span{{0, 2 * second}, {2500 * time.Millisecond, 5500 * time.Millisecond}},
+		},
+		{
+			name:      "crossfade",
+			segments:  []AudioSegment{{}, {}},
+			durations: []time.Duration{2 * second, 3 * second},
+			crossfade: 400 * time.Millisecond,
+			want:      []Span{{0, 2 * second}, {1600 * time.Millisecond, 4600 * time.Millisecond}},
+		},
+		{
+			name:      "crossfade clamped to half the next clip",
+			segments:  []AudioSegment{{}, {}},
+			durations: []time.Duration{4 * second, second},
+			crossfade: 3 * second,
+			want:      []Span{{0, 4 * second}, {3500 * time.Millisecond, 4500 * time.Millisecond}},
+		},
+		{
+			name:      "crossfade clamped to half the audio so far",
+			segments:  []AudioSegment{{}, {}},
+			durations: []time.Duration{second, 4 * second},
+			crossfade: 3 * second,
+			want:      []Span{{0, second}, {500 * time.Millisecond, 4500 * time.Millisecond}},
+		},
+	} {
+		got := EstimateTimeline(tc.segments, tc.durations, tc.crossfade)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %d spans, want %d", tc.name, len(got), len(tc.want))
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: span %d is %v, want %v", tc.name, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
+
+// testCues covers hours, text that must be escaped in WebVTT, blank lines
+// and a crossfade clamped to half of the short middle clip.
+func testCues() []Cue {
+	segments := []AudioSegment{{}, {Gap: 250 * time.Millisecond}, {}}
+	durations := []time.Duration{
+		time.Hour + 1500*time.Millisecond,
+		2 * time.Second,
+		1234 * time.Millisecond,
+	}
+	spans := EstimateTimeline(segments, durations, 3*time.Second)
+	return []Cue{
+		{Span: spans[0], Speaker: "Battler", Text: "Ahahaha!\n\n  I won't give up!  "},
+		{Span: spans[1], Speaker: "Beatrice <the Golden Witch>", Text: "Red truth: <3 & more"},
+		{Span: spans[2], Text: "No speaker."},
+	}
+}
+
+func TestWriteSubtitles(t *testing.T) {
+	for _, format := range []SubtitleFormat{SubtitlesVTT, SubtitlesSRT} {
+		got := WriteSubtitles(testCues(), format)
+		path := filepath.Join("testdata", "subtitles."+string(format))
+		if *update {
+			if err := os.WriteFile(path, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", format, got, want)
+		}
+	}
+}
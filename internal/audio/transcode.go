@@ -0,0 +1,52 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Format is an output format for voice clips.
+type Format string
+
+const (
+	FormatOgg Format = "ogg"
+	FormatWAV Format = "wav"
+)
+
+// ErrUnsupportedFormat is returned for formats there is no encoder for.
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// ParseFormat reads a format query value. An empty value means Ogg, the
+// format the clips are stored in.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "", "ogg":
+		return FormatOgg, nil
+	case "wav":
+		return FormatWAV, nil
+	}
+	return "", fmt.Errorf("%w: %q (supported: ogg, wav)", ErrUnsupportedFormat, s)
+}
+
+func (f Format) ContentType() string {
+	if f == FormatWAV {
+		return ContentTypeWAV
+	}
+	return ContentTypeOgg
+}
+
+// Transcode converts an Ogg Vorbis file to format.
+func Transcode(data []byte, format Format) ([]byte, error) {
+	switch format {
+	case FormatOgg:
+		return data, nil
+	case FormatWAV:
+		pcm, err := decodeOggVorbis(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAudio, err)
+		}
+		return pcm.encodeWAV(), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+}
@@ -0,0 +1,123 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidAudio marks a file that is not a well-formed Ogg Vorbis
+	// stream.
+	ErrInvalidAudio = errors.New("invalid audio file")
+	// ErrIncompatibleAudio marks clips whose codec settings differ too much
+	// to be spliced into one stream.
+	ErrIncompatibleAudio = errors.New("incompatible audio files")
+)
+
+// StreamInfo describes the codec parameters of an Ogg Vorbis file.
+type StreamInfo struct {
+	Channels   int
+	SampleRate int
+	// BlockSizes holds the short and long Vorbis block sizes.
+	BlockSizes [2]int
+}
+
+// ValidateOgg checks that data holds a single, complete Ogg Vorbis stream:
+// every page checksum matches, pages are in sequence from a beginning-of-
+// stream page to an end-of-stream page, and the identification, comment and
+// setup headers parse. Errors wrap ErrInvalidAudio.
+func ValidateOgg(data []byte) (StreamInfo, error) {
+	stream, err := validateStream(data)
+	if err != nil {
+		return StreamInfo{}, err
+	}
+	return stream.info(), nil
+}
+
+func (s *vorbisStream) info() StreamInfo {
+	return StreamInfo{
+		Channels:   s.setup.channels,
+		SampleRate: s.setup.sampleRate,
+		BlockSizes: s.setup.blocksize,
+	}
+}
+
+func validateStream(data []byte) (*vorbisStream, error) {
+	stream, err := parseVorbisStream(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAudio, err)
+	}
+	if err := checkPageSequence(stream.pages); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAudio, err)
+	}
+	for i, packet := range stream.audio {
+		if err := stream.setup.checkAudioPacket(packet); err != nil {
+			return nil, fmt.Errorf("%w: audio packet %d: %v", ErrInvalidAudio, i, err)
+		}
+	}
+	return stream, nil
+}
+
+// checkPageSequence verifies the page framing of a single logical stream.
+func checkPageSequence(pages []oggPage) error {
+	if len(pages) == 0 {
+		return errors.New("no Ogg pages")
+	}
+	first := pages[0]
+	if first.headerType&0x02 == 0 {
+		return errors.New("first page is not marked beginning-of-stream")
+	}
+	for i, page := range pages {
+		if page.serialNumber != first.serialNumber {
+			return fmt.Errorf("page %d belongs to stream %08x, expected %08x (chained or multiplexed streams are not supported)", i, page.serialNumber, first.serialNumber)
+		}
+		if want := first.sequenceNumber + uint32(i); page.sequenceNumber != want {
+			return fmt.Errorf("page %d has sequence number %d, expected %d", i, page.sequenceNumber, want)
+		}
+		if i > 0 && page.headerType&0x02 != 0 {
+			return fmt.Errorf("page %d repeats the beginning-of-stream flag", i)
+		}
+		if i < len(pages)-1 && page.headerType&0x04 != 0 {
+			return fmt.Errorf("page %d is marked end-of-stream before the last page", i)
+		}
+	}
+	if pages[len(pages)-1].headerType&0x04 == 0 {
+		return errors.New("stream is truncated: last page is not marked end-of-stream")
+	}
+	return nil
+}
+
+// checkAudioPacket verifies that a packet is an audio packet using one of the
+// modes from the setup header.
+func (s *vorbisSetup) checkAudioPacket(packet []byte) error {
+	if len(packet) == 0 {
+		return nil
+	}
+	r := newBitReader(packet)
+	if r.readFlag() {
+		return errors.New("unexpected header packet")
+	}
+	if mode := int(r.read(ilog(len(s.modes) - 1))); mode >= len(s.modes) {
+		return fmt.Errorf("mode %d out of range", mode)
+	}
+	return nil
+}
+
+// checkCompatible reports why other cannot be spliced after first, or nil
+// when their audio packets decode under the same setup. The names identify
+// the clips in the error.
+func checkCompatible(first, other *vorbisStream, firstName, otherName string) error {
+	a, b := first.info(), other.info()
+	switch {
+	case a.SampleRate != b.SampleRate:
+		return fmt.Errorf("%w: %s is %d Hz but %s is %d Hz", ErrIncompatibleAudio, otherName, b.SampleRate, firstName, a.SampleRate)
+	case a.Channels != b.Channels:
+		return fmt.Errorf("%w: %s has %d channels but %s has %d", ErrIncompatibleAudio, otherName, b.Channels, firstName, a.Channels)
+	case a.BlockSizes != b.BlockSizes:
+		return fmt.Errorf("%w: %s uses block sizes %v but %s uses %v", ErrIncompatibleAudio, otherName, b.BlockSizes, firstName, a.BlockSizes)
+	case !bytes.Equal(first.headers[2], other.headers[2]):
+		return fmt.Errorf("%w: %s was encoded with a different Vorbis setup than %s", ErrIncompatibleAudio, otherName, firstName)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// rewriteTestClip parses the test clip's pages, lets edit change them and
+// serializes them again with fresh checksums.
+func rewriteTestClip(t *testing.T, edit func(pages []oggPage)) []byte {
+	t.Helper()
+	pages, err := parseOggPages(readTestClip(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit(pages)
+	var data []byte
+	for i := range pages {
+		data = append(data, pages[i].serialize()...)
+	}
+	return data
+}
+
+func TestValidateOgg(t *testing.T) {
+	info, err := ValidateOgg(readTestClip(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (StreamInfo{Channels: 1, SampleRate: 44100, BlockSizes: [2]int{256, 2048}}); info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+
+	badCRC := readTestClip(t)
+	badCRC[len(badCRC)-1] ^= 0xff
+
+	gap := rewriteTestClip(t, func(pages []oggPage) {
+		pages[len(pages)-1].sequenceNumber++
+	})
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"bad CRC", badCRC, "CRC mismatch"},
+		{"sequence gap", gap, "sequence number"},
+		{"missing setup header", testClip(44100, 1, 44100), "missing header packets"},
+	} {
+		_, err := ValidateOgg(tc.data)
+		if !errors.Is(err, ErrInvalidAudio) {
+			t.Errorf("%s: got %v, want ErrInvalidAudio", tc.name, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %q does not mention %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestCheckCompatible(t *testing.T) {
+	first := testClipStream(t)
+	if err := checkCompatible(first, testClipStream(t), "a", "b"); err != nil {
+		t.Errorf("identical clips: %v", err)
+	}
+
+	// The identification header sits alone on the first page, with the
+	// sample rate at bytes 12 to 15.
+	resampled, err := validateStream(rewriteTestClip(t, func(pages []oggPage) {
+		binary.LittleEndian.PutUint32(pages[0].data[12:], 22050)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkCompatible(first, resampled, "a", "b")
+	if !errors.Is(err, ErrIncompatibleAudio) {
+		t.Fatalf("got %v, want ErrIncompatibleAudio", err)
+	}
+	if want := "b is 22050 Hz but a is 44100 Hz"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not mention %q", err, want)
+	}
+}
@@ -0,0 +1,47 @@
+package audio
+
+// bitReader reads Vorbis packets, which pack values least significant bit
+// first. Reading past the end sets eop and returns zero bits, matching the
+// spec's end-of-packet rule for audio packets.
+type bitReader struct {
+	data []byte
+	pos  int // bit position
+	eop  bool
+}
+
+func newBitReader(data []byte) *bitReader {
+	return &bitReader{data: data}
+}
+
+func (r *bitReader) readBit() uint32 {
+	if r.pos >= len(r.data)*8 {
+		r.eop = true
+		return 0
+	}
+	bit := uint32(r.data[r.pos>>3]>>(r.pos&7)) & 1
+	r.pos++
+	return bit
+}
+
+// read returns the next n bits (n <= 32) as an unsigned integer.
+func (r *bitReader) read(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		v |= r.readBit() << i
+	}
+	return v
+}
+
+func (r *bitReader) readFlag() bool {
+	return r.readBit() == 1
+}
+
+// ilog returns the position of the highest set bit of v, counting from 1.
+func ilog(v int) int {
+	n := 0
+	for v > 0 {
+		n++
+		v >>= 1
+	}
+	return n
+}
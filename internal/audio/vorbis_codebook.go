@@ -0,0 +1,251 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errCorruptStream = errors.New("corrupt vorbis stream")
+
+// codebook is a Vorbis Huffman codebook with its optional VQ lookup table.
+type codebook struct {
+	dimensions int
+	entries    int
+	// tree holds the decode tree: node i has children tree[2i] and
+	// tree[2i+1]. A positive child is another node index; a negative child
+	// -(entry+1) is a leaf. Zero marks a missing branch.
+	tree []int32
+	// single is set when the book has exactly one used entry, which takes
+	// no bits to code.
+	single int
+	// vectors holds the unpacked VQ value of every entry, dimensions apiece.
+	vectors []float32
+}
+
+func readCodebook(r *bitReader) (*codebook, error) {
+	if r.read(24) != 0x564342 {
+		return nil, fmt.Errorf("%w: bad codebook sync pattern", errCorruptStream)
+	}
+	cb := &codebook{
+		dimensions: int(r.read(16)),
+		entries:    int(r.read(24)),
+		single:     -1,
+	}
+
+	lengths := make([]int, cb.entries)
+	if r.readFlag() {
+		// ordered
+		current := 0
+		length := int(r.read(5)) + 1
+		for current < cb.entries {
+			number := int(r.read(ilog(cb.entries - current)))
+			if current+number > cb.entries {
+				return nil, fmt.Errorf("%w: codebook lengths overflow", errCorruptStream)
+			}
+			for i := current; i < current+number; i++ {
+				lengths[i] = length
+			}
+			current += number
+			length++
+		}
+	} else {
+		sparse := r.readFlag()
+		for i := range lengths {
+			if !sparse || r.readFlag() {
+				lengths[i] = int(r.read(5)) + 1
+			}
+		}
+	}
+
+	if err := cb.buildTree(lengths); err != nil {
+		return nil, err
+	}
+
+	lookupType := r.read(4)
+	switch lookupType {
+	case 0:
+	case 1, 2:
+		minimum := float32Unpack(r.read(32))
+		delta := float32Unpack(r.read(32))
+		valueBits := int(r.read(4)) + 1
+		sequenceP := r.readFlag()
+
+		var lookupValues int
+		if lookupType == 1 {
+			lookupValues = lookup1Values(cb.entries, cb.dimensions)
+		} else {
+			lookupValues = cb.entries * cb.dimensions
+		}
+		multiplicands := make([]uint32, lookupValues)
+		for i := range multiplicands {
+			multiplicands[i] = r.read(valueBits)
+		}
+		cb.unpackVectors(lookupType, multiplicands, minimum, delta, sequenceP)
+	default:
+		return nil, fmt.Errorf("%w: codebook lookup type %d", errCorruptStream, lookupType)
+	}
+
+	if r.eop {
+		return nil, fmt.Errorf("%w: truncated codebook", errCorruptStream)
+	}
+	return cb, nil
+}
+
+// buildTree assigns codewords to entries in order, each taking the lowest
+// free codeword of its length, and builds the decode tree.
+func (cb *codebook) buildTree(lengths []int) error {
+	used := 0
+	for i, l := range lengths {
+		if l > 0 {
+			used++
+			cb.single = i
+		}
+	}
+	if used != 1 {
+		cb.single = -1
+	}
+	if used <= 1 {
+		return nil
+	}
+
+	var marker [33]uint32
+	cb.tree = make([]int32, 2)
+	for entry, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		code := marker[length]
+		if length < 32 && code>>length != 0 {
+			return fmt.Errorf("%w: overspecified codebook", errCorruptStream)
+		}
+		cb.insert(code, length, entry)
+
+		for j := length; j > 0; j-- {
+			if marker[j]&1 != 0 {
+				if j == 1 {
+					marker[1]++
+				} else {
+					marker[j] = marker[j-1] << 1
+				}
+				break
+			}
+			marker[j]++
+		}
+		for j := length + 1; j < 33; j++ {
+			if marker[j]>>1 != code {
+				break
+			}
+			code = marker[j]
+			marker[j] = marker[j-1] << 1
+		}
+	}
+	return nil
+}
+
+// insert adds the length-bit codeword code, read most significant bit first,
+// as a leaf for entry.
+func (cb *codebook) insert(code uint32, length int, entry int) {
+	node := int32(0)
+	for i := length - 1; i > 0; i-- {
+		slot := 2*node + int32((code>>i)&1)
+		if cb.tree[slot] <= 0 {
+			cb.tree[slot] = int32(len(cb.tree) / 2)
+			cb.tree = append(cb.tree, 0, 0)
+		}
+		node = cb.tree[slot]
+	}
+	cb.tree[2*node+int32(code&1)] = -int32(entry) - 1
+}
+
+// float32Unpack converts the spec's packed float format.
+func float32Unpack(x uint32) float32 {
+	mantissa := float64(x & 0x1fffff)
+	if x&0x80000000 != 0 {
+		mantissa = -mantissa
+	}
+	exponent := int((x & 0x7fe00000) >> 21)
+	return float32(math.Ldexp(mantissa, exponent-788))
+}
+
+// lookup1Values returns the greatest r with r^dimensions <= entries.
+func lookup1Values(entries, dimensions int) int {
+	r := int(math.Floor(math.Pow(float64(entries), 1/float64(dimensions))))
+	for pow(r+1, dimensions) <= entries {
+		r++
+	}
+	for r > 0 && pow(r, dimensions) > entries {
+		r--
+	}
+	return r
+}
+
+func pow(base, exp int) int {
+	result := 1
+	for i := 0; i < exp; i++ {
+		result *= base
+		if result > 1<<40 {
+			break
+		}
+	}
+	return result
+}
+
+func (cb *codebook) unpackVectors(lookupType uint32, multiplicands []uint32, minimum, delta float32, sequenceP bool) {
+	cb.vectors = make([]float32, cb.entries*cb.dimensions)
+	for entry := 0; entry < cb.entries; entry++ {
+		var last float32
+		indexDivisor := 1
+		for i := 0; i < cb.dimensions; i++ {
+			var offset int
+			if lookupType == 1 {
+				offset = (entry / indexDivisor) % len(multiplicands)
+				indexDivisor *= len(multiplicands)
+			} else {
+				offset = entry*cb.dimensions + i
+			}
+			v := float32(multiplicands[offset])*delta + minimum + last
+			if sequenceP {
+				last = v
+			}
+			cb.vectors[entry*cb.dimensions+i] = v
+		}
+	}
+}
+
+// decodeScalar reads one codeword and returns its entry number, or -1 at the
+// end of the packet.
+func (cb *codebook) decodeScalar(r *bitReader) int {
+	if cb.tree == nil {
+		if cb.single < 0 {
+			r.eop = true
+			return -1
+		}
+		return cb.single
+	}
+	node := int32(0)
+	for {
+		child := cb.tree[2*node+int32(r.readBit())]
+		if r.eop {
+			return -1
+		}
+		switch {
+		case child < 0:
+			return int(-child - 1)
+		case child == 0:
+			r.eop = true
+			return -1
+		default:
+			node = child
+		}
+	}
+}
+
+// decodeVector reads one codeword and returns its VQ vector.
+func (cb *codebook) decodeVector(r *bitReader) []float32 {
+	entry := cb.decodeScalar(r)
+	if entry < 0 || cb.vectors == nil {
+		return nil
+	}
+	return cb.vectors[entry*cb.dimensions : (entry+1)*cb.dimensions]
+}
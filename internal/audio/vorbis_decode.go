@@ -0,0 +1,543 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// oggPackets reassembles the packets of a single logical stream from its
+// pages, following lacing values across page boundaries.
+func oggPackets(pages []oggPage) [][]byte {
+	var packets [][]byte
+	var current []byte
+	for _, page := range pages {
+		offset := 0
+		for _, lace := range page.segmentTable {
+			current = append(current, page.data[offset:offset+int(lace)]...)
+			offset += int(lace)
+			if lace < 255 {
+				packets = append(packets, current)
+				current = nil
+			}
+		}
+	}
+	return packets
+}
+
+// lastGranule returns the granule position of the last page that has one.
+func lastGranule(pages []oggPage) int64 {
+	for i := len(pages) - 1; i >= 0; i-- {
+		if pages[i].granulePos > 0 {
+			return pages[i].granulePos
+		}
+	}
+	return 0
+}
+
+// vorbisStream is an Ogg Vorbis file split into its headers and audio
+// packets.
+type vorbisStream struct {
+	pages   []oggPage
+	headers [3][]byte
+	audio   [][]byte
+	setup   *vorbisSetup
+}
+
+func parseVorbisStream(data []byte) (*vorbisStream, error) {
+	pages, err := parseOggPages(data)
+	if err != nil {
+		return nil, err
+	}
+	packets := oggPackets(pages)
+	if len(packets) < 3 {
+		return nil, fmt.Errorf("%w: missing header packets", errCorruptStream)
+	}
+
+	s := &vorbisStream{pages: pages, audio: packets[3:]}
+	copy(s.headers[:], packets[:3])
+	id, err := parseVorbisIdent(s.headers[0])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := checkVorbisHeader(s.headers[1], 3); err != nil {
+		return nil, err
+	}
+	if s.setup, err = parseVorbisSetup(id, s.headers[2]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// decodeOggVorbis decodes a whole Ogg Vorbis file to PCM.
+func decodeOggVorbis(data []byte) (*pcmAudio, error) {
+	stream, err := parseVorbisStream(data)
+	if err != nil {
+		return nil, err
+	}
+	return stream.decode()
+}
+
+func (s *vorbisStream) decode() (*pcmAudio, error) {
+	setup := s.setup
+	out := &pcmAudio{
+		sampleRate: setup.sampleRate,
+		samples:    make([][]float32, setup.channels),
+	}
+	d := newVorbisDecoder(setup)
+	for _, packet := range s.audio {
+		pcm, err := d.decodePacket(packet)
+		if err != nil {
+			return nil, err
+		}
+		for ch := range out.samples {
+			out.samples[ch] = append(out.samples[ch], pcm[ch]...)
+		}
+	}
+
+	// The final granule position marks the true end of the stream; the last
+	// packet is padded out to a full block.
+	if total := lastGranule(s.pages); total > 0 {
+		for ch := range out.samples {
+			if int64(len(out.samples[ch])) > total {
+				out.samples[ch] = out.samples[ch][:total]
+			}
+		}
+	}
+	return out, nil
+}
+
+type vorbisDecoder struct {
+	setup *vorbisSetup
+	// previous holds the windowed output of the last block per channel.
+	previous  [][]float32
+	prevBlock int
+	windows   map[[3]int][]float32
+	imdct     [2]*imdct
+}
+
+func newVorbisDecoder(setup *vorbisSetup) *vorbisDecoder {
+	return &vorbisDecoder{
+		setup:    setup,
+		previous: make([][]float32, setup.channels),
+		windows:  make(map[[3]int][]float32),
+		imdct:    [2]*imdct{newIMDCT(setup.blocksize[0]), newIMDCT(setup.blocksize[1])},
+	}
+}
+
+// decodePacket decodes one audio packet and returns the samples it
+// completes, which run from the centre of the previous block to the centre
+// of this one. The first packet returns no samples.
+func (d *vorbisDecoder) decodePacket(packet []byte) ([][]float32, error) {
+	s := d.setup
+	r := newBitReader(packet)
+	if r.readFlag() {
+		return nil, fmt.Errorf("%w: expected audio packet", errCorruptStream)
+	}
+	if len(packet) == 0 {
+		return make([][]float32, s.channels), nil
+	}
+	modeNumber := int(r.read(ilog(len(s.modes) - 1)))
+	if modeNumber >= len(s.modes) {
+		return nil, fmt.Errorf("%w: mode out of range", errCorruptStream)
+	}
+	mode := s.modes[modeNumber]
+	m := s.mappings[mode.mapping]
+
+	blockIdx := 0
+	prevLong, nextLong := false, false
+	if mode.blockflag {
+		blockIdx = 1
+		prevLong = r.readFlag()
+		nextLong = r.readFlag()
+	}
+	n := s.blocksize[blockIdx]
+	half := n / 2
+
+	// Floors
+	floors := make([][]float32, s.channels)
+	noResidue := make([]bool, s.channels)
+	for ch := 0; ch < s.channels; ch++ {
+		f := s.floors[m.submapFloor[m.mux[ch]]]
+		floors[ch] = d.decodeFloor1(f, r, half)
+		noResidue[ch] = floors[ch] == nil
+	}
+	for _, step := range m.coupling {
+		if !noResidue[step.magnitude] || !noResidue[step.angle] {
+			noResidue[step.magnitude] = false
+			noResidue[step.angle] = false
+		}
+	}
+
+	// Residues
+	spectra := make([][]float32, s.channels)
+	for ch := range spectra {
+		spectra[ch] = make([]float32, half)
+	}
+	for submap, resIdx := range m.submapResidue {
+		var vectors [][]float32
+		var skip []bool
+		for ch := 0; ch < s.channels; ch++ {
+			if m.mux[ch] == submap {
+				vectors = append(vectors, spectra[ch])
+				skip = append(skip, noResidue[ch])
+			}
+		}
+		d.decodeResidue(s.residues[resIdx], r, vectors, skip, half)
+	}
+
+	// Inverse coupling
+	for i := len(m.coupling) - 1; i >= 0; i-- {
+		mag, ang := spectra[m.coupling[i].magnitude], spectra[m.coupling[i].angle]
+		for j := range mag {
+			mv, av := mag[j], ang[j]
+			switch {
+			case mv > 0 && av > 0:
+				mag[j], ang[j] = mv, mv-av
+			case mv > 0:
+				mag[j], ang[j] = mv+av, mv
+			case av > 0:
+				mag[j], ang[j] = mv, mv+av
+			default:
+				mag[j], ang[j] = mv-av, mv
+			}
+		}
+	}
+
+	window := d.window(blockIdx, prevLong, nextLong)
+	current := make([][]float32, s.channels)
+	for ch := range spectra {
+		block := make([]float32, n)
+		if floors[ch] != nil {
+			for j := range spectra[ch] {
+				spectra[ch][j] *= floors[ch][j]
+			}
+			d.imdct[blockIdx].inverse(spectra[ch], block)
+			for j := range block {
+				block[j] *= window[j]
+			}
+		}
+		current[ch] = block
+	}
+
+	out := make([][]float32, s.channels)
+	if d.prevBlock != 0 {
+		pn := d.prevBlock
+		start := 3*pn/4 - n/4 // offset of this block relative to the previous one
+		count := pn/4 + n/4
+		for ch := range out {
+			samples := make([]float32, count)
+			for i := range samples {
+				pos := pn/2 + i
+				if pos < pn {
+					samples[i] = d.previous[ch][pos]
+				}
+				if j := pos - start; j >= 0 && j < n {
+					samples[i] += current[ch][j]
+				}
+			}
+			out[ch] = samples
+		}
+	}
+	d.previous = current
+	d.prevBlock = n
+	return out, nil
+}
+
+// window returns the window for a block, shaped by the sizes of its
+// neighbours.
+func (d *vorbisDecoder) window(blockIdx int, prevLong, nextLong bool) []float32 {
+	key := [3]int{blockIdx, boolInt(prevLong), boolInt(nextLong)}
+	if w, ok := d.windows[key]; ok {
+		return w
+	}
+
+	n := d.setup.blocksize[blockIdx]
+	short := d.setup.blocksize[0]
+	leftStart, leftEnd, leftN := 0, n/2, n/2
+	rightStart, rightEnd, rightN := n/2, n, n/2
+	if blockIdx == 1 && !prevLong {
+		leftStart, leftEnd, leftN = n/4-short/4, n/4+short/4, short/2
+	}
+	if blockIdx == 1 && !nextLong {
+		rightStart, rightEnd, rightN = 3*n/4-short/4, 3*n/4+short/4, short/2
+	}
+
+	w := make([]float32, n)
+	for i := leftStart; i < leftEnd; i++ {
+		x := math.Sin((float64(i-leftStart) + 0.5) / float64(leftN) * math.Pi / 2)
+		w[i] = float32(math.Sin(math.Pi / 2 * x * x))
+	}
+	for i := leftEnd; i < rightStart; i++ {
+		w[i] = 1
+	}
+	for i := rightStart; i < rightEnd; i++ {
+		x := math.Sin((float64(i-rightStart)+0.5)/float64(rightN)*math.Pi/2 + math.Pi/2)
+		w[i] = float32(math.Sin(math.Pi / 2 * x * x))
+	}
+	d.windows[key] = w
+	return w
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var floor1Ranges = [4]int{256, 128, 86, 64}
+
+// decodeFloor1 reads a floor from the packet and renders its curve over n
+// spectral lines. It returns nil when the floor is unused for the channel.
+func (d *vorbisDecoder) decodeFloor1(f *floor1, r *bitReader, n int) []float32 {
+	if !r.readFlag() {
+		return nil
+	}
+	floorRange := floor1Ranges[f.multiplier-1]
+	y := make([]int, len(f.xList))
+	bits := ilog(floorRange - 1)
+	y[0] = int(r.read(bits))
+	y[1] = int(r.read(bits))
+	offset := 2
+	for _, class := range f.partitionClass {
+		cdim := f.classDimensions[class]
+		cbits := f.classSubclasses[class]
+		csub := (1 << cbits) - 1
+		cval := 0
+		if cbits > 0 {
+			cval = d.setup.codebooks[f.classMasterbook[class]].decodeScalar(r)
+		}
+		for j := 0; j < cdim; j++ {
+			book := f.subclassBooks[class][cval&csub]
+			cval >>= cbits
+			if book >= 0 {
+				y[offset+j] = d.setup.codebooks[book].decodeScalar(r)
+			}
+		}
+		offset += cdim
+	}
+	if r.eop {
+		return nil
+	}
+
+	// Amplitude value synthesis
+	finalY := make([]int, len(y))
+	step2 := make([]bool, len(y))
+	finalY[0], finalY[1] = y[0], y[1]
+	step2[0], step2[1] = true, true
+	for i := 2; i < len(y); i++ {
+		low, high := f.lowNeighbor[i], f.highNeighbor[i]
+		predicted := renderPoint(f.xList[low], finalY[low], f.xList[high], finalY[high], f.xList[i])
+		val := y[i]
+		highRoom := floorRange - predicted
+		lowRoom := predicted
+		room := min(highRoom, lowRoom) * 2
+		if val == 0 {
+			finalY[i] = predicted
+			continue
+		}
+		step2[low], step2[high], step2[i] = true, true, true
+		switch {
+		case val >= room && highRoom > lowRoom:
+			finalY[i] = val - lowRoom + predicted
+		case val >= room:
+			finalY[i] = predicted - val + highRoom - 1
+		case val%2 == 1:
+			finalY[i] = predicted - (val+1)/2
+		default:
+			finalY[i] = predicted + val/2
+		}
+	}
+
+	// Curve synthesis
+	curve := make([]int, n)
+	lx, ly := 0, finalY[f.sorted[0]]*f.multiplier
+	hx, hy := 0, 0
+	for _, idx := range f.sorted[1:] {
+		if !step2[idx] {
+			continue
+		}
+		hx, hy = f.xList[idx], finalY[idx]*f.multiplier
+		renderLine(lx, ly, hx, hy, curve)
+		lx, ly = hx, hy
+	}
+	if hx < n {
+		renderLine(hx, hy, n, hy, curve)
+	}
+
+	out := make([]float32, n)
+	for i, v := range curve {
+		out[i] = floor1InverseDB[min(max(v, 0), 255)]
+	}
+	return out
+}
+
+func renderPoint(x0, y0, x1, y1, x int) int {
+	dy := y1 - y0
+	adx := x1 - x0
+	ady := dy
+	if ady < 0 {
+		ady = -ady
+	}
+	off := ady * (x - x0) / adx
+	if dy < 0 {
+		return y0 - off
+	}
+	return y0 + off
+}
+
+// renderLine draws the integer line from (x0,y0) up to but not including
+// x1 into v, clipped to its length.
+func renderLine(x0, y0, x1, y1 int, v []int) {
+	dy := y1 - y0
+	adx := x1 - x0
+	if adx <= 0 {
+		return
+	}
+	ady := dy
+	if ady < 0 {
+		ady = -ady
+	}
+	base := dy / adx
+	sy := base + 1
+	if dy < 0 {
+		sy = base - 1
+	}
+	absBase := base
+	if absBase < 0 {
+		absBase = -absBase
+	}
+	ady -= absBase * adx
+
+	x, y, err := x0, y0, 0
+	if x < len(v) {
+		v[x] = y
+	}
+	for x = x0 + 1; x < x1 && x < len(v); x++ {
+		err += ady
+		if err >= adx {
+			err -= adx
+			y += sy
+		} else {
+			y += base
+		}
+		v[x] = y
+	}
+}
+
+// floor1InverseDB maps floor curve values to linear amplitudes; the spec's
+// table steps evenly in dB from about -140dB up to 0dB.
+var floor1InverseDB = func() [256]float32 {
+	var table [256]float32
+	for i := range table {
+		table[i] = float32(math.Pow(1.0649863, float64(i-255)))
+	}
+	return table
+}()
+
+// decodeResidue reads a residue into vectors, each of length n. Channels
+// flagged in skip are left untouched.
+func (d *vorbisDecoder) decodeResidue(res *residue, r *bitReader, vectors [][]float32, skip []bool, n int) {
+	if res.kind == 2 {
+		decode := false
+		for _, s := range skip {
+			decode = decode || !s
+		}
+		if !decode {
+			return
+		}
+		ch := len(vectors)
+		flat := make([]float32, n*ch)
+		d.decodeResidueVectors(res, r, [][]float32{flat}, []bool{false}, n*ch)
+		for i, v := range flat {
+			vectors[i%ch][i/ch] = v
+		}
+		return
+	}
+	d.decodeResidueVectors(res, r, vectors, skip, n)
+}
+
+func (d *vorbisDecoder) decodeResidueVectors(res *residue, r *bitReader, vectors [][]float32, skip []bool, n int) {
+	classbook := d.setup.codebooks[res.classbook]
+	classwords := classbook.dimensions
+	begin, end := min(res.begin, n), min(res.end, n)
+	partitions := (end - begin) / res.partitionSize
+	if partitions <= 0 {
+		return
+	}
+
+	classifications := make([][]int, len(vectors))
+	for ch := range classifications {
+		classifications[ch] = make([]int, partitions+classwords)
+	}
+
+	for pass := 0; pass < 8; pass++ {
+		partition := 0
+		for partition < partitions {
+			if pass == 0 {
+				for ch := range vectors {
+					if skip[ch] {
+						continue
+					}
+					temp := classbook.decodeScalar(r)
+					if temp < 0 {
+						return
+					}
+					for i := classwords - 1; i >= 0; i-- {
+						classifications[ch][i+partition] = temp % res.classifications
+						temp /= res.classifications
+					}
+				}
+			}
+			for i := 0; i < classwords && partition < partitions; i++ {
+				for ch := range vectors {
+					if skip[ch] {
+						continue
+					}
+					book := res.books[classifications[ch][partition]][pass]
+					if book < 0 {
+						continue
+					}
+					offset := begin + partition*res.partitionSize
+					if !d.decodePartition(res.kind, d.setup.codebooks[book], r, vectors[ch][offset:offset+res.partitionSize]) {
+						return
+					}
+				}
+				partition++
+			}
+		}
+	}
+}
+
+// decodePartition adds one partition of VQ vectors into v. Residue type 0
+// interleaves each vector across the partition; types 1 and 2 lay them out
+// in order. It reports false at the end of the packet.
+func (d *vorbisDecoder) decodePartition(kind int, book *codebook, r *bitReader, v []float32) bool {
+	dims := book.dimensions
+	if kind == 0 {
+		step := len(v) / dims
+		for i := 0; i < step; i++ {
+			entry := book.decodeVector(r)
+			if entry == nil {
+				return false
+			}
+			for j, e := range entry {
+				v[i+j*step] += e
+			}
+		}
+		return true
+	}
+	for i := 0; i < len(v); {
+		entry := book.decodeVector(r)
+		if entry == nil {
+			return false
+		}
+		for _, e := range entry {
+			if i < len(v) {
+				v[i] += e
+			}
+			i++
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+)
+
+// readTestClip reads testdata/clip.ogg, one second of mono 44.1 kHz audio.
+func readTestClip(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/clip.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func testClipStream(t *testing.T) *vorbisStream {
+	t.Helper()
+	stream, err := validateStream(readTestClip(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stream
+}
+
+func TestDecodeOggVorbis(t *testing.T) {
+	raw, err := os.ReadFile("testdata/clip.f32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]float32, len(raw)/4)
+	for i := range want {
+		want[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+
+	got, err := decodeOggVorbis(readTestClip(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.sampleRate != 44100 || got.channels() != 1 {
+		t.Fatalf("got %d Hz with %d channels, want 44100 Hz mono", got.sampleRate, got.channels())
+	}
+	if got.frames() != len(want) {
+		t.Fatalf("got %d frames, want %d", got.frames(), len(want))
+	}
+	for i, v := range got.samples[0] {
+		if math.Abs(float64(v-want[i])) > 1e-4 {
+			t.Fatalf("sample %d: got %f, want %f", i, v, want[i])
+		}
+	}
+}
+
+func TestIMDCT(t *testing.T) {
+	for _, n := range []int{256, 2048} {
+		x := make([]float32, n/2)
+		for k := range x {
+			x[k] = float32(math.Sin(float64(k)*0.37) + 0.25*math.Cos(float64(k)*1.3))
+		}
+		got := make([]float32, n)
+		newIMDCT(n).inverse(x, got)
+
+		for i := range got {
+			var want float64
+			for k, v := range x {
+				want += float64(v) * math.Cos(math.Pi/float64(n/2)*(float64(i)+0.5+float64(n)/4)*(float64(k)+0.5))
+			}
+			if math.Abs(float64(got[i])-want) > 1e-3 {
+				t.Fatalf("n=%d, sample %d: got %f, want %f", n, i, got[i], want)
+			}
+		}
+	}
+}
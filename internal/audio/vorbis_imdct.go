@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// imdct computes the unscaled inverse MDCT used by Vorbis,
+//
+//	y[n] = sum_k X[k] cos(2π/N (n + 1/2 + N/4)(k + 1/2)),
+//
+// through a DCT-IV of size N/2 evaluated with an N/4 point complex FFT.
+type imdct struct {
+	n    int
+	pre  []complex128 // N/4 entries
+	post []complex128
+	fft  *fft
+}
+
+func newIMDCT(n int) *imdct {
+	m := n / 2
+	t := &imdct{n: n, pre: make([]complex128, m/2), post: make([]complex128, m/2), fft: newFFT(m / 2)}
+	for k := range t.pre {
+		t.pre[k] = cmplx.Exp(complex(0, -math.Pi*(float64(k)+0.25)/float64(m)))
+		t.post[k] = cmplx.Exp(complex(0, -math.Pi*float64(k)/float64(m)))
+	}
+	return t
+}
+
+// inverse transforms the n/2 coefficients in x into n samples in y.
+func (t *imdct) inverse(x []float32, y []float32) {
+	m := t.n / 2
+	u := t.dct4(x)
+	for i := 0; i < m/2; i++ {
+		y[i] = float32(u[i+m/2])
+	}
+	for i := m / 2; i < 3*m/2; i++ {
+		y[i] = float32(-u[3*m/2-1-i])
+	}
+	for i := 3 * m / 2; i < 2*m; i++ {
+		y[i] = float32(-u[i-3*m/2])
+	}
+}
+
+// dct4 computes u[j] = sum_k x[k] cos(π/M (j + 1/2)(k + 1/2)).
+func (t *imdct) dct4(x []float32) []float64 {
+	m := len(x)
+	z := make([]complex128, m/2)
+	for k := range z {
+		z[k] = complex(float64(x[2*k]), float64(x[m-1-2*k])) * t.pre[k]
+	}
+	t.fft.transform(z)
+	u := make([]float64, m)
+	for k := range z {
+		v := z[k] * t.post[k]
+		u[2*k] = real(v)
+		u[m-1-2*k] = -imag(v)
+	}
+	return u
+}
+
+// fft is an in-place radix-2 complex FFT of a fixed power-of-two size.
+type fft struct {
+	n     int
+	roots []complex128
+	rev   []int
+}
+
+func newFFT(n int) *fft {
+	f := &fft{n: n, roots: make([]complex128, n/2), rev: make([]int, n)}
+	for i := range f.roots {
+		f.roots[i] = cmplx.Exp(complex(0, -2*math.Pi*float64(i)/float64(n)))
+	}
+	bits := ilog(n - 1)
+	for i := range f.rev {
+		r := 0
+		for b := 0; b < bits; b++ {
+			if i&(1<<b) != 0 {
+				r |= 1 << (bits - 1 - b)
+			}
+		}
+		f.rev[i] = r
+	}
+	return f
+}
+
+func (f *fft) transform(z []complex128) {
+	for i, r := range f.rev {
+		if i < r {
+			z[i], z[r] = z[r], z[i]
+		}
+	}
+	for size := 2; size <= f.n; size <<= 1 {
+		step := f.n / size
+		for start := 0; start < f.n; start += size {
+			for k := 0; k < size/2; k++ {
+				w := f.roots[k*step]
+				a, b := z[start+k], z[start+k+size/2]*w
+				z[start+k] = a + b
+				z[start+k+size/2] = a - b
+			}
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// errUnsupportedVorbis is returned for streams using features the decoder
+// does not implement, such as floor type 0.
+var errUnsupportedVorbis = errors.New("unsupported vorbis stream")
+
+// vorbisIdent holds the fields of the identification header.
+type vorbisIdent struct {
+	channels   int
+	sampleRate int
+	blocksize  [2]int
+}
+
+type floor1 struct {
+	partitionClass  []int
+	classDimensions []int
+	classSubclasses []int
+	classMasterbook []int
+	subclassBooks   [][]int
+	multiplier      int
+	xList           []int
+	// sorted is the order of xList by increasing X.
+	sorted []int
+	// lowNeighbor and highNeighbor are precomputed per post.
+	lowNeighbor  []int
+	highNeighbor []int
+}
+
+type residue struct {
+	kind            int
+	begin           int
+	end             int
+	partitionSize   int
+	classifications int
+	classbook       int
+	// books[class][pass] is a codebook number, or -1 when unused.
+	books [][8]int
+}
+
+type couplingStep struct {
+	magnitude int
+	angle     int
+}
+
+type mapping struct {
+	coupling      []couplingStep
+	mux           []int
+	submapFloor   []int
+	submapResidue []int
+}
+
+type vorbisMode struct {
+	blockflag bool
+	mapping   int
+}
+
+// vorbisSetup holds everything needed to decode audio packets.
+type vorbisSetup struct {
+	vorbisIdent
+	codebooks []*codebook
+	floors    []*floor1
+	residues  []*residue
+	mappings  []*mapping
+	modes     []vorbisMode
+}
+
+var vorbisMagic = []byte("vorbis")
+
+func checkVorbisHeader(packet []byte, kind byte) (*bitReader, error) {
+	if len(packet) < 7 || packet[0] != kind || !bytes.Equal(packet[1:7], vorbisMagic) {
+		return nil, fmt.Errorf("%w: expected header packet type %d", errCorruptStream, kind)
+	}
+	return newBitReader(packet[7:]), nil
+}
+
+func parseVorbisIdent(packet []byte) (vorbisIdent, error) {
+	var id vorbisIdent
+	r, err := checkVorbisHeader(packet, 1)
+	if err != nil {
+		return id, err
+	}
+	if version := r.read(32); version != 0 {
+		return id, fmt.Errorf("%w: vorbis version %d", errUnsupportedVorbis, version)
+	}
+	id.channels = int(r.read(8))
+	id.sampleRate = int(r.read(32))
+	r.read(32) // bitrate maximum
+	r.read(32) // bitrate nominal
+	r.read(32) // bitrate minimum
+	id.blocksize[0] = 1 << r.read(4)
+	id.blocksize[1] = 1 << r.read(4)
+	framing := r.readFlag()
+
+	switch {
+	case r.eop || !framing:
+		return id, fmt.Errorf("%w: truncated identification header", errCorruptStream)
+	case id.channels == 0 || id.sampleRate == 0:
+		return id, fmt.Errorf("%w: zero channels or sample rate", errCorruptStream)
+	case id.blocksize[0] < 64 || id.blocksize[1] > 8192 || id.blocksize[0] > id.blocksize[1]:
+		return id, fmt.Errorf("%w: invalid block sizes", errCorruptStream)
+	}
+	return id, nil
+}
+
+func parseVorbisSetup(id vorbisIdent, packet []byte) (*vorbisSetup, error) {
+	r, err := checkVorbisHeader(packet, 5)
+	if err != nil {
+		return nil, err
+	}
+	s := &vorbisSetup{vorbisIdent: id}
+
+	count := int(r.read(8)) + 1
+	for i := 0; i < count; i++ {
+		cb, err := readCodebook(r)
+		if err != nil {
+			return nil, err
+		}
+		s.codebooks = append(s.codebooks, cb)
+	}
+
+	count = int(r.read(6)) + 1
+	for i := 0; i < count; i++ {
+		if r.read(16) != 0 {
+			return nil, fmt.Errorf("%w: nonzero time domain transform", errCorruptStream)
+		}
+	}
+
+	count = int(r.read(6)) + 1
+	for i := 0; i < count; i++ {
+		kind := r.read(16)
+		if kind != 1 {
+			return nil, fmt.Errorf("%w: floor type %d", errUnsupportedVorbis, kind)
+		}
+		f, err := s.readFloor1(r)
+		if err != nil {
+			return nil, err
+		}
+		s.floors = append(s.floors, f)
+	}
+
+	count = int(r.read(6)) + 1
+	for i := 0; i < count; i++ {
+		res, err := s.readResidue(r)
+		if err != nil {
+			return nil, err
+		}
+		s.residues = append(s.residues, res)
+	}
+
+	count = int(r.read(6)) + 1
+	for i := 0; i < count; i++ {
+		m, err := s.readMapping(r)
+		if err != nil {
+			return nil, err
+		}
+		s.mappings = append(s.mappings, m)
+	}
+
+	count = int(r.read(6)) + 1
+	for i := 0; i < count; i++ {
+		mode := vorbisMode{blockflag: r.readFlag()}
+		windowType, transformType := r.read(16), r.read(16)
+		mode.mapping = int(r.read(8))
+		if windowType != 0 || transformType != 0 || mode.mapping >= len(s.mappings) {
+			return nil, fmt.Errorf("%w: invalid mode", errCorruptStream)
+		}
+		s.modes = append(s.modes, mode)
+	}
+
+	if !r.readFlag() || r.eop {
+		return nil, fmt.Errorf("%w: truncated setup header", errCorruptStream)
+	}
+	return s, nil
+}
+
+func (s *vorbisSetup) validBook(n int) bool {
+	return n >= 0 && n < len(s.codebooks)
+}
+
+func (s *vorbisSetup) readFloor1(r *bitReader) (*floor1, error) {
+	f := &floor1{}
+	partitions := int(r.read(5))
+	maxClass := -1
+	f.partitionClass = make([]int, partitions)
+	for i := range f.partitionClass {
+		f.partitionClass[i] = int(r.read(4))
+		maxClass = max(maxClass, f.partitionClass[i])
+	}
+
+	classes := maxClass + 1
+	f.classDimensions = make([]int, classes)
+	f.classSubclasses = make([]int, classes)
+	f.classMasterbook = make([]int, classes)
+	f.subclassBooks = make([][]int, classes)
+	for i := 0; i < classes; i++ {
+		f.classDimensions[i] = int(r.read(3)) + 1
+		f.classSubclasses[i] = int(r.read(2))
+		if f.classSubclasses[i] != 0 {
+			f.classMasterbook[i] = int(r.read(8))
+			if !s.validBook(f.classMasterbook[i]) {
+				return nil, fmt.Errorf("%w: floor masterbook out of range", errCorruptStream)
+			}
+		}
+		books := make([]int, 1<<f.classSubclasses[i])
+		for j := range books {
+			books[j] = int(r.read(8)) - 1
+			if books[j] >= len(s.codebooks) {
+				return nil, fmt.Errorf("%w: floor subclass book out of range", errCorruptStream)
+			}
+		}
+		f.subclassBooks[i] = books
+	}
+
+	f.multiplier = int(r.read(2)) + 1
+	rangeBits := int(r.read(4))
+	f.xList = []int{0, 1 << rangeBits}
+	for _, class := range f.partitionClass {
+		for j := 0; j < f.classDimensions[class]; j++ {
+			f.xList = append(f.xList, int(r.read(rangeBits)))
+		}
+	}
+	if len(f.xList) > 65 {
+		return nil, fmt.Errorf("%w: too many floor posts", errCorruptStream)
+	}
+
+	f.sorted = make([]int, len(f.xList))
+	for i := range f.sorted {
+		f.sorted[i] = i
+	}
+	for i := 1; i < len(f.sorted); i++ {
+		for j := i; j > 0 && f.xList[f.sorted[j]] < f.xList[f.sorted[j-1]]; j-- {
+			f.sorted[j], f.sorted[j-1] = f.sorted[j-1], f.sorted[j]
+		}
+	}
+
+	f.lowNeighbor = make([]int, len(f.xList))
+	f.highNeighbor = make([]int, len(f.xList))
+	for i := 2; i < len(f.xList); i++ {
+		low, high := 0, 1
+		for j := 0; j < i; j++ {
+			x := f.xList[j]
+			if x < f.xList[i] && x > f.xList[low] {
+				low = j
+			}
+			if x > f.xList[i] && x < f.xList[high] {
+				high = j
+			}
+		}
+		f.lowNeighbor[i] = low
+		f.highNeighbor[i] = high
+	}
+	return f, nil
+}
+
+func (s *vorbisSetup) readResidue(r *bitReader) (*residue, error) {
+	res := &residue{kind: int(r.read(16))}
+	if res.kind > 2 {
+		return nil, fmt.Errorf("%w: residue type %d", errCorruptStream, res.kind)
+	}
+	res.begin = int(r.read(24))
+	res.end = int(r.read(24))
+	res.partitionSize = int(r.read(24)) + 1
+	res.classifications = int(r.read(6)) + 1
+	res.classbook = int(r.read(8))
+	if !s.validBook(res.classbook) {
+		return nil, fmt.Errorf("%w: residue classbook out of range", errCorruptStream)
+	}
+
+	cascade := make([]uint32, res.classifications)
+	for i := range cascade {
+		low := r.read(3)
+		var high uint32
+		if r.readFlag() {
+			high = r.read(5)
+		}
+		cascade[i] = high<<3 | low
+	}
+	res.books = make([][8]int, res.classifications)
+	for i := range res.books {
+		for j := 0; j < 8; j++ {
+			res.books[i][j] = -1
+			if cascade[i]&(1<<j) != 0 {
+				res.books[i][j] = int(r.read(8))
+				if !s.validBook(res.books[i][j]) || s.codebooks[res.books[i][j]].vectors == nil {
+					return nil, fmt.Errorf("%w: residue book out of range", errCorruptStream)
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+func (s *vorbisSetup) readMapping(r *bitReader) (*mapping, error) {
+	if kind := r.read(16); kind != 0 {
+		return nil, fmt.Errorf("%w: mapping type %d", errCorruptStream, kind)
+	}
+	m := &mapping{mux: make([]int, s.channels)}
+	submaps := 1
+	if r.readFlag() {
+		submaps = int(r.read(4)) + 1
+	}
+	if r.readFlag() {
+		steps := int(r.read(8)) + 1
+		bits := ilog(s.channels - 1)
+		for i := 0; i < steps; i++ {
+			step := couplingStep{magnitude: int(r.read(bits)), angle: int(r.read(bits))}
+			if step.magnitude == step.angle || step.magnitude >= s.channels || step.angle >= s.channels {
+				return nil, fmt.Errorf("%w: invalid channel coupling", errCorruptStream)
+			}
+			m.coupling = append(m.coupling, step)
+		}
+	}
+	if r.read(2) != 0 {
+		return nil, fmt.Errorf("%w: reserved mapping bits set", errCorruptStream)
+	}
+	if submaps > 1 {
+		for i := range m.mux {
+			m.mux[i] = int(r.read(4))
+			if m.mux[i] >= submaps {
+				return nil, fmt.Errorf("%w: channel mux out of range", errCorruptStream)
+			}
+		}
+	}
+	for i := 0; i < submaps; i++ {
+		r.read(8) // unused time configuration
+		floor, res := int(r.read(8)), int(r.read(8))
+		if floor >= len(s.floors) || res >= len(s.residues) {
+			return nil, fmt.Errorf("%w: submap out of range", errCorruptStream)
+		}
+		m.submapFloor = append(m.submapFloor, floor)
+		m.submapResidue = append(m.submapResidue, res)
+	}
+	return m, nil
+}
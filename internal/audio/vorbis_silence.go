@@ -0,0 +1,85 @@
+package audio
+
+// bitWriter packs values least significant bit first, the inverse of
+// bitReader.
+type bitWriter struct {
+	data []byte
+	pos  int
+}
+
+func (w *bitWriter) write(v uint32, n int) {
+	for i := 0; i < n; i++ {
+		if w.pos%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if v&(1<<i) != 0 {
+			w.data[w.pos>>3] |= 1 << (w.pos & 7)
+		}
+		w.pos++
+	}
+}
+
+// isLongBlock reports whether an audio packet uses a long block.
+func (s *vorbisSetup) isLongBlock(packet []byte) bool {
+	r := newBitReader(packet)
+	if r.readFlag() {
+		return false
+	}
+	mode := int(r.read(ilog(len(s.modes) - 1)))
+	return mode < len(s.modes) && s.modes[mode].blockflag
+}
+
+// silenceMode picks the mode silent packets are coded in, preferring long
+// blocks so the fewest packets are needed.
+func (s *vorbisSetup) silenceMode() int {
+	for i, mode := range s.modes {
+		if mode.blockflag {
+			return i
+		}
+	}
+	return 0
+}
+
+// silentPackets returns audio packets that decode to at least frames samples
+// of silence, along with the exact number of samples they add. prevLong and
+// nextLong describe the blocks either side so the windows line up.
+//
+// Each packet marks every channel's floor as unused, which zeroes the
+// channel without coding any residue.
+func (s *vorbisSetup) silentPackets(frames int, prevLong, nextLong bool) ([][]byte, int64) {
+	modeNumber := s.silenceMode()
+	mode := s.modes[modeNumber]
+	half := s.blocksize[boolInt(mode.blockflag)] / 2
+	count := max(1, (frames+half-1)/half)
+
+	packets := make([][]byte, count)
+	for i := range packets {
+		w := &bitWriter{}
+		w.write(0, 1)
+		w.write(uint32(modeNumber), ilog(len(s.modes)-1))
+		if mode.blockflag {
+			w.write(uint32(boolInt(i > 0 || prevLong)), 1)
+			w.write(uint32(boolInt(i < count-1 || nextLong)), 1)
+		}
+		for ch := 0; ch < s.channels; ch++ {
+			w.write(0, 1)
+		}
+		packets[i] = w.data
+	}
+	return packets, int64(count * half)
+}
+
+// packetsPage wraps whole packets in a single Ogg page.
+func packetsPage(packets [][]byte, serialNumber uint32, granulePos int64) oggPage {
+	page := oggPage{serialNumber: serialNumber, granulePos: granulePos}
+	for _, packet := range packets {
+		for len(packet) >= 255 {
+			page.segmentTable = append(page.segmentTable, 255)
+			page.data = append(page.data, packet[:255]...)
+			packet = packet[255:]
+		}
+		page.segmentTable = append(page.segmentTable, byte(len(packet)))
+		page.data = append(page.data, packet...)
+	}
+	return page
+}
@@ -0,0 +1,106 @@
+package audio
+
+import "testing"
+
+// blockFlags reads an audio packet's block size and, for a long block, the
+// sizes it expects either side.
+func blockFlags(s *vorbisSetup, packet []byte) (long, prevLong, nextLong bool) {
+	r := newBitReader(packet)
+	r.readFlag()
+	mode := s.modes[r.read(ilog(len(s.modes)-1))]
+	if !mode.blockflag {
+		return false, false, false
+	}
+	return true, r.readFlag(), r.readFlag()
+}
+
+// testClipPacket returns an audio packet of the test clip with the given
+// block size.
+func testClipPacket(t *testing.T, stream *vorbisStream, long bool) []byte {
+	t.Helper()
+	for _, packet := range stream.audio[1:] {
+		if stream.setup.isLongBlock(packet) == long {
+			return packet
+		}
+	}
+	t.Fatalf("test clip has no packet with long=%v", long)
+	return nil
+}
+
+// decodedFrames decodes packets in order and returns the number of frames
+// each one completes.
+func decodedFrames(t *testing.T, setup *vorbisSetup, packets [][]byte) []int {
+	t.Helper()
+	d := newVorbisDecoder(setup)
+	frames := make([]int, len(packets))
+	for i, packet := range packets {
+		pcm, err := d.decodePacket(packet)
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		frames[i] = len(pcm[0])
+	}
+	return frames
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+func TestSilentPackets(t *testing.T) {
+	stream := testClipStream(t)
+	setup := stream.setup
+	lead := stream.audio[0]
+
+	const requested = 3000
+	for _, tc := range []struct{ prevLong, nextLong bool }{
+		{false, false}, {false, true}, {true, false}, {true, true},
+	} {
+		prev := testClipPacket(t, stream, tc.prevLong)
+		next := testClipPacket(t, stream, tc.nextLong)
+		silence, frames := setup.silentPackets(requested, tc.prevLong, tc.nextLong)
+		if frames < requested {
+			t.Errorf("%+v: claims %d frames, fewer than the %d requested", tc, frames, requested)
+		}
+
+		if _, p, _ := blockFlags(setup, silence[0]); p != tc.prevLong {
+			t.Errorf("%+v: first silent packet expects a long previous block: %v", tc, p)
+		}
+		if _, _, n := blockFlags(setup, silence[len(silence)-1]); n != tc.nextLong {
+			t.Errorf("%+v: last silent packet expects a long next block: %v", tc, n)
+		}
+
+		without := sum(decodedFrames(t, setup, [][]byte{lead, prev, next}))
+		packets := append([][]byte{lead, prev}, silence...)
+		with := decodedFrames(t, setup, append(packets, next))
+		if added := int64(sum(with) - without); added != frames {
+			t.Errorf("%+v: silence adds %d frames, claims %d", tc, added, frames)
+		}
+	}
+}
+
+func TestSilentPackets_Zero(t *testing.T) {
+	stream := testClipStream(t)
+	silence, _ := stream.setup.silentPackets(5000, true, true)
+	if len(silence) < 3 {
+		t.Fatalf("expected several packets, got %d", len(silence))
+	}
+
+	// Between the first and last silent blocks only silence overlaps.
+	d := newVorbisDecoder(stream.setup)
+	for i, packet := range silence {
+		pcm, err := d.decodePacket(packet)
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		for _, v := range pcm[0] {
+			if v != 0 {
+				t.Fatalf("packet %d decodes to %f, want silence", i, v)
+			}
+		}
+	}
+}
@@ -38,9 +38,14 @@ func (s *Service) ogImage(ctx *fiber.Ctx) error {
 		})
 	}
 
-	lang := ctx.Query("lang", "en")
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-	q := s.QuoteService.GetByAudioID(lang, audioId)
+	q := s.quotes(ctx).GetByAudioID(lang, audioId)
 	if q == nil {
 		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "quote not found",
@@ -106,7 +111,7 @@ type builderSegmentMeta struct {
 	Text      string
 }
 
-func (s *Service) parseBuilderSegments(param, lang string) []builderSegmentMeta {
+func (s *Service) parseBuilderSegments(ctx *fiber.Ctx, param, lang string) []builderSegmentMeta {
 	parts := strings.Split(param, ",")
 	if len(parts) > 20 {
 		parts = parts[:20]
@@ -125,7 +130,7 @@ func (s *Service) parseBuilderSegments(param, lang string) []builderSegmentMeta
 			continue
 		}
 
-		q := s.QuoteService.GetByAudioID(lang, audioId)
+		q := s.quotes(ctx).GetByAudioID(lang, audioId)
 		if q != nil {
 			clipText := q.Text
 			if q.AudioTextMap != nil {
@@ -159,12 +164,17 @@ func (s *Service) ogPage(ctx *fiber.Ctx) error {
 		return ctx.SendString(html)
 	}
 
-	lang := ctx.Query("lang", "en")
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		html := s.replaceOGPlaceholders(defaultOGTitle, defaultOGDescription, defaultTwitterDesc, defaultOGImage)
+		ctx.Set("Content-Type", "text/html; charset=utf-8")
+		return ctx.SendString(html)
+	}
 	base := s.baseURL(ctx)
 
 	// Handle builder links
 	if builderParam != "" {
-		segments := s.parseBuilderSegments(builderParam, lang)
+		segments := s.parseBuilderSegments(ctx, builderParam, lang)
 		if len(segments) == 0 {
 			html := s.replaceOGPlaceholders(defaultOGTitle, defaultOGDescription, defaultTwitterDesc, defaultOGImage)
 			ctx.Set("Content-Type", "text/html; charset=utf-8")
@@ -208,7 +218,7 @@ func (s *Service) ogPage(ctx *fiber.Ctx) error {
 	}
 
 	// Handle single quote links
-	q := s.QuoteService.GetByAudioID(lang, audioId)
+	q := s.quotes(ctx).GetByAudioID(lang, audioId)
 	if q == nil {
 		html := s.replaceOGPlaceholders(defaultOGTitle, defaultOGDescription, defaultTwitterDesc, defaultOGImage)
 		ctx.Set("Content-Type", "text/html; charset=utf-8")
@@ -235,8 +245,13 @@ func (s *Service) ogBuilderImage(ctx *fiber.Ctx) error {
 		})
 	}
 
-	lang := ctx.Query("lang", "en")
-	segments := s.parseBuilderSegments(segmentsParam, lang)
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	segments := s.parseBuilderSegments(ctx, segmentsParam, lang)
 	if len(segments) == 0 {
 		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "no valid segments found",
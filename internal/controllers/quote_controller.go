@@ -1,9 +1,15 @@
 package controllers
 
 import (
-	"os"
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"umineko_quote/internal/audio"
 	"umineko_quote/internal/quote"
@@ -14,15 +20,26 @@ import (
 
 var audioIdPattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
 
+const (
+	maxCombinedGapMs       = 10000
+	maxCombinedCrossfadeMs = 1000
+	maxCombinedDurationMs  = 120000
+)
+
 func (s *Service) getAllQuoteRoutes() []FSetupRoute {
 	return []FSetupRoute{
 		s.setupSearchRoute,
 		s.setupRandomRoute,
 		s.setupBrowseRoute,
+		s.setupExportRoute,
 		s.setupByCharacterRoute,
 		s.setupByAudioIDRoute,
 		s.setupContextRoute,
+		s.setupParallelRoute,
+		s.setupScenesRoute,
+		s.setupSceneRoute,
 		s.setupCharactersRoute,
+		s.setupLanguagesRoute,
 		s.setupCombinedAudioRoute,
 		s.setupAudioRoute,
 		s.setupStatsRoute,
@@ -41,6 +58,10 @@ func (s *Service) setupBrowseRoute(routeGroup fiber.Router) {
 	routeGroup.Get("/browse", s.browse)
 }
 
+func (s *Service) setupExportRoute(routeGroup fiber.Router) {
+	routeGroup.Get("/export", s.export)
+}
+
 func (s *Service) setupByCharacterRoute(routeGroup fiber.Router) {
 	routeGroup.Get("/character/:id", s.byCharacter)
 }
@@ -53,6 +74,21 @@ func (s *Service) setupCharactersRoute(routeGroup fiber.Router) {
 	routeGroup.Get("/characters", s.characters)
 }
 
+func (s *Service) setupLanguagesRoute(routeGroup fiber.Router) {
+	routeGroup.Get("/languages", s.languages)
+}
+
+// queryLang reads the lang query parameter, the first loaded language by
+// default, and checks it is one of the loaded languages.
+func (s *Service) queryLang(ctx *fiber.Ctx) (string, error) {
+	quotes := s.quotes(ctx)
+	lang := ctx.Query("lang", quotes.DefaultLanguage())
+	if !quotes.HasLanguage(lang) {
+		return "", fmt.Errorf("unknown language '%s'", lang)
+	}
+	return lang, nil
+}
+
 func (s *Service) search(ctx *fiber.Ctx) error {
 	query := ctx.Query("q")
 	if query == "" {
@@ -61,29 +97,63 @@ func (s *Service) search(ctx *fiber.Ctx) error {
 		})
 	}
 
-	lang := ctx.Query("lang", "en")
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	limit := ctx.QueryInt("limit", 30)
 	offset := ctx.QueryInt("offset", 0)
 	characterID := ctx.Query("character")
 	episode := ctx.QueryInt("episode", 0)
 	truth := quote.TruthAll.Parse(ctx.Query("truth"))
+	bgm := ctx.Query("bgm")
+	var present []string
+	if p := ctx.Query("present"); p != "" {
+		present = strings.Split(p, ",")
+	}
+	mode := quote.SearchModeExact.Parse(ctx.Query("mode"))
+
+	var response quote.SearchResponse
+	if ctx.QueryBool("regex") {
+		var err error
+		response, err = s.quotes(ctx).SearchRegex(query, lang, limit, offset, characterID, episode, truth, bgm, present, ctx.Query("field") == "html")
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	} else {
+		response = s.quotes(ctx).Search(query, lang, limit, offset, characterID, episode, truth, bgm, present, mode)
+	}
+
+	if ctx.QueryBool("parallel") {
+		response.Results = s.quotes(ctx).WithParallel(lang, response.Results)
+	}
 
-	response := s.QuoteService.Search(query, lang, limit, offset, characterID, episode, truth)
 	return ctx.JSON(fiber.Map{
-		"query":   query,
-		"results": response.Results,
-		"total":   response.Total,
-		"limit":   response.Limit,
-		"offset":  response.Offset,
+		"query":       query,
+		"results":     response.Results,
+		"total":       response.Total,
+		"limit":       response.Limit,
+		"offset":      response.Offset,
+		"suggestions": response.Suggestions,
+		"truncated":   response.Truncated,
 	})
 }
 
 func (s *Service) random(ctx *fiber.Ctx) error {
-	lang := ctx.Query("lang", "en")
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	characterID := ctx.Query("character")
 	episode := ctx.QueryInt("episode", 0)
 	truth := quote.TruthAll.Parse(ctx.Query("truth"))
-	q := s.QuoteService.Random(lang, characterID, episode, truth)
+	q := s.quotes(ctx).Random(lang, characterID, episode, truth)
 	if q == nil {
 		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "no quotes available",
@@ -93,34 +163,78 @@ func (s *Service) random(ctx *fiber.Ctx) error {
 }
 
 func (s *Service) browse(ctx *fiber.Ctx) error {
-	lang := ctx.Query("lang", "en")
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	characterID := ctx.Query("character")
 	limit := ctx.QueryInt("limit", 50)
 	offset := ctx.QueryInt("offset", 0)
 	episode := ctx.QueryInt("episode", 0)
 	truth := quote.TruthAll.Parse(ctx.Query("truth"))
 
-	response := s.QuoteService.Browse(lang, characterID, limit, offset, episode, truth)
+	response := s.quotes(ctx).Browse(lang, characterID, limit, offset, episode, truth)
 	return ctx.JSON(response)
 }
 
+// export streams every quote matching the browse filters as a file.
+func (s *Service) export(ctx *fiber.Ctx) error {
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	format, err := quote.ParseExportFormat(ctx.Query("format", string(quote.ExportJSONL)))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	characterID := ctx.Query("character")
+	episode := ctx.QueryInt("episode", 0)
+	truth := quote.TruthAll.Parse(ctx.Query("truth"))
+
+	quotes := s.quotes(ctx).Browse(lang, characterID, math.MaxInt, 0, episode, truth).Quotes
+	ctx.Attachment(fmt.Sprintf("umineko-%s.%s", lang, format.Extension()))
+	ctx.Set(fiber.HeaderContentType, format.ContentType())
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := quote.WriteExport(w, format, quotes); err != nil {
+			log.Printf("[export] %v", err)
+		}
+	})
+	return nil
+}
+
 func (s *Service) byCharacter(ctx *fiber.Ctx) error {
-	lang := ctx.Query("lang", "en")
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	characterID := ctx.Params("id")
 	limit := ctx.QueryInt("limit", 50)
 	offset := ctx.QueryInt("offset", 0)
 	episode := ctx.QueryInt("episode", 0)
 	truth := quote.TruthAll.Parse(ctx.Query("truth"))
 
-	response := s.QuoteService.GetByCharacter(lang, characterID, limit, offset, episode, truth)
+	response := s.quotes(ctx).GetByCharacter(lang, characterID, limit, offset, episode, truth)
 	return ctx.JSON(response)
 }
 
 func (s *Service) byAudioID(ctx *fiber.Ctx) error {
-	lang := ctx.Query("lang", "en")
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	audioID := ctx.Params("audioId")
 
-	quote := s.QuoteService.GetByAudioID(lang, audioID)
+	quote := s.quotes(ctx).GetByAudioID(lang, audioID)
 	if quote == nil {
 		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "quote not found",
@@ -134,7 +248,12 @@ func (s *Service) setupContextRoute(routeGroup fiber.Router) {
 }
 
 func (s *Service) context(ctx *fiber.Ctx) error {
-	lang := ctx.Query("lang", "en")
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	audioID := ctx.Params("audioId")
 	if !audioIdPattern.MatchString(audioID) {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -143,7 +262,28 @@ func (s *Service) context(ctx *fiber.Ctx) error {
 	}
 
 	lines := ctx.QueryInt("lines", 5)
-	result := s.QuoteService.GetContext(lang, audioID, lines)
+	result := s.quotes(ctx).GetContext(lang, audioID, lines)
+	if result == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "quote not found",
+		})
+	}
+	return ctx.JSON(result)
+}
+
+func (s *Service) setupParallelRoute(routeGroup fiber.Router) {
+	routeGroup.Get("/parallel/:audioId", s.parallel)
+}
+
+func (s *Service) parallel(ctx *fiber.Ctx) error {
+	audioID := ctx.Params("audioId")
+	if !audioIdPattern.MatchString(audioID) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid audio ID",
+		})
+	}
+
+	result := s.quotes(ctx).GetParallel(audioID)
 	if result == nil {
 		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "quote not found",
@@ -152,8 +292,54 @@ func (s *Service) context(ctx *fiber.Ctx) error {
 	return ctx.JSON(result)
 }
 
+func (s *Service) setupScenesRoute(routeGroup fiber.Router) {
+	routeGroup.Get("/scenes", s.scenes)
+}
+
+func (s *Service) setupSceneRoute(routeGroup fiber.Router) {
+	routeGroup.Get("/scene/:id", s.scene)
+}
+
+func (s *Service) scenes(ctx *fiber.Ctx) error {
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	episode := ctx.QueryInt("episode", 0)
+	return ctx.JSON(s.quotes(ctx).GetScenes(lang, episode))
+}
+
+func (s *Service) scene(ctx *fiber.Ctx) error {
+	lang, err := s.queryLang(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	sceneID := ctx.Params("id")
+	if !audioIdPattern.MatchString(sceneID) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid scene ID",
+		})
+	}
+
+	result := s.quotes(ctx).GetScene(lang, sceneID)
+	if result == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "scene not found",
+		})
+	}
+	return ctx.JSON(result)
+}
+
 func (s *Service) characters(ctx *fiber.Ctx) error {
-	return ctx.JSON(s.QuoteService.GetCharacters())
+	return ctx.JSON(s.quotes(ctx).GetCharacters())
+}
+
+func (s *Service) languages(ctx *fiber.Ctx) error {
+	return ctx.JSON(s.quotes(ctx).Languages())
 }
 
 func (s *Service) setupStatsRoute(routeGroup fiber.Router) {
@@ -162,16 +348,19 @@ func (s *Service) setupStatsRoute(routeGroup fiber.Router) {
 
 func (s *Service) stats(ctx *fiber.Ctx) error {
 	episode := ctx.QueryInt("episode", 0)
-	return ctx.JSON(s.QuoteService.GetStats().Compute(episode))
+	return ctx.JSON(s.quotes(ctx).GetStats().Compute(episode))
 }
 
 func (s *Service) setupCombinedAudioRoute(routeGroup fiber.Router) {
 	routeGroup.Get("/audio/combined", s.combinedAudioSegments)
+	routeGroup.Get("/audio/combined.vtt", s.combinedSubtitles(audio.SubtitlesVTT))
+	routeGroup.Get("/audio/combined.srt", s.combinedSubtitles(audio.SubtitlesSRT))
 	routeGroup.Get("/audio/:charId/combined", s.combinedAudioLegacy)
 }
 
 func (s *Service) setupAudioRoute(routeGroup fiber.Router) {
 	routeGroup.Get("/audio/:charId/:audioId", s.audio)
+	routeGroup.Get("/audio/:charId/:audioId/meta", s.audioMeta)
 }
 
 func (s *Service) audio(ctx *fiber.Ctx) error {
@@ -183,65 +372,374 @@ func (s *Service) audio(ctx *fiber.Ctx) error {
 		})
 	}
 
-	filePath := s.QuoteService.AudioFilePath(charId, audioId)
-	if filePath == "" {
+	format, err := audio.ParseFormat(ctx.Query("format"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	clip, err := s.quotes(ctx).OpenAudio(charId, audioId)
+	if errors.Is(err, audio.ErrAudioNotFound) {
 		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "audio file not found",
 		})
 	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to read audio file",
+		})
+	}
 
-	data, err := os.ReadFile(filePath)
+	if format == audio.FormatOgg {
+		return utils.ServeAudioSource(ctx, clip, audio.ContentTypeOgg)
+	}
+
+	key := "clip:" + charId + "/" + audioId + "@" + clipVersion(clip) + ":" + string(format)
+	if f, contentType, ok := s.AudioCache.Open(key); ok {
+		clip.Close()
+		return utils.ServeCachedAudio(ctx, f, contentType, key)
+	}
+
+	data, err := audio.ReadClip(clip)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to read audio file",
 		})
 	}
+	data, err = audio.Transcode(data, format)
+	if err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return s.serveAndCache(ctx, key, data, format.ContentType())
+}
+
+// clipVersion identifies the version of a clip in the store, so audio built
+// from it is cached apart from audio built from a replaced clip.
+func clipVersion(clip audio.Clip) string {
+	return fmt.Sprintf("%d.%d", clip.Size(), clip.ModTime().UnixNano())
+}
+
+// serveAndCache stores freshly built audio in the cache and serves it from
+// there, so the first response carries the same validators as later ones.
+// Audio the cache turns away is served from memory.
+func (s *Service) serveAndCache(ctx *fiber.Ctx, key string, data []byte, contentType string) error {
+	s.AudioCache.Put(key, data, contentType)
+	if f, cachedType, ok := s.AudioCache.Open(key); ok {
+		return utils.ServeCachedAudio(ctx, f, cachedType, key)
+	}
+	return utils.ServeAudioAs(ctx, data, contentType)
+}
+
+func (s *Service) audioMeta(ctx *fiber.Ctx) error {
+	charId := ctx.Params("charId")
+	audioId := ctx.Params("audioId")
+	if !audioIdPattern.MatchString(charId) || !audioIdPattern.MatchString(audioId) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid audio ID",
+		})
+	}
+
+	meta := s.quotes(ctx).GetAudioMeta(charId, audioId)
+	if meta == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "audio metadata not found",
+		})
+	}
 
-	return utils.ServeAudio(ctx, data)
+	return ctx.JSON(meta)
 }
 
 func (s *Service) combinedAudioSegments(ctx *fiber.Ctx) error {
-	segmentsParam := ctx.Query("segments")
-	if segmentsParam == "" {
+	segments, crossfade, err := parseCombinedSegments(ctx)
+	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "query parameter 'segments' is required",
+			"error": err.Error(),
 		})
 	}
+	return s.serveCombinedAudio(ctx, segments, crossfade)
+}
+
+// parseCombinedSegments reads the segments query parameter, a comma
+// separated list of charId:audioId or charId:audioId:gapMs, along with the
+// gap and crossfade parameters.
+func parseCombinedSegments(ctx *fiber.Ctx) ([]audio.AudioSegment, time.Duration, error) {
+	segmentsParam := ctx.Query("segments")
+	if segmentsParam == "" {
+		return nil, 0, errors.New("query parameter 'segments' is required")
+	}
 
 	parts := strings.Split(segmentsParam, ",")
 	if len(parts) > 20 {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "maximum 20 audio segments allowed",
-		})
+		return nil, 0, errors.New("maximum 20 audio segments allowed")
+	}
+
+	gap, crossfade, err := combinedAudioTiming(ctx)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	segments := make([]audio.AudioSegment, 0, len(parts))
 	for _, part := range parts {
 		part = strings.TrimSpace(part)
-		colonIdx := strings.IndexByte(part, ':')
-		if colonIdx < 1 || colonIdx >= len(part)-1 {
+		fields := strings.Split(part, ":")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, 0, errors.New("invalid segment format: " + part + " (expected charId:audioId or charId:audioId:gapMs)")
+		}
+		charId := fields[0]
+		audioId := fields[1]
+		if !audioIdPattern.MatchString(charId) || !audioIdPattern.MatchString(audioId) {
+			return nil, 0, errors.New("invalid segment: " + part)
+		}
+		segmentGap := gap
+		if len(fields) == 3 {
+			ms, err := strconv.Atoi(fields[2])
+			if err != nil || ms < 0 || ms > maxCombinedGapMs {
+				return nil, 0, errors.New("invalid gap in segment: " + part)
+			}
+			segmentGap = time.Duration(ms) * time.Millisecond
+		}
+		segments = append(segments, audio.AudioSegment{CharID: charId, AudioID: audioId, Gap: segmentGap})
+	}
+	return segments, crossfade, nil
+}
+
+// combinedAudioTiming reads the gap and crossfade query parameters, both in
+// milliseconds.
+func combinedAudioTiming(ctx *fiber.Ctx) (time.Duration, time.Duration, error) {
+	gap := ctx.QueryInt("gap", 0)
+	if gap < 0 || gap > maxCombinedGapMs {
+		return 0, 0, fmt.Errorf("gap must be between 0 and %d ms", maxCombinedGapMs)
+	}
+	crossfade := ctx.QueryInt("crossfade", 0)
+	if crossfade < 0 || crossfade > maxCombinedCrossfadeMs {
+		return 0, 0, fmt.Errorf("crossfade must be between 0 and %d ms", maxCombinedCrossfadeMs)
+	}
+	return time.Duration(gap) * time.Millisecond, time.Duration(crossfade) * time.Millisecond, nil
+}
+
+// clipDurations looks up each segment's duration in the clip metadata, and
+// reads it from the open clip for one the metadata lacks, such as a clip
+// added since the store was scanned.
+func (s *Service) clipDurations(ctx *fiber.Ctx, segments []audio.AudioSegment, clips openClips) ([]time.Duration, error) {
+	durations := make([]time.Duration, len(segments))
+	for i, seg := range segments {
+		if meta := s.quotes(ctx).GetAudioMeta(seg.CharID, seg.AudioID); meta != nil {
+			durations[i] = time.Duration(meta.DurationMs) * time.Millisecond
+			continue
+		}
+		clip, err := clips.open(seg.CharID, seg.AudioID)
+		if err != nil {
+			return nil, err
+		}
+		meta, err := audio.ReadMeta(clip)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", seg.CharID, seg.AudioID, err)
+		}
+		durations[i] = meta.Duration
+	}
+	return durations, nil
+}
+
+// combinedDuration estimates the length of the combined clip from the clip
+// durations, the gaps and the crossfades.
+func (s *Service) combinedDuration(ctx *fiber.Ctx, segments []audio.AudioSegment, clips openClips, crossfade time.Duration) (time.Duration, error) {
+	durations, err := s.clipDurations(ctx, segments, clips)
+	if err != nil {
+		return 0, err
+	}
+	spans := audio.EstimateTimeline(segments, durations, crossfade)
+	return spans[len(spans)-1].End, nil
+}
+
+// combinedSubtitles serves subtitles timed to the combined audio built from
+// the same query, one cue per segment, with the text from the quote's
+// AudioTextMap when it has one. The cues follow the samples the combiner
+// emits, so the clips are read as they would be to combine them.
+func (s *Service) combinedSubtitles(format audio.SubtitleFormat) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		segments, crossfade, err := parseCombinedSegments(ctx)
+		if err != nil {
 			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "invalid segment format: " + part + " (expected charId:audioId)",
+				"error": err.Error(),
 			})
 		}
-		charId := part[:colonIdx]
-		audioId := part[colonIdx+1:]
-		if !audioIdPattern.MatchString(charId) || !audioIdPattern.MatchString(audioId) {
+		lang, err := s.queryLang(ctx)
+		if err != nil {
 			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "invalid segment: " + part,
+				"error": err.Error(),
 			})
 		}
-		segments = append(segments, audio.AudioSegment{CharID: charId, AudioID: audioId})
+
+		clips, err := s.openSegments(ctx, segments)
+		if err != nil {
+			return combinedAudioError(ctx, err)
+		}
+		defer clips.close()
+		spans, err := s.AudioCombiner.Timeline(segments, clips.open, combineOptions(ctx, crossfade))
+		if err != nil {
+			return combinedAudioError(ctx, err)
+		}
+
+		cues := make([]audio.Cue, 0, len(segments))
+		for i, seg := range segments {
+			q := s.quotes(ctx).GetByAudioID(lang, seg.AudioID)
+			if q == nil {
+				continue
+			}
+			text := q.Text
+			if mapped, ok := q.AudioTextMap[seg.AudioID]; ok {
+				text = mapped
+			}
+			cues = append(cues, audio.Cue{Span: spans[i], Speaker: q.Character, Text: text})
+		}
+
+		ctx.Set("Content-Type", format.ContentType())
+		return ctx.Send(audio.WriteSubtitles(cues, format))
+	}
+}
+
+// combineOptions reads the options shared by combined audio and its
+// subtitles.
+func combineOptions(ctx *fiber.Ctx, crossfade time.Duration) audio.CombineOptions {
+	return audio.CombineOptions{
+		Crossfade: crossfade,
+		Normalize: ctx.QueryBool("normalize", false),
 	}
+}
 
-	data, err := s.AudioCombiner.CombineOgg(segments, s.QuoteService.AudioFilePath)
+func (s *Service) serveCombinedAudio(ctx *fiber.Ctx, segments []audio.AudioSegment, crossfade time.Duration) error {
+	maxDuration := ctx.QueryInt("maxDuration", maxCombinedDurationMs)
+	if maxDuration <= 0 || maxDuration > maxCombinedDurationMs {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("maxDuration must be between 1 and %d ms", maxCombinedDurationMs),
+		})
+	}
+	limit := time.Duration(maxDuration) * time.Millisecond
+	format, err := audio.ParseFormat(ctx.Query("format"))
 	if err != nil {
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	return utils.ServeAudio(ctx, data)
+	clips, err := s.openSegments(ctx, segments)
+	if err != nil {
+		return combinedAudioError(ctx, err)
+	}
+	defer clips.close()
+
+	total, err := s.combinedDuration(ctx, segments, clips, crossfade)
+	if err != nil {
+		return combinedAudioError(ctx, err)
+	}
+	if total > limit {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("combined audio would be %v long, over the %v limit", total.Round(time.Millisecond), limit),
+		})
+	}
+
+	// Combined output is addressed by its segment list and the versions of
+	// its clips, so replaying the same builder sequence is served straight
+	// from the cache.
+	opts := combineOptions(ctx, crossfade)
+	key := combinedCacheKey(segments, clips, opts, format)
+	if f, contentType, ok := s.AudioCache.Open(key); ok {
+		return utils.ServeCachedAudio(ctx, f, contentType, key)
+	}
+
+	data, contentType, err := s.AudioCombiner.Combine(segments, clips.open, opts)
+	if err == nil && format != audio.FormatOgg && contentType != format.ContentType() {
+		data, err = audio.Transcode(data, format)
+		contentType = format.ContentType()
+	}
+	if err != nil {
+		return combinedAudioError(ctx, err)
+	}
+
+	return s.serveAndCache(ctx, key, data, contentType)
+}
+
+func combinedAudioError(ctx *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, audio.ErrAudioNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, audio.ErrInvalidAudio), errors.Is(err, audio.ErrIncompatibleAudio):
+		status = fiber.StatusUnprocessableEntity
+	}
+	return ctx.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// openClips holds the clips of a combined request open, by
+// AudioSegment.CharID and AudioID, so the combiner reads the very versions
+// the cache key names.
+type openClips map[string]audio.Clip
+
+// openSegments opens the clip of every segment once.
+func (s *Service) openSegments(ctx *fiber.Ctx, segments []audio.AudioSegment) (openClips, error) {
+	clips := make(openClips, len(segments))
+	for _, seg := range segments {
+		name := seg.CharID + "/" + seg.AudioID
+		if _, ok := clips[name]; ok {
+			continue
+		}
+		clip, err := s.quotes(ctx).OpenAudio(seg.CharID, seg.AudioID)
+		if err != nil {
+			clips.close()
+			if errors.Is(err, audio.ErrAudioNotFound) {
+				return nil, fmt.Errorf("%w: %s", audio.ErrAudioNotFound, name)
+			}
+			return nil, fmt.Errorf("failed to read audio file: %s", seg.AudioID)
+		}
+		clips[name] = clip
+	}
+	return clips, nil
+}
+
+// open is an audio.ClipOpener over the open clips. The clips it returns stay
+// open when closed, as a clip may appear in more than one segment.
+func (c openClips) open(charID, audioID string) (audio.Clip, error) {
+	clip, ok := c[charID+"/"+audioID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s", audio.ErrAudioNotFound, charID, audioID)
+	}
+	return keptOpen{clip}, nil
+}
+
+func (c openClips) close() {
+	for _, clip := range c {
+		clip.Close()
+	}
+}
+
+type keptOpen struct {
+	audio.Clip
+}
+
+func (keptOpen) Close() error {
+	return nil
+}
+
+// combinedCacheKey identifies a combined clip by everything that shapes it.
+func combinedCacheKey(segments []audio.AudioSegment, clips openClips, opts audio.CombineOptions, format audio.Format) string {
+	var b strings.Builder
+	b.WriteString("combined:")
+	for _, seg := range segments {
+		name := seg.CharID + "/" + seg.AudioID
+		fmt.Fprintf(&b, "%s@%s+%d,", name, clipVersion(clips[name]), seg.Gap.Milliseconds())
+	}
+	fmt.Fprintf(&b, "x%d", opts.Crossfade.Milliseconds())
+	if opts.Normalize {
+		b.WriteString("n")
+	}
+	fmt.Fprintf(&b, ":%s", format)
+	return b.String()
 }
 
 func (s *Service) combinedAudioLegacy(ctx *fiber.Ctx) error {
@@ -266,6 +764,13 @@ func (s *Service) combinedAudioLegacy(ctx *fiber.Ctx) error {
 		})
 	}
 
+	gap, crossfade, err := combinedAudioTiming(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	segments := make([]audio.AudioSegment, 0, len(ids))
 	for _, id := range ids {
 		id = strings.TrimSpace(id)
@@ -274,15 +779,8 @@ func (s *Service) combinedAudioLegacy(ctx *fiber.Ctx) error {
 				"error": "invalid audio ID: " + id,
 			})
 		}
-		segments = append(segments, audio.AudioSegment{CharID: charId, AudioID: id})
-	}
-
-	data, err := s.AudioCombiner.CombineOgg(segments, s.QuoteService.AudioFilePath)
-	if err != nil {
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		segments = append(segments, audio.AudioSegment{CharID: charId, AudioID: id, Gap: gap})
 	}
 
-	return utils.ServeAudio(ctx, data)
+	return s.serveCombinedAudio(ctx, segments, crossfade)
 }
@@ -4,22 +4,45 @@ import (
 	"umineko_quote/internal/audio"
 	"umineko_quote/internal/og"
 	"umineko_quote/internal/quote"
+
+	"github.com/gofiber/fiber/v2"
 )
 
+// quoteSnapshotKey holds a request's quote snapshot in its locals.
+const quoteSnapshotKey = "quoteSnapshot"
+
 type Service struct {
-	QuoteService     quote.Service
+	QuoteService     quote.ReloadableService
 	OGImageGenerator *og.ImageGenerator
 	AudioCombiner    audio.Combiner
+	AudioCache       audio.Cache
 	HTMLContent      string
+	// AdminToken guards the admin routes, which are disabled when it is
+	// empty.
+	AdminToken string
 }
 
-func NewService(quoteService quote.Service, ogGen *og.ImageGenerator, audioCombiner audio.Combiner, htmlContent string) Service {
+func NewService(quoteService quote.ReloadableService, ogGen *og.ImageGenerator, audioCombiner audio.Combiner, audioCache audio.Cache, htmlContent string, adminToken string) Service {
 	return Service{
 		QuoteService:     quoteService,
 		OGImageGenerator: ogGen,
 		AudioCombiner:    audioCombiner,
+		AudioCache:       audioCache,
 		HTMLContent:      htmlContent,
+		AdminToken:       adminToken,
+	}
+}
+
+// quotes returns the scripts for this request. The snapshot is taken on
+// first use and kept for the rest of the request, so a reload partway
+// through cannot mix answers from two versions of the scripts.
+func (s *Service) quotes(ctx *fiber.Ctx) quote.Service {
+	if snapshot, ok := ctx.Locals(quoteSnapshotKey).(quote.Service); ok {
+		return snapshot
 	}
+	snapshot := s.QuoteService.Snapshot()
+	ctx.Locals(quoteSnapshotKey, snapshot)
+	return snapshot
 }
 
 func (s *Service) GetAPIRoutes() []FSetupRoute {
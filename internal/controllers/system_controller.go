@@ -1,11 +1,17 @@
 package controllers
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 func (s *Service) getAllSystemRoutes() []FSetupRoute {
 	return []FSetupRoute{
 		s.setupHealthRoute,
 		s.setupConfigRoute,
+		s.setupReloadRoute,
 	}
 }
 
@@ -26,6 +32,33 @@ func (s *Service) setupConfigRoute(routeGroup fiber.Router) {
 
 func (s *Service) config(ctx *fiber.Ctx) error {
 	return ctx.JSON(fiber.Map{
-		"hasAudio": s.QuoteService.HasAudio(),
+		"hasAudio": s.quotes(ctx).HasAudio(),
+	})
+}
+
+func (s *Service) setupReloadRoute(routeGroup fiber.Router) {
+	routeGroup.Post("/admin/reload", s.reload)
+}
+
+// reload reparses the scripts and swaps them in. It needs the admin token
+// as a bearer token, and does not exist when no token is configured.
+func (s *Service) reload(ctx *fiber.Ctx) error {
+	if s.AdminToken == "" {
+		return fiber.ErrNotFound
+	}
+	token, ok := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) != 1 {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid admin token",
+		})
+	}
+
+	if err := s.QuoteService.Reload(); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(fiber.Map{
+		"status": "reloaded",
 	})
 }
@@ -10,6 +10,12 @@ type (
 		Value  string
 		Line   int
 		Column int
+		// Space is the whitespace skipped just before the token in a
+		// dialogue line, which no other token holds.
+		Space string
+		// Unclosed marks a format tag or inline command that runs to the end
+		// of the line without its closing brace or bracket.
+		Unclosed bool
 	}
 )
 
@@ -177,6 +183,14 @@ type (
 		Duration  int
 		Pos       Token
 	}
+
+	// Delimiter is source in a dialogue line that is not part of the
+	// dialogue: a backtick opening or closing a run of text, or whitespace
+	// outside of one. It is kept so the line can be printed back.
+	Delimiter struct {
+		Text string
+		Pos  Token
+	}
 )
 
 // Marker methods to restrict interface implementations.
@@ -208,6 +222,8 @@ func (c *ClickWait) nodeType() string         { return "ClickWait" }
 func (c *ClickWait) dialogueElement()         {}
 func (t *TimedWait) nodeType() string         { return "TimedWait" }
 func (t *TimedWait) dialogueElement()         {}
+func (d *Delimiter) nodeType() string         { return "Delimiter" }
+func (d *Delimiter) dialogueElement()         {}
 
 // Helper methods
 
@@ -88,6 +88,7 @@ func TestNodeTypes(t *testing.T) {
 		{"VoiceCommand", &VoiceCommand{}, "VoiceCommand"},
 		{"ClickWait", &ClickWait{}, "ClickWait"},
 		{"TimedWait", &TimedWait{}, "TimedWait"},
+		{"Delimiter", &Delimiter{}, "Delimiter"},
 	}
 
 	for _, tt := range tests {
@@ -128,6 +129,7 @@ func TestDialogueElementInterface(t *testing.T) {
 		&VoiceCommand{},
 		&ClickWait{},
 		&TimedWait{},
+		&Delimiter{},
 	}
 
 	for _, e := range elements {
@@ -0,0 +1,147 @@
+package lexar
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"umineko_quote/internal/lexar/ast"
+)
+
+type (
+	// Diagnostic is a problem found while parsing a script. The script still
+	// parses, but the game or the extractor may not read the line the way
+	// its author meant.
+	Diagnostic struct {
+		Kind    DiagnosticKind
+		Message string
+		Pos     ast.Token
+	}
+
+	DiagnosticKind string
+
+	// diagnostics collects what the lexer and parser report. A nil
+	// *diagnostics discards every report, which is how Parse runs.
+	diagnostics struct {
+		list []Diagnostic
+	}
+)
+
+const (
+	// DiagnosticUnbalanced is an unclosed or unmatched brace or bracket.
+	DiagnosticUnbalanced DiagnosticKind = "unbalanced"
+	// DiagnosticUnknownTag is a format tag the game does not define.
+	DiagnosticUnknownTag DiagnosticKind = "unknown-tag"
+	// DiagnosticVoiceCommand is an lv command not of the form
+	// lv channel*"character"*"audio".
+	DiagnosticVoiceCommand DiagnosticKind = "voice-command"
+	// DiagnosticVoiceEpisode is a voice clip from another episode than the
+	// one the script is in.
+	DiagnosticVoiceEpisode DiagnosticKind = "voice-episode"
+	// DiagnosticUndefinedPreset is a {p:N:...} tag for a preset no
+	// preset_define line defines.
+	DiagnosticUndefinedPreset DiagnosticKind = "undefined-preset"
+	// DiagnosticUnknownChar is a character outside dialogue that starts no
+	// token, which the lexer skips.
+	DiagnosticUnknownChar DiagnosticKind = "unknown-char"
+)
+
+// knownFormatTags are the format tags of ONScripter-RU, by both their long
+// and short names.
+var knownFormatTags = map[string]bool{
+	"a": true, "ac": true, "b": true, "bold": true, "bolditalic": true,
+	"border": true, "bordercolor": true, "c": true, "center": true,
+	"characterspacing": true, "color": true, "colour": true, "Comment": true,
+	"d": true, "e": true, "f": true, "fit": true, "font": true,
+	"fontsize": true, "fontsizepercent": true, "g": true, "gradient": true,
+	"h": true, "i": true, "italic": true, "j": true, "l": true,
+	"loghint": true, "m": true, "n": true, "nobr": true, "nobreak": true,
+	"o": true, "p": true, "preset": true, "r": true, "ruby": true, "s": true,
+	"shadow": true, "shadowcolor": true, "u": true, "underline": true,
+	"v": true, "w": true, "width": true, "x": true, "y": true,
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos.Position(), d.Message)
+}
+
+func (d *diagnostics) report(kind DiagnosticKind, pos ast.Token, format string, args ...any) {
+	if d == nil {
+		return
+	}
+	d.list = append(d.list, Diagnostic{Kind: kind, Message: fmt.Sprintf(format, args...), Pos: pos})
+}
+
+// ParseWithDiagnostics parses input like Parse, and also returns the
+// problems found in it, ordered by position.
+func ParseWithDiagnostics(input string) (*ast.Script, []Diagnostic) {
+	diags := &diagnostics{}
+	lexer := NewLexer(input)
+	lexer.diagnostics = diags
+	parser := &Parser{tokens: lexer.Tokenize(), pos: 0, diagnostics: diags}
+	script := parser.parse()
+	checkScript(script, diags)
+
+	slices.SortStableFunc(diags.list, func(a, b Diagnostic) int {
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line - b.Pos.Line
+		}
+		return a.Pos.Column - b.Pos.Column
+	})
+	return script, diags.list
+}
+
+// checkScript reports what needs more than one line to see: voice clips
+// from another episode and presets that are never defined.
+func checkScript(script *ast.Script, diags *diagnostics) {
+	defined := make(map[int]bool)
+	for _, line := range script.Lines {
+		if preset, ok := line.(*ast.PresetDefineLine); ok {
+			defined[preset.ID] = true
+		}
+	}
+
+	// Voice IDs start with their episode's digit in the main episodes
+	// only; tea parties, ura and omake chapters use other prefixes.
+	episode := 0
+	for _, line := range script.Lines {
+		switch l := line.(type) {
+		case *ast.EpisodeMarkerLine:
+			episode = 0
+			if l.Type == "episode" {
+				episode = l.Episode
+			}
+		case *ast.LabelLine:
+			if omakeRegex.MatchString(l.Name) {
+				episode = 0
+			}
+		case *ast.DialogueLine:
+			if episode > 0 {
+				for _, v := range l.GetVoiceCommands() {
+					if v.AudioID == "" || v.AudioID[0] < '1' || v.AudioID[0] > '8' {
+						continue
+					}
+					if ep := int(v.AudioID[0] - '0'); ep != episode {
+						diags.report(DiagnosticVoiceEpisode, v.Pos, "voice %s is from episode %d, but the script is in episode %d", v.AudioID, ep, episode)
+					}
+				}
+			}
+			checkPresets(l.Content, defined, diags)
+		}
+	}
+}
+
+func checkPresets(elements []ast.DialogueElement, defined map[int]bool, diags *diagnostics) {
+	for _, elem := range elements {
+		tag, ok := elem.(*ast.FormatTag)
+		if !ok {
+			continue
+		}
+		if tag.Name == "p" || tag.Name == "preset" {
+			if id, err := strconv.Atoi(tag.Param); err != nil || !defined[id] {
+				diags.report(DiagnosticUndefinedPreset, tag.Pos, "preset '%s' is not defined by preset_define", tag.Param)
+			}
+		}
+		checkPresets(tag.Content, defined, diags)
+	}
+}
@@ -0,0 +1,107 @@
+package lexar
+
+import (
+	"strings"
+	"testing"
+
+	"umineko_quote/internal/lexar/ast"
+)
+
+const lintHeader = "preset_define 1,1,-1,#FF0000,0,0,0,1,-1,#000000,0,-1,-1,#000000,1,-1\nnew_episode 1\n"
+
+func lint(t *testing.T, input string) []Diagnostic {
+	t.Helper()
+	_, diags := ParseWithDiagnostics(lintHeader + input)
+	return diags
+}
+
+func expectDiagnostic(t *testing.T, diags []Diagnostic, kind DiagnosticKind, position string, message string) {
+	t.Helper()
+	for _, d := range diags {
+		if d.Kind == kind && d.Pos.Position() == position && strings.Contains(d.Message, message) {
+			return
+		}
+	}
+	t.Errorf("expected %s at %s containing %q, got %v", kind, position, message, diags)
+}
+
+func TestParseWithDiagnostics_Clean(t *testing.T) {
+	diags := lint(t, "*umi1_1\nd [lv 0*\"10\"*\"10100001\"]`\"{p:1:Red truth}{n}{i:and {c:FF0000:colour}}.\"`[\\]\nd `Narration{qt}{os}1{es}.`[@]\n")
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestParseWithDiagnostics_Unbalanced(t *testing.T) {
+	diags := lint(t, "d `Unclosed {i:tag`[\\]\nd `Stray} brace`[\\]\nd `Unclosed`[lv 0*\"10\"\n")
+	expectDiagnostic(t, diags, DiagnosticUnbalanced, "3:13", "unclosed '{'")
+	expectDiagnostic(t, diags, DiagnosticUnbalanced, "4:9", "unmatched '}'")
+	expectDiagnostic(t, diags, DiagnosticUnbalanced, "5:13", "unclosed '['")
+}
+
+func TestParseWithDiagnostics_NestedPositions(t *testing.T) {
+	diags := lint(t, "d `{i:one {zz:two}]}`[\\]\n")
+	expectDiagnostic(t, diags, DiagnosticUnknownTag, "3:11", "'zz'")
+	expectDiagnostic(t, diags, DiagnosticUnbalanced, "3:19", "unmatched ']'")
+}
+
+func TestParseWithDiagnostics_UnknownTag(t *testing.T) {
+	diags := lint(t, "d `{bogus:text} and {}`[\\]\n")
+	expectDiagnostic(t, diags, DiagnosticUnknownTag, "3:4", "'bogus'")
+	expectDiagnostic(t, diags, DiagnosticUnknownTag, "3:21", "''")
+}
+
+func TestParseWithDiagnostics_VoiceCommand(t *testing.T) {
+	diags := lint(t, "d [lv 0*\"10\"]`a`\nd [lv x*\"10\"*\"10100001\"]`b`\nd [lv 0*10*\"10100001\"]`c`\nd [lv 0*\"10\"*\"\"]`d`\n")
+	expectDiagnostic(t, diags, DiagnosticVoiceCommand, "3:3", "is not lv channel")
+	expectDiagnostic(t, diags, DiagnosticVoiceCommand, "4:3", "channel 'x'")
+	expectDiagnostic(t, diags, DiagnosticVoiceCommand, "5:3", "character ID 10")
+	expectDiagnostic(t, diags, DiagnosticVoiceCommand, "6:3", `audio ID ""`)
+	if len(diags) != 4 {
+		t.Errorf("expected 4 diagnostics, got %v", diags)
+	}
+}
+
+func TestParseWithDiagnostics_VoiceEpisode(t *testing.T) {
+	diags := lint(t, "d [lv 0*\"10\"*\"20100001\"]`Wrong episode.`\nnew_tea 1\nd [lv 0*\"10\"*\"90100001\"]`Tea party.`\nnew_episode 2\nd [lv 0*\"10\"*\"20100002\"]`Right episode.`\n")
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diags)
+	}
+	expectDiagnostic(t, diags, DiagnosticVoiceEpisode, "3:3", "from episode 2, but the script is in episode 1")
+}
+
+func TestParseWithDiagnostics_UndefinedPreset(t *testing.T) {
+	diags := lint(t, "d `{p:1:Defined} {p:2:Undefined} {i:{preset:x:Nested}}`\n")
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diags)
+	}
+	expectDiagnostic(t, diags, DiagnosticUndefinedPreset, "3:18", "'2'")
+	expectDiagnostic(t, diags, DiagnosticUndefinedPreset, "3:37", "'x'")
+}
+
+func TestParseWithDiagnostics_UnknownChar(t *testing.T) {
+	diags := lint(t, "wait 100 @\n§ bgmplay 1\nd `Dialogue keeps @ and §.`[\\]\n")
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diags)
+	}
+	expectDiagnostic(t, diags, DiagnosticUnknownChar, "3:10", "'@'")
+	expectDiagnostic(t, diags, DiagnosticUnknownChar, "4:1", "'§'")
+}
+
+func TestParseWithDiagnostics_Ordered(t *testing.T) {
+	diags := lint(t, "d `{p:9:a}`\nd `b}`\n")
+	if len(diags) != 2 || diags[0].Pos.Line != 3 || diags[1].Pos.Line != 4 {
+		t.Errorf("expected diagnostics in line order, got %v", diags)
+	}
+}
+
+func TestParse_UnclosedKeepsContent(t *testing.T) {
+	for input, want := range map[string]string{
+		"d `{i:text\n": "text",
+		"d `text[":     "text",
+	} {
+		if got := getPlainText(Parse(input).Lines[0].(*ast.DialogueLine)); got != want {
+			t.Errorf("%q: got %q, want %q", input, got, want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package lexar
 
 import (
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"unicode"
@@ -23,10 +24,28 @@ type (
 		AudioTextMap map[string][]ast.DialogueElement // audioID → text fragment elements, only for multi-audio quotes
 		Episode      int
 		ContentType  string
+		Label        string   // name of the script label the quote appears under
+		Chapter      string   // most recent chapter title, reset at each episode marker
+		Background   string   // most recent background set with bg
+		BGM          string   // track playing on the bgm channel, empty when stopped
+		SoundEffects []string // se/me sounds started since the previous dialogue line
+		Sprites      []string // sprites on screen, ordered by position
 		Truth        TruthFlags
 	}
 )
 
+// Commands that carry scene information. Their lines are kept so quotes can
+// be grouped into scenes with a chapter title and backgrounds.
+const (
+	BackgroundCommand   = "bg"
+	ChapterTitleCommand = "chapter_title"
+	// SpriteLoadCommand shows a sprite at a position: ld <pos>,<sprite>,<effect>.
+	SpriteLoadCommand = "ld"
+	// SpriteClearCommand removes the sprite at a position, or every sprite
+	// for position "a": cl <pos>,<effect>.
+	SpriteClearCommand = "cl"
+)
+
 var omakeRegex = regexp.MustCompile(`^o(\d+)_`)
 
 func NewQuoteExtractor() *QuoteExtractor {
@@ -46,11 +65,20 @@ func (e *QuoteExtractor) ExtractFromScript(script *ast.Script) []ExtractedQuote
 	var quotes []ExtractedQuote
 	currentEpisode := 0
 	currentContentType := ""
+	currentLabel := ""
+	currentChapter := ""
+	currentBackground := ""
+	currentBGM := ""
+	var pendingSounds []string
+	onScreen := make(map[string]string)
 
 	for _, line := range script.Lines {
 		switch l := line.(type) {
 		case *ast.EpisodeMarkerLine:
 			currentEpisode = l.Episode
+			currentChapter = ""
+			currentBGM = ""
+			clear(onScreen)
 			if l.Type == "episode" {
 				currentContentType = ""
 			} else {
@@ -58,6 +86,7 @@ func (e *QuoteExtractor) ExtractFromScript(script *ast.Script) []ExtractedQuote
 			}
 
 		case *ast.LabelLine:
+			currentLabel = l.Name
 			if matches := omakeRegex.FindStringSubmatch(l.Name); len(matches) >= 2 {
 				if ep, err := strconv.Atoi(matches[1]); err == nil {
 					currentEpisode = ep
@@ -65,6 +94,44 @@ func (e *QuoteExtractor) ExtractFromScript(script *ast.Script) []ExtractedQuote
 				}
 			}
 
+		case *ast.CommandLine:
+			switch SoundCommandKind(l.Command) {
+			case SoundBGM:
+				if len(l.Args) > 0 {
+					currentBGM = l.Args[0].Value
+				}
+				continue
+			case SoundBGMStop:
+				currentBGM = ""
+				continue
+			case SoundEffect:
+				if len(l.Args) > 0 {
+					pendingSounds = append(pendingSounds, l.Args[0].Value)
+				}
+				continue
+			}
+			if len(l.Args) == 0 {
+				continue
+			}
+			switch l.Command {
+			case BackgroundCommand:
+				currentBackground = l.Args[0].Value
+				// bg also erases every sprite on screen.
+				clear(onScreen)
+			case SpriteLoadCommand:
+				if len(l.Args) >= 2 {
+					onScreen[l.Args[0].Value] = l.Args[1].Value
+				}
+			case SpriteClearCommand:
+				if l.Args[0].Value == "a" {
+					clear(onScreen)
+				} else {
+					delete(onScreen, l.Args[0].Value)
+				}
+			case ChapterTitleCommand:
+				currentChapter = l.Args[0].Value
+			}
+
 		case *ast.DialogueLine:
 			quote := e.extractFromDialogue(l)
 			if quote != nil {
@@ -72,6 +139,13 @@ func (e *QuoteExtractor) ExtractFromScript(script *ast.Script) []ExtractedQuote
 					quote.Episode = currentEpisode
 				}
 				quote.ContentType = currentContentType
+				quote.Label = currentLabel
+				quote.Chapter = currentChapter
+				quote.Background = currentBackground
+				quote.BGM = currentBGM
+				quote.SoundEffects = pendingSounds
+				quote.Sprites = spritesOnScreen(onScreen)
+				pendingSounds = nil
 				quotes = append(quotes, *quote)
 			}
 		}
@@ -80,6 +154,25 @@ func (e *QuoteExtractor) ExtractFromScript(script *ast.Script) []ExtractedQuote
 	return quotes
 }
 
+// spritesOnScreen lists the visible sprites ordered by position, or nil when
+// the screen is empty.
+func spritesOnScreen(onScreen map[string]string) []string {
+	if len(onScreen) == 0 {
+		return nil
+	}
+	positions := make([]string, 0, len(onScreen))
+	for pos := range onScreen {
+		positions = append(positions, pos)
+	}
+	slices.Sort(positions)
+
+	sprites := make([]string, len(positions))
+	for i, pos := range positions {
+		sprites[i] = onScreen[pos]
+	}
+	return sprites
+}
+
 func (e *QuoteExtractor) extractFromDialogue(d *ast.DialogueLine) *ExtractedQuote {
 	voices := d.GetVoiceCommands()
 	truth := DetectTruth(d.Content, e.presets)
@@ -197,6 +290,8 @@ func buildAudioTextMap(elements []ast.DialogueElement) map[string][]ast.Dialogue
 				} else if currentAudioID != "" {
 					currentFragment = append(currentFragment, el)
 				}
+			case *ast.Delimiter:
+				// Backticks and spacing carry no text.
 			default:
 				if currentAudioID != "" {
 					currentFragment = append(currentFragment, elem)
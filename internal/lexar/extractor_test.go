@@ -120,6 +120,123 @@ d [lv 0*"10"*"91000001"]` + "`\"This is the ura content line.\"`" + `[\]`,
 	}
 }
 
+func TestExtractQuotes_Labels(t *testing.T) {
+	input := `d ` + "`Before any label.`" + `[\]
+*umi1_1
+new_episode 1
+d ` + "`First scene.`" + `[\]
+*umi1_2
+d [lv 0*"10"*"10100001"]` + "`\"Second scene.\"`" + `[\]`
+
+	quotes := NewQuoteExtractor().ExtractQuotes(input)
+
+	if len(quotes) != 3 {
+		t.Fatalf("expected 3 quotes, got %d", len(quotes))
+	}
+	for i, want := range []string{"", "umi1_1", "umi1_2"} {
+		if quotes[i].Label != want {
+			t.Errorf("quote %d label: got %q, want %q", i, quotes[i].Label, want)
+		}
+	}
+}
+
+func TestExtractQuotes_SceneCommands(t *testing.T) {
+	input := `*umi1_1
+new_episode 1
+chapter_title "Legend of the Golden Witch"
+bg black,22
+d ` + "`First line.`" + `[\]
+bg ship_deck,22
+d ` + "`Second line.`" + `[\]
+new_tea 1
+d ` + "`Tea party.`" + `[\]`
+
+	quotes := NewQuoteExtractor().ExtractQuotes(input)
+
+	if len(quotes) != 3 {
+		t.Fatalf("expected 3 quotes, got %d", len(quotes))
+	}
+
+	tests := []struct {
+		chapter    string
+		background string
+	}{
+		{"Legend of the Golden Witch", "black"},
+		{"Legend of the Golden Witch", "ship_deck"},
+		{"", "ship_deck"},
+	}
+	for i, tt := range tests {
+		if quotes[i].Chapter != tt.chapter {
+			t.Errorf("quote %d chapter: got %q, want %q", i, quotes[i].Chapter, tt.chapter)
+		}
+		if quotes[i].Background != tt.background {
+			t.Errorf("quote %d background: got %q, want %q", i, quotes[i].Background, tt.background)
+		}
+	}
+}
+
+func TestExtractQuotes_SoundCommands(t *testing.T) {
+	input := `new_episode 1
+bgm1 2
+se1 12
+me1v 3,100
+d ` + "`First line.`" + `[\]
+d ` + "`Second line.`" + `[\]
+bgmstop
+d ` + "`Silence.`" + `[\]`
+
+	quotes := NewQuoteExtractor().ExtractQuotes(input)
+
+	if len(quotes) != 3 {
+		t.Fatalf("expected 3 quotes, got %d", len(quotes))
+	}
+	if quotes[0].BGM != "2" || quotes[1].BGM != "2" {
+		t.Errorf("BGM: got %q and %q, want %q", quotes[0].BGM, quotes[1].BGM, "2")
+	}
+	if quotes[2].BGM != "" {
+		t.Errorf("BGM after bgmstop: got %q, want empty", quotes[2].BGM)
+	}
+	if len(quotes[0].SoundEffects) != 2 || quotes[0].SoundEffects[0] != "12" || quotes[0].SoundEffects[1] != "3" {
+		t.Errorf("SoundEffects: got %v, want [12 3]", quotes[0].SoundEffects)
+	}
+	if len(quotes[1].SoundEffects) != 0 {
+		t.Errorf("SoundEffects on second line: got %v, want none", quotes[1].SoundEffects)
+	}
+}
+
+func TestExtractQuotes_Sprites(t *testing.T) {
+	input := `new_episode 1
+ld c,nan_a11_def1,22
+ld l,kin_a11_ikari1,22
+d ` + "`Both on screen.`" + `[\]
+cl l,22
+d ` + "`Only Nanjo.`" + `[\]
+ld r,jes_a11_def1,22
+cl a,22
+d ` + "`Nobody.`" + `[\]
+ld c,bea_a11_warai1,22
+bg rose_garden,22
+d ` + "`Cleared by bg.`" + `[\]`
+
+	quotes := NewQuoteExtractor().ExtractQuotes(input)
+
+	if len(quotes) != 4 {
+		t.Fatalf("expected 4 quotes, got %d", len(quotes))
+	}
+
+	want := [][]string{
+		{"nan_a11_def1", "kin_a11_ikari1"},
+		{"nan_a11_def1"},
+		nil,
+		nil,
+	}
+	for i := range want {
+		if strings.Join(quotes[i].Sprites, ",") != strings.Join(want[i], ",") {
+			t.Errorf("quote %d sprites: got %v, want %v", i, quotes[i].Sprites, want[i])
+		}
+	}
+}
+
 func TestExtractQuotes_VoiceMetadata(t *testing.T) {
 	input := `new_episode 1
 d [lv 0*"19"*"11900001"]` + "`\"First part. `[@][lv 0*\"19\"*\"11900002\"]`Second part.\"`" + `[\]`
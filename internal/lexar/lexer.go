@@ -1,6 +1,10 @@
 package lexar
 
-import "umineko_quote/internal/lexar/ast"
+import (
+	"unicode/utf8"
+
+	"umineko_quote/internal/lexar/ast"
+)
 
 type Lexer struct {
 	input    string
@@ -8,12 +12,20 @@ type Lexer struct {
 	line     int
 	col      int
 	inDialog bool
+
+	diagnostics *diagnostics
 }
 
 func NewLexer(input string) *Lexer {
 	return &Lexer{input: input, pos: 0, line: 1, col: 1}
 }
 
+// newLexerAt lexes input as though it started at line and col of a larger
+// script, so tokens from text cut out of it keep their true positions.
+func newLexerAt(input string, line int, col int, diags *diagnostics) *Lexer {
+	return &Lexer{input: input, pos: 0, line: line, col: col, diagnostics: diags}
+}
+
 func (l *Lexer) NextToken() ast.Token {
 	if l.inDialog {
 		return l.nextDialogToken()
@@ -139,11 +151,21 @@ func (l *Lexer) nextLineToken() ast.Token {
 		return tok
 	}
 
-	l.advance()
+	// Nothing starts with this character, so skip it, whole if it is
+	// multi-byte, and say so.
+	r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+	pos := ast.Token{Value: l.input[l.pos : l.pos+size], Line: startLine, Column: startCol}
+	l.diagnostics.report(DiagnosticUnknownChar, pos, "unexpected character %q", r)
+	for range size {
+		l.advance()
+	}
 	return l.nextLineToken()
 }
 
+// nextDialogToken skips a space before a backtick, an inline command or the
+// end of the line, and records it on the token that follows.
 func (l *Lexer) nextDialogToken() ast.Token {
+	start := l.pos
 	for (l.peek() == ' ' || l.peek() == '\t') && l.peekN(2)[1:2] != "" {
 		next := l.peekN(2)
 		if len(next) >= 2 && (next[1] == '`' || next[1] == '[' || next[1] == '\n' || next[1] == '\r') {
@@ -154,7 +176,13 @@ func (l *Lexer) nextDialogToken() ast.Token {
 			break
 		}
 	}
+	space := l.input[start:l.pos]
+	tok := l.scanDialogToken()
+	tok.Space = space
+	return tok
+}
 
+func (l *Lexer) scanDialogToken() ast.Token {
 	if l.pos >= len(l.input) {
 		l.inDialog = false
 		return l.makeToken(ast.TokenEOF, "")
@@ -258,7 +286,7 @@ func (l *Lexer) scanInlineCommand() ast.Token {
 	l.advance()
 	start := l.pos
 	depth := 1
-	for depth > 0 && l.peek() != 0 && l.peek() != '\n' {
+	for depth > 0 && l.peek() != 0 && l.peek() != '\n' && l.peek() != '\r' {
 		ch := l.advance()
 		if ch == '[' {
 			depth++
@@ -266,8 +294,16 @@ func (l *Lexer) scanInlineCommand() ast.Token {
 			depth--
 		}
 	}
-	value := l.input[start : l.pos-1]
-	return ast.Token{Type: ast.TokenInlineCommand, Value: value, Line: startLine, Column: startCol}
+	end := l.pos
+	if depth == 0 {
+		end--
+	}
+	value := l.input[start:end]
+	tok := ast.Token{Type: ast.TokenInlineCommand, Value: value, Line: startLine, Column: startCol, Unclosed: depth > 0}
+	if depth > 0 {
+		l.diagnostics.report(DiagnosticUnbalanced, tok, "unclosed '['")
+	}
+	return tok
 }
 
 func (l *Lexer) scanFormatTag() ast.Token {
@@ -275,7 +311,7 @@ func (l *Lexer) scanFormatTag() ast.Token {
 	l.advance()
 	start := l.pos
 	depth := 1
-	for depth > 0 && l.peek() != 0 && l.peek() != '\n' {
+	for depth > 0 && l.peek() != 0 && l.peek() != '\n' && l.peek() != '\r' {
 		ch := l.advance()
 		if ch == '{' {
 			depth++
@@ -283,8 +319,16 @@ func (l *Lexer) scanFormatTag() ast.Token {
 			depth--
 		}
 	}
-	value := l.input[start : l.pos-1]
-	return ast.Token{Type: ast.TokenFormatTag, Value: value, Line: startLine, Column: startCol}
+	end := l.pos
+	if depth == 0 {
+		end--
+	}
+	value := l.input[start:end]
+	tok := ast.Token{Type: ast.TokenFormatTag, Value: value, Line: startLine, Column: startCol, Unclosed: depth > 0}
+	if depth > 0 {
+		l.diagnostics.report(DiagnosticUnbalanced, tok, "unclosed '{'")
+	}
+	return tok
 }
 
 func (l *Lexer) scanDialogText() ast.Token {
@@ -297,7 +341,19 @@ func (l *Lexer) scanDialogText() ast.Token {
 		}
 		l.advance()
 	}
-	return ast.Token{Type: ast.TokenText, Value: l.input[start:l.pos], Line: startLine, Column: startCol}
+	tok := ast.Token{Type: ast.TokenText, Value: l.input[start:l.pos], Line: startLine, Column: startCol}
+	if l.diagnostics != nil {
+		// Literal braces and brackets are written {ob}, {eb}, {os} and {es};
+		// a bare closer has lost its opener.
+		for i := 0; i < len(tok.Value); i++ {
+			if ch := tok.Value[i]; ch == '}' || ch == ']' {
+				pos := tok
+				pos.Value, pos.Column = string(ch), pos.Column+i
+				l.diagnostics.report(DiagnosticUnbalanced, pos, "unmatched '%c'", ch)
+			}
+		}
+	}
+	return tok
 }
 
 func isIdentStart(ch byte) bool {
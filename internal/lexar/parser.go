@@ -9,8 +9,9 @@ import (
 )
 
 type Parser struct {
-	tokens []ast.Token
-	pos    int
+	tokens      []ast.Token
+	pos         int
+	diagnostics *diagnostics
 }
 
 func Parse(input string) *ast.Script {
@@ -107,29 +108,40 @@ func (p *Parser) parseDialogue(cmdTok ast.Token) *ast.DialogueLine {
 	for {
 		tok := p.peek()
 		if tok.Type == ast.TokenEOF || tok.Type == ast.TokenNewline {
+			elements = appendSpace(elements, tok)
 			break
 		}
 
-		switch tok.Type {
-		case ast.TokenBacktick:
-			p.advance()
-		case ast.TokenText:
-			p.advance()
-			elements = append(elements, &ast.PlainText{Text: tok.Value, Pos: tok})
-		case ast.TokenFormatTag:
-			p.advance()
-			elements = append(elements, p.parseFormatTagContent(tok))
-		case ast.TokenInlineCommand:
-			p.advance()
-			elements = append(elements, p.parseInlineCommandContent(tok))
-		default:
-			p.advance()
-		}
+		p.advance()
+		elements = p.appendDialogueToken(elements, tok)
 	}
 
 	return &ast.DialogueLine{Command: cmdTok.Value, Content: elements, Pos: cmdTok}
 }
 
+// appendDialogueToken appends the element tok makes, after a delimiter for
+// the space before it.
+func (p *Parser) appendDialogueToken(elements []ast.DialogueElement, tok ast.Token) []ast.DialogueElement {
+	switch tok.Type {
+	case ast.TokenBacktick:
+		return append(elements, &ast.Delimiter{Text: tok.Space + tok.Value, Pos: tok})
+	case ast.TokenText:
+		return append(appendSpace(elements, tok), &ast.PlainText{Text: tok.Value, Pos: tok})
+	case ast.TokenFormatTag:
+		return append(appendSpace(elements, tok), p.parseFormatTagContent(tok))
+	case ast.TokenInlineCommand:
+		return append(appendSpace(elements, tok), p.parseInlineCommandContent(tok))
+	}
+	return elements
+}
+
+func appendSpace(elements []ast.DialogueElement, tok ast.Token) []ast.DialogueElement {
+	if tok.Space == "" {
+		return elements
+	}
+	return append(elements, &ast.Delimiter{Text: tok.Space, Pos: tok})
+}
+
 func (p *Parser) parseFormatTagContent(tok ast.Token) ast.DialogueElement {
 	tagName, param, content := p.parseFormatTag(tok.Value)
 
@@ -140,38 +152,42 @@ func (p *Parser) parseFormatTagContent(tok ast.Token) ast.DialogueElement {
 		}
 	}
 
+	if !knownFormatTags[tagName] {
+		p.diagnostics.report(DiagnosticUnknownTag, tok, "unknown format tag '%s'", tagName)
+	}
+
 	var nested []ast.DialogueElement
 	if content != "" {
-		nested = p.parseNestedContent(content)
+		// The content ends the tag, after its opening brace.
+		col := tok.Column + 1 + len(tok.Value) - len(content)
+		nested = p.parseNestedContent(content, tok.Line, col)
 	}
 
 	return &ast.FormatTag{Name: tagName, Param: param, Content: nested, Pos: tok}
 }
 
-func (p *Parser) parseNestedContent(content string) []ast.DialogueElement {
+// parseNestedContent parses the content of a format tag, which starts at
+// line and col of the script.
+func (p *Parser) parseNestedContent(content string, line int, col int) []ast.DialogueElement {
 	var elements []ast.DialogueElement
-	lexer := NewLexer("d `" + content + "`")
-
-	for {
-		tok := lexer.NextToken()
-		if tok.Type == ast.TokenEOF {
-			break
-		}
-
-		switch tok.Type {
-		case ast.TokenText:
-			if tok.Value != "" {
-				elements = append(elements, &ast.PlainText{Text: tok.Value, Pos: tok})
-			}
-		case ast.TokenFormatTag:
-			elements = append(elements, p.parseFormatTagContent(tok))
-		case ast.TokenInlineCommand:
-			elements = append(elements, p.parseInlineCommandContent(tok))
-		case ast.TokenCommand, ast.TokenBacktick:
-		}
+	const prefix = "d `"
+	tokens := newLexerAt(prefix+content+"`", line, col-len(prefix), p.diagnostics).Tokenize()
+
+	// Skip the d and the backticks wrapped around the content, keeping
+	// only the space before the closing one.
+	tokens = tokens[2 : len(tokens)-1]
+	var closing ast.Token
+	if last := len(tokens) - 1; last >= 0 && tokens[last].Type == ast.TokenBacktick {
+		closing, tokens = tokens[last], tokens[:last]
+	} else if last >= 0 && tokens[last].Unclosed {
+		// An unclosed tag or command ran on through the closing backtick.
+		tokens[last].Value = strings.TrimSuffix(tokens[last].Value, "`")
+	}
+	for _, tok := range tokens {
+		elements = p.appendDialogueToken(elements, tok)
 	}
 
-	return elements
+	return appendSpace(elements, closing)
 }
 
 func (p *Parser) parseInlineCommandContent(tok ast.Token) ast.DialogueElement {
@@ -207,9 +223,33 @@ func (p *Parser) parseVoiceCommand(args string, tok ast.Token) *ast.VoiceCommand
 		vc.AudioID = strings.Trim(parts[2], `"`)
 	}
 
+	if p.diagnostics != nil {
+		p.checkVoiceCommand(args, parts, tok)
+	}
 	return vc
 }
 
+func (p *Parser) checkVoiceCommand(args string, parts []string, tok ast.Token) {
+	if len(parts) != 3 {
+		p.diagnostics.report(DiagnosticVoiceCommand, tok, `voice command 'lv %s' is not lv channel*"character"*"audio"`, args)
+		return
+	}
+	if _, err := strconv.Atoi(strings.TrimSpace(parts[0])); err != nil {
+		p.diagnostics.report(DiagnosticVoiceCommand, tok, "voice channel '%s' is not a number", parts[0])
+	}
+	if !isQuotedID(parts[1]) {
+		p.diagnostics.report(DiagnosticVoiceCommand, tok, "voice character ID %s is not a quoted ID", parts[1])
+	}
+	if !isQuotedID(parts[2]) {
+		p.diagnostics.report(DiagnosticVoiceCommand, tok, "voice audio ID %s is not a quoted ID", parts[2])
+	}
+}
+
+// isQuotedID reports whether s is a non-empty ID in double quotes.
+func isQuotedID(s string) bool {
+	return len(s) >= 3 && s[0] == '"' && s[len(s)-1] == '"' && !strings.ContainsAny(s[1:len(s)-1], `" `)
+}
+
 func (p *Parser) parsePresetDefine(tok ast.Token) *ast.PresetDefineLine {
 	preset := &ast.PresetDefineLine{Pos: tok}
 
@@ -0,0 +1,130 @@
+package lexar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"umineko_quote/internal/lexar/ast"
+)
+
+// FormatDialogue prints a dialogue line back as script source. A line as
+// parsed prints exactly as it was written; elements changed or added since
+// are written in their usual form.
+func FormatDialogue(line *ast.DialogueLine) string {
+	var sb strings.Builder
+	sb.WriteString(line.Command)
+	formatElements(&sb, line.Content)
+	return sb.String()
+}
+
+// RewriteDialogue returns input with each dialogue line of script, which
+// was parsed from input, replaced by its printed form. Every other line is
+// kept as it is, so a script can be parsed, edited and written back.
+func RewriteDialogue(input string, script *ast.Script) string {
+	lines := strings.SplitAfter(input, "\n")
+	for _, line := range script.Lines {
+		d, ok := line.(*ast.DialogueLine)
+		if !ok || d.Pos.Line < 1 || d.Pos.Line > len(lines) {
+			continue
+		}
+		src := lines[d.Pos.Line-1]
+		body := strings.TrimRight(src, "\r\n")
+		lines[d.Pos.Line-1] = body[:d.Pos.Column-1] + FormatDialogue(d) + src[len(body):]
+	}
+	return strings.Join(lines, "")
+}
+
+// plainTextEscaper writes the characters that would start a tag or an
+// inline command as the tags that stand for them.
+var plainTextEscaper = strings.NewReplacer("{", "{ob}", "[", "{os}")
+
+func formatElements(sb *strings.Builder, elements []ast.DialogueElement) {
+	for _, elem := range elements {
+		switch el := elem.(type) {
+		case *ast.Delimiter:
+			sb.WriteString(el.Text)
+		case *ast.PlainText:
+			plainTextEscaper.WriteString(sb, el.Text)
+		case *ast.SpecialChar:
+			sb.WriteString("{" + el.Name + "}")
+		case *ast.FormatTag:
+			formatTag(sb, el)
+		case *ast.InlineCommand, *ast.VoiceCommand, *ast.ClickWait, *ast.TimedWait:
+			formatInlineCommand(sb, elem)
+		}
+	}
+}
+
+// formatTag writes a format tag. Its name and parameter keep their source
+// spelling while they are unchanged, so {i:} and {i::text} survive.
+func formatTag(sb *strings.Builder, tag *ast.FormatTag) {
+	header := tag.Name
+	if tag.Param != "" {
+		header += ":" + tag.Param + ":"
+	} else if len(tag.Content) > 0 {
+		header += ":"
+	}
+	if tag.Pos.Type == ast.TokenFormatTag {
+		name, param, content := (&Parser{}).parseFormatTag(tag.Pos.Value)
+		if name == tag.Name && param == tag.Param {
+			header = tag.Pos.Value[:len(tag.Pos.Value)-len(content)]
+		}
+	}
+
+	sb.WriteString("{")
+	sb.WriteString(header)
+	formatElements(sb, tag.Content)
+	if !tag.Pos.Unclosed {
+		sb.WriteString("}")
+	}
+}
+
+// formatInlineCommand writes an inline command, keeping its source spelling
+// while it still means the same.
+func formatInlineCommand(sb *strings.Builder, elem ast.DialogueElement) {
+	source := inlineCommandSource(elem)
+	if pos := inlineCommandPos(elem); pos.Type == ast.TokenInlineCommand {
+		if inlineCommandSource((&Parser{}).parseInlineCommandContent(pos)) == source {
+			source = pos.Value
+		}
+	}
+	sb.WriteString("[" + source)
+	if !inlineCommandPos(elem).Unclosed {
+		sb.WriteString("]")
+	}
+}
+
+func inlineCommandSource(elem ast.DialogueElement) string {
+	switch el := elem.(type) {
+	case *ast.VoiceCommand:
+		return fmt.Sprintf(`lv %d*"%s"*"%s"`, el.Channel, el.CharacterID, el.AudioID)
+	case *ast.ClickWait:
+		return el.Type
+	case *ast.TimedWait:
+		if el.Skippable {
+			return "!d" + strconv.Itoa(el.Duration)
+		}
+		return "!w" + strconv.Itoa(el.Duration)
+	case *ast.InlineCommand:
+		if el.Args == "" {
+			return el.Command
+		}
+		return el.Command + " " + el.Args
+	}
+	return ""
+}
+
+func inlineCommandPos(elem ast.DialogueElement) ast.Token {
+	switch el := elem.(type) {
+	case *ast.VoiceCommand:
+		return el.Pos
+	case *ast.ClickWait:
+		return el.Pos
+	case *ast.TimedWait:
+		return el.Pos
+	case *ast.InlineCommand:
+		return el.Pos
+	}
+	return ast.Token{}
+}
@@ -0,0 +1,111 @@
+package lexar
+
+import (
+	"strings"
+	"testing"
+
+	"umineko_quote/internal/lexar/ast"
+)
+
+var roundTripLines = []string{
+	"d `Hello, world!`",
+	"d2 `Plain d2 line.`[\\]",
+	`d [lv 0*"10"*"10100001"]` + "`\"Test line.\"`[\\]",
+	`d [lv 0*"19"*"11900001"]` + "`\"............Again. `[@]" + `[lv 0*"19"*"11900002"]` + "`...So, you still haven't?\" `[\\]",
+	`d [lv 0*"27"*"12700001"]` + "`\"{p:1:I am the Golden Witch, Beatrice}. Without love, it cannot be seen.\"`[\\]",
+	`d [lv 0*"27"*"40700001"]` + "`{p:1:Red truth split `[@]" + `[lv 0*"27"*"40700002"]` + "`across voices}.`[\\]",
+	"d `{nobr:{m:-5:——}—}{n}{i:and {c:FF0000:colour}}{qt}{os}1{es}{0}`[|]",
+	"d `{ruby:reading:text} {f:5:font} {i:} {i::empty param} {y:2:hidden}`",
+	"d `Timed`[!w500]`, skippable`[!d 300]`, and odd`[ @ ][lv  0*\"10\"*\"10100003\"][unknown arg]",
+	"d `  Leading spaces and a trailing tab.`\t[\\]",
+	"d    `Spaces after d.`",
+	"d\t`Tab after d.`",
+	"d `Trailing space before the newline.` ",
+	"d `日本語の台詞です。`[\\]",
+	"d `{p:1:x`",
+	"d `x`[lv 0*\"10\"*\"1\"",
+	"d `Unclosed {i:tag and [bracket`",
+	"d Text outside backticks [@] and more",
+	"d ``",
+	"d",
+}
+
+func TestFormatDialogue_RoundTrip(t *testing.T) {
+	for _, line := range roundTripLines {
+		script := Parse(line + "\n")
+		if len(script.Lines) != 1 {
+			t.Fatalf("%q: got %d lines", line, len(script.Lines))
+		}
+		d, ok := script.Lines[0].(*ast.DialogueLine)
+		if !ok {
+			t.Fatalf("%q: got %T", line, script.Lines[0])
+		}
+		if got := FormatDialogue(d); got != line {
+			t.Errorf("round trip:\n got %q\nwant %q", got, line)
+		}
+	}
+}
+
+func TestRewriteDialogue_RoundTrip(t *testing.T) {
+	input := ";header comment\r\n" +
+		"preset_define 1,1,-1,#FF0000,0,0,0,1,-1,#000000,0,-1,-1,#000000,1,-1 ;Red text\r\n" +
+		"*umi1_1\r\n" +
+		"new_episode 1\r\n" +
+		"bgm1 2\r\n" +
+		strings.Join(roundTripLines, "\r\n") + "\r\n" +
+		"  d `Indented.`\n" +
+		"ld c,nan_a11_def1,22\n" +
+		"d `Last line without a newline.`"
+
+	if got := RewriteDialogue(input, Parse(input)); got != input {
+		t.Errorf("round trip changed the script:\n got %q\nwant %q", got, input)
+	}
+}
+
+func TestFormatDialogue_Edited(t *testing.T) {
+	input := `d [lv  0*"10"*"10100001"]` + "`\"{p:1:Batler} is {i:wrong}.\"`[\\]"
+	d := Parse(input).Lines[0].(*ast.DialogueLine)
+
+	voice := d.GetVoiceCommands()[0]
+	voice.AudioID = "10100002"
+	for _, elem := range d.Content {
+		if tag, ok := elem.(*ast.FormatTag); ok && tag.Name == "p" {
+			tag.Content[0].(*ast.PlainText).Text = "Battler"
+			tag.Param = "2"
+		}
+	}
+	d.Content = append(d.Content, &ast.PlainText{Text: " {literal} [brackets]"})
+
+	want := `d [lv 0*"10"*"10100002"]` + "`\"{p:2:Battler} is {i:wrong}.\"`[\\] {ob}literal} {os}brackets]"
+	got := FormatDialogue(d)
+	if got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+
+	// The edited line parses back to what was printed.
+	if again := FormatDialogue(Parse(got).Lines[0].(*ast.DialogueLine)); again != got {
+		t.Errorf("reprinted edit changed:\n got %q\nwant %q", again, got)
+	}
+}
+
+func TestFormatDialogue_Constructed(t *testing.T) {
+	d := &ast.DialogueLine{
+		Command: "d",
+		Content: []ast.DialogueElement{
+			&ast.Delimiter{Text: " "},
+			&ast.VoiceCommand{CharacterID: "27", AudioID: "12700001"},
+			&ast.Delimiter{Text: "`"},
+			&ast.FormatTag{Name: "p", Param: "1", Content: []ast.DialogueElement{&ast.PlainText{Text: "Red"}}},
+			&ast.SpecialChar{Name: "n"},
+			&ast.FormatTag{Name: "i", Content: []ast.DialogueElement{&ast.PlainText{Text: "italic"}}},
+			&ast.TimedWait{Duration: 100},
+			&ast.Delimiter{Text: "`"},
+			&ast.ClickWait{Type: "\\"},
+		},
+	}
+
+	want := `d [lv 0*"27"*"12700001"]` + "`{p:1:Red}{n}{i:italic}[!w100]`[\\]"
+	if got := FormatDialogue(d); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
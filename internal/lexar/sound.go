@@ -0,0 +1,41 @@
+package lexar
+
+import "strings"
+
+// SoundKind classifies an audio command.
+type SoundKind int
+
+const (
+	SoundNone SoundKind = iota
+	// SoundBGM starts a background music track (bgm, bgm1, bgm1v, ...).
+	SoundBGM
+	// SoundBGMStop stops background music (bgmstop, bgm1fadeout, ...).
+	SoundBGMStop
+	// SoundEffect plays a sound effect or music effect (se1, se1v, me1v, ...).
+	SoundEffect
+)
+
+// SoundCommandKind reports what an audio command does. Channel numbers and
+// the trailing "v" (volume) variant are accepted on every command, so bgm1v,
+// se3 and me2v are all recognised. Stop and fade commands on the se/me
+// channels are ignored.
+func SoundCommandKind(command string) SoundKind {
+	for _, prefix := range []string{"bgm", "se", "me"} {
+		rest, ok := strings.CutPrefix(command, prefix)
+		if !ok {
+			continue
+		}
+		rest = strings.TrimLeft(rest, "0123456789")
+		switch {
+		case rest == "" || rest == "v":
+			if prefix == "bgm" {
+				return SoundBGM
+			}
+			return SoundEffect
+		case prefix == "bgm" && (strings.HasSuffix(rest, "stop") || strings.HasSuffix(rest, "fadeout")):
+			return SoundBGMStop
+		}
+		return SoundNone
+	}
+	return SoundNone
+}
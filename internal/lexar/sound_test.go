@@ -0,0 +1,30 @@
+package lexar
+
+import "testing"
+
+func TestSoundCommandKind(t *testing.T) {
+	tests := []struct {
+		command string
+		want    SoundKind
+	}{
+		{"bgm", SoundBGM},
+		{"bgm1", SoundBGM},
+		{"bgm1v", SoundBGM},
+		{"bgmstop", SoundBGMStop},
+		{"bgm1fadeout", SoundBGMStop},
+		{"se1", SoundEffect},
+		{"se3v", SoundEffect},
+		{"me1v", SoundEffect},
+		{"sestop", SoundNone},
+		{"setwindow", SoundNone},
+		{"menu", SoundNone},
+		{"bg", SoundNone},
+		{"d", SoundNone},
+	}
+
+	for _, tt := range tests {
+		if got := SoundCommandKind(tt.command); got != tt.want {
+			t.Errorf("SoundCommandKind(%q): got %d, want %d", tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package quote
+
+import "strings"
+
+// narrationKey locates a narration line by its position among the narration
+// lines under the same episode, content type and script label.
+type narrationKey struct {
+	episode     int
+	contentType string
+	label       string
+	ordinal     int
+}
+
+// narrationKeys returns the narrationKey of every narration quote, keyed by
+// its index. Voiced quotes are left out.
+func narrationKeys(quotes []ParsedQuote) map[int]narrationKey {
+	keys := make(map[int]narrationKey)
+	counts := make(map[narrationKey]int)
+	for i := range quotes {
+		if quotes[i].AudioID != "" {
+			continue
+		}
+		group := narrationKey{
+			episode:     quotes[i].Episode,
+			contentType: quotes[i].ContentType,
+			label:       quotes[i].label,
+		}
+		key := group
+		key.ordinal = counts[group]
+		counts[group]++
+		keys[i] = key
+	}
+	return keys
+}
+
+// alignQuotes pairs each quote in from with its counterpart in to. Voiced
+// lines are paired through any audio ID they share, using toAudio to look
+// them up; narration is paired by position within its episode and label.
+// The result holds the index into to for each quote in from, or -1 when
+// nothing lines up.
+func alignQuotes(from []ParsedQuote, to []ParsedQuote, toAudio map[string]int) []int {
+	aligned := make([]int, len(from))
+
+	toNarration := make(map[narrationKey]int)
+	for idx, key := range narrationKeys(to) {
+		toNarration[key] = idx
+	}
+	fromNarration := narrationKeys(from)
+
+	for i := range from {
+		aligned[i] = -1
+		if from[i].AudioID == "" {
+			if idx, ok := toNarration[fromNarration[i]]; ok {
+				aligned[i] = idx
+			}
+			continue
+		}
+		for _, id := range strings.Split(from[i].AudioID, ", ") {
+			if idx, ok := toAudio[id]; ok {
+				aligned[i] = idx
+				break
+			}
+		}
+	}
+	return aligned
+}
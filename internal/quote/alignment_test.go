@@ -0,0 +1,60 @@
+package quote
+
+import "testing"
+
+func TestAlignQuotes_VoicedByAudioID(t *testing.T) {
+	from := []ParsedQuote{
+		{Text: "Beatrice! Why?", AudioID: "10100001, 10100002"},
+		{Text: "Battler!", AudioID: "10400001"},
+	}
+	to := []ParsedQuote{
+		{Text: "戦人！", AudioID: "10400001"},
+		{Text: "なぜ？", AudioID: "10100002"},
+	}
+	toAudio := map[string]int{"10400001": 0, "10100002": 1}
+
+	got := alignQuotes(from, to, toAudio)
+
+	if got[0] != 1 {
+		t.Errorf("composite line: got %d, want 1", got[0])
+	}
+	if got[1] != 0 {
+		t.Errorf("single line: got %d, want 0", got[1])
+	}
+}
+
+func TestAlignQuotes_NarrationByPosition(t *testing.T) {
+	from := []ParsedQuote{
+		{Text: "first", Episode: 1, label: "umi1_1"},
+		{Text: "voiced", Episode: 1, label: "umi1_1", AudioID: "10100001"},
+		{Text: "second", Episode: 1, label: "umi1_1"},
+		{Text: "next scene", Episode: 1, label: "umi1_2"},
+	}
+	to := []ParsedQuote{
+		{Text: "一", Episode: 1, label: "umi1_1"},
+		{Text: "二", Episode: 1, label: "umi1_1"},
+		{Text: "次", Episode: 1, label: "umi1_2"},
+	}
+
+	got := alignQuotes(from, to, map[string]int{})
+	want := []int{0, -1, 1, 2}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("quote %d: got %d, want %d", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAlignQuotes_NoCounterpart(t *testing.T) {
+	from := []ParsedQuote{
+		{Text: "extra narration", Episode: 2, label: "umi2_1"},
+		{Text: "untranslated", AudioID: "29900001"},
+	}
+
+	for i, got := range alignQuotes(from, nil, map[string]int{}) {
+		if got != -1 {
+			t.Errorf("quote %d: got %d, want -1", i, got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package quote
+
+import (
+	"strings"
+
+	"umineko_quote/internal/audio"
+)
+
+// AudioMeta describes one voice clip.
+type AudioMeta struct {
+	DurationMs int64 `json:"durationMs"`
+	SampleRate int   `json:"sampleRate"`
+	Channels   int   `json:"channels"`
+}
+
+func audioMetaKey(characterID string, audioID string) string {
+	return characterID + "/" + audioID
+}
+
+// audioCatalog is what is known about the clips in an audio store. It is
+// scanned once and shared by every indexer built while the server runs, so
+// reloading the scripts does not rescan the audio.
+type audioCatalog struct {
+	store    audio.AudioStore
+	meta     map[string]AudioMeta
+	hasAudio bool
+}
+
+// scanAudioCatalog lists and reads the metadata of every clip in store,
+// which may be nil.
+func scanAudioCatalog(store audio.AudioStore) *audioCatalog {
+	var clips []audio.ClipInfo
+	if store != nil {
+		clips, _ = store.List()
+	}
+	return &audioCatalog{
+		store:    store,
+		meta:     scanAudioMeta(store, clips),
+		hasAudio: len(clips) > 0,
+	}
+}
+
+// scanAudioMeta reads the metadata of every listed clip in store, keyed by
+// audioMetaKey. Clips that fail to open or parse are skipped.
+func scanAudioMeta(store audio.AudioStore, clips []audio.ClipInfo) map[string]AudioMeta {
+	meta := make(map[string]AudioMeta)
+	if len(clips) == 0 {
+		return meta
+	}
+	for ref, m := range audio.ScanMeta(store, clips) {
+		meta[audioMetaKey(ref.CharID, ref.AudioID)] = AudioMeta{
+			DurationMs: m.Duration.Milliseconds(),
+			SampleRate: m.SampleRate,
+			Channels:   m.Channels,
+		}
+	}
+	return meta
+}
+
+// applyAudioMeta fills in AudioMeta for each of a quote's audio IDs, taking
+// each clip's character from AudioCharMap when the quote has one.
+func applyAudioMeta(quotes []ParsedQuote, idx Indexer) {
+	for i := range quotes {
+		if quotes[i].AudioID == "" {
+			continue
+		}
+		for _, id := range strings.Split(quotes[i].AudioID, ", ") {
+			characterID := quotes[i].CharacterID
+			if c, ok := quotes[i].AudioCharMap[id]; ok {
+				characterID = c
+			}
+			m, ok := idx.AudioMeta(characterID, id)
+			if !ok {
+				continue
+			}
+			if quotes[i].AudioMeta == nil {
+				quotes[i].AudioMeta = make(map[string]AudioMeta)
+			}
+			quotes[i].AudioMeta[id] = m
+		}
+	}
+}
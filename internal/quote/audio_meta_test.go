@@ -0,0 +1,99 @@
+package quote
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"umineko_quote/internal/audio"
+)
+
+// writeTestClip copies the audio package's test clip, one second of mono
+// 44.1 kHz audio, to path.
+func writeTestClip(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile("../audio/testdata/clip.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanAudioMeta(t *testing.T) {
+	dir := t.TempDir()
+	writeTestClip(t, filepath.Join(dir, "10", "10100001.ogg"))
+	writeTestClip(t, filepath.Join(dir, "27", "12700001.ogg"))
+	if err := os.WriteFile(filepath.Join(dir, "27", "broken.ogg"), []byte("not ogg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := audio.NewDirStore(dir)
+	clips, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := scanAudioMeta(store, clips)
+	if len(meta) != 2 {
+		t.Fatalf("expected 2 clips, got %d: %v", len(meta), meta)
+	}
+	want := AudioMeta{DurationMs: 1000, SampleRate: 44100, Channels: 1}
+	for _, key := range []string{audioMetaKey("10", "10100001"), audioMetaKey("27", "12700001")} {
+		if got := meta[key]; got != want {
+			t.Errorf("%s: got %+v, want %+v", key, got, want)
+		}
+	}
+}
+
+func TestScanAudioMeta_NoStore(t *testing.T) {
+	if meta := scanAudioMeta(nil, nil); len(meta) != 0 {
+		t.Errorf("expected no metadata without an audio store, got %v", meta)
+	}
+	if catalog := scanAudioCatalog(nil); catalog.hasAudio || len(catalog.meta) != 0 {
+		t.Errorf("expected an empty catalog without an audio store, got %+v", catalog)
+	}
+}
+
+func TestApplyAudioMeta(t *testing.T) {
+	dir := t.TempDir()
+	writeTestClip(t, filepath.Join(dir, "10", "10100001.ogg"))
+	writeTestClip(t, filepath.Join(dir, "27", "12700001.ogg"))
+
+	quotes := map[string][]ParsedQuote{
+		"en": {
+			{Text: "Solo", CharacterID: "10", AudioID: "10100001"},
+			{
+				Text:         "Duet",
+				CharacterID:  "10",
+				AudioID:      "10100001, 12700001",
+				AudioCharMap: map[string]string{"12700001": "27"},
+			},
+			{Text: "Narration", CharacterID: "narrator"},
+			{Text: "Missing clip", CharacterID: "10", AudioID: "10199999"},
+		},
+	}
+	idx := NewIndexer(quotes, audio.NewDirStore(dir))
+	applyAudioMeta(quotes["en"], idx)
+
+	if got := quotes["en"][0].AudioMeta["10100001"].DurationMs; got != 1000 {
+		t.Errorf("solo duration: got %d, want 1000", got)
+	}
+	duet := quotes["en"][1].AudioMeta
+	if len(duet) != 2 || duet["12700001"].DurationMs != 1000 {
+		t.Errorf("duet meta: got %+v", duet)
+	}
+	if quotes["en"][2].AudioMeta != nil || quotes["en"][3].AudioMeta != nil {
+		t.Errorf("expected no meta for narration or a missing clip")
+	}
+
+	if _, ok := idx.AudioMeta("27", "12700001"); !ok {
+		t.Errorf("expected indexer to know 27/12700001")
+	}
+	if _, ok := idx.AudioMeta("10", "12700001"); ok {
+		t.Errorf("expected metadata to be keyed by character")
+	}
+}
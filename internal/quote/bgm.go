@@ -0,0 +1,51 @@
+package quote
+
+import "strings"
+
+// bgmTitlesPath is an optional file in the script directory naming BGM
+// tracks. Each line holds a track reference as it appears in bgm commands,
+// a tab and the title:
+//
+//	21	Dread of the Grave
+//
+// Blank lines and lines starting with # are skipped.
+const bgmTitlesPath = "bgm.txt"
+
+func parseBGMTitles(data string) map[string]string {
+	titles := make(map[string]string)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		track, title, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		track, title = strings.TrimSpace(track), strings.TrimSpace(title)
+		if track != "" && title != "" {
+			titles[track] = title
+		}
+	}
+	return titles
+}
+
+func applyBGMTitles(quotes []ParsedQuote, titles map[string]string) {
+	for i := range quotes {
+		if quotes[i].BGM != "" {
+			quotes[i].BGMTitle = titles[quotes[i].BGM]
+		}
+	}
+}
+
+// matchesBGM reports whether the quote was spoken while the given track was
+// playing. bgm may be the track reference or part of its title, ignoring case.
+func matchesBGM(q ParsedQuote, bgm string) bool {
+	if q.BGM == "" {
+		return false
+	}
+	if strings.EqualFold(q.BGM, bgm) {
+		return true
+	}
+	return q.BGMTitle != "" && strings.Contains(strings.ToLower(q.BGMTitle), strings.ToLower(bgm))
+}
@@ -0,0 +1,48 @@
+package quote
+
+import "testing"
+
+func TestParseBGMTitles(t *testing.T) {
+	titles := parseBGMTitles("# track titles\n21\tDread of the Grave\n\n5\t Golden Nocturne \nbroken line\n")
+
+	if len(titles) != 2 {
+		t.Fatalf("expected 2 titles, got %d: %v", len(titles), titles)
+	}
+	if titles["21"] != "Dread of the Grave" {
+		t.Errorf("track 21: got %q", titles["21"])
+	}
+	if titles["5"] != "Golden Nocturne" {
+		t.Errorf("track 5: got %q", titles["5"])
+	}
+}
+
+func TestApplyBGMTitles(t *testing.T) {
+	quotes := []ParsedQuote{{BGM: "21"}, {BGM: "99"}, {}}
+
+	applyBGMTitles(quotes, map[string]string{"21": "Dread of the Grave"})
+
+	if quotes[0].BGMTitle != "Dread of the Grave" {
+		t.Errorf("quote 0: got %q", quotes[0].BGMTitle)
+	}
+	if quotes[1].BGMTitle != "" || quotes[2].BGMTitle != "" {
+		t.Errorf("untitled quotes: got %q and %q", quotes[1].BGMTitle, quotes[2].BGMTitle)
+	}
+}
+
+func TestMatchesBGM(t *testing.T) {
+	q := ParsedQuote{BGM: "21", BGMTitle: "Dread of the Grave"}
+
+	for _, bgm := range []string{"21", "Dread of the Grave", "dread of the grave", "grave"} {
+		if !matchesBGM(q, bgm) {
+			t.Errorf("matchesBGM(%q): got false, want true", bgm)
+		}
+	}
+	for _, bgm := range []string{"2", "nocturne"} {
+		if matchesBGM(q, bgm) {
+			t.Errorf("matchesBGM(%q): got true, want false", bgm)
+		}
+	}
+	if matchesBGM(ParsedQuote{}, "21") {
+		t.Error("quote without BGM should never match")
+	}
+}
@@ -0,0 +1,290 @@
+package quote
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ExportFormat is a file format for a bulk export of quotes.
+type ExportFormat string
+
+const (
+	// ExportJSONL writes one JSON object per quote and line.
+	ExportJSONL ExportFormat = "jsonl"
+	// ExportCSV writes RFC 4180 CSV with a header row.
+	ExportCSV ExportFormat = "csv"
+	// ExportTSV writes tab-separated values with a header row. Backslashes,
+	// tabs and line breaks in values are escaped as \\, \t, \n and \r.
+	ExportTSV ExportFormat = "tsv"
+	// ExportColumnar writes one JSON object holding the schema and an array
+	// of values per column:
+	//
+	//	{"schema":[{"name":"id","type":"string"},...],"rows":2,"columns":{"id":["a","b"],...}}
+	ExportColumnar ExportFormat = "columnar"
+)
+
+// ParseExportFormat reads an export format by name.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch f := ExportFormat(s); f {
+	case ExportJSONL, ExportCSV, ExportTSV, ExportColumnar:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown export format '%s'; want jsonl, csv, tsv or columnar", s)
+}
+
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportCSV:
+		return "text/csv; charset=utf-8"
+	case ExportTSV:
+		return "text/tab-separated-values; charset=utf-8"
+	case ExportColumnar:
+		return "application/json"
+	}
+	return "application/x-ndjson"
+}
+
+// Extension is the file extension for the format, without a dot.
+func (f ExportFormat) Extension() string {
+	if f == ExportColumnar {
+		return "json"
+	}
+	return string(f)
+}
+
+// ExportColumn describes one field of an export.
+type ExportColumn struct {
+	Name string `json:"name"`
+	// Type is string, int, bool, list<string> or list<int>. CSV and TSV
+	// write lists as JSON arrays.
+	Type string `json:"type"`
+
+	value func(q *ParsedQuote, voices []string) any
+}
+
+// ExportSchema lists the columns of an export, in order. A quote's voice
+// clips are flattened into the voice_* lists, which line up with
+// voice_audio_ids: voice_character_ids gives whose clip each is, from
+// AudioCharMap or else the quote's character; voice_texts gives the part of
+// the text each clip speaks, from AudioTextMap or else ""; and
+// voice_duration_ms gives each clip's length, or 0 when it is unknown.
+var ExportSchema = []ExportColumn{
+	{Name: "id", Type: "string", value: func(q *ParsedQuote, _ []string) any { return q.ID }},
+	{Name: "episode", Type: "int", value: func(q *ParsedQuote, _ []string) any { return q.Episode }},
+	{Name: "content_type", Type: "string", value: func(q *ParsedQuote, _ []string) any { return q.ContentType }},
+	{Name: "scene_id", Type: "string", value: func(q *ParsedQuote, _ []string) any { return q.SceneID }},
+	{Name: "character_id", Type: "string", value: func(q *ParsedQuote, _ []string) any { return q.CharacterID }},
+	{Name: "character", Type: "string", value: func(q *ParsedQuote, _ []string) any { return q.Character }},
+	{Name: "text", Type: "string", value: func(q *ParsedQuote, _ []string) any { return q.Text }},
+	{Name: "text_html", Type: "string", value: func(q *ParsedQuote, _ []string) any { return q.TextHtml }},
+	{Name: "has_red_truth", Type: "bool", value: func(q *ParsedQuote, _ []string) any { return q.HasRedTruth }},
+	{Name: "has_blue_truth", Type: "bool", value: func(q *ParsedQuote, _ []string) any { return q.HasBlueTruth }},
+	{Name: "voice_audio_ids", Type: "list<string>", value: func(_ *ParsedQuote, voices []string) any { return voices }},
+	{Name: "voice_character_ids", Type: "list<string>", value: func(q *ParsedQuote, voices []string) any {
+		ids := make([]string, len(voices))
+		for i, id := range voices {
+			ids[i] = q.CharacterID
+			if c, ok := q.AudioCharMap[id]; ok {
+				ids[i] = c
+			}
+		}
+		return ids
+	}},
+	{Name: "voice_texts", Type: "list<string>", value: func(q *ParsedQuote, voices []string) any {
+		texts := make([]string, len(voices))
+		for i, id := range voices {
+			texts[i] = q.AudioTextMap[id]
+		}
+		return texts
+	}},
+	{Name: "voice_duration_ms", Type: "list<int>", value: func(q *ParsedQuote, voices []string) any {
+		durations := make([]int, len(voices))
+		for i, id := range voices {
+			durations[i] = int(q.AudioMeta[id].DurationMs)
+		}
+		return durations
+	}},
+	{Name: "bgm", Type: "string", value: func(q *ParsedQuote, _ []string) any { return q.BGM }},
+	{Name: "bgm_title", Type: "string", value: func(q *ParsedQuote, _ []string) any { return q.BGMTitle }},
+	{Name: "sound_effects", Type: "list<string>", value: func(q *ParsedQuote, _ []string) any { return nonNil(q.SoundEffects) }},
+	{Name: "present", Type: "list<string>", value: func(q *ParsedQuote, _ []string) any { return nonNil(q.Present) }},
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// voiceIDs splits a quote's audio IDs.
+func voiceIDs(q *ParsedQuote) []string {
+	if q.AudioID == "" {
+		return []string{}
+	}
+	return strings.Split(q.AudioID, ", ")
+}
+
+// WriteExport writes quotes to w in format, one quote at a time.
+func WriteExport(w io.Writer, format ExportFormat, quotes []ParsedQuote) error {
+	bw := bufio.NewWriter(w)
+	var err error
+	switch format {
+	case ExportCSV:
+		err = writeExportCSV(bw, quotes)
+	case ExportTSV:
+		err = writeExportTSV(bw, quotes)
+	case ExportColumnar:
+		err = writeExportColumnar(bw, quotes)
+	default:
+		err = writeExportJSONL(bw, quotes)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeExportJSONL(w *bufio.Writer, quotes []ParsedQuote) error {
+	for i := range quotes {
+		voices := voiceIDs(&quotes[i])
+		w.WriteByte('{')
+		for c, col := range ExportSchema {
+			if c > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeJSONField(w, col.Name, col.value(&quotes[i], voices)); err != nil {
+				return err
+			}
+		}
+		if _, err := w.WriteString("}\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeExportCSV(w *bufio.Writer, quotes []ParsedQuote) error {
+	cw := csv.NewWriter(w)
+	record := make([]string, len(ExportSchema))
+	for c, col := range ExportSchema {
+		record[c] = col.Name
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	for i := range quotes {
+		voices := voiceIDs(&quotes[i])
+		for c, col := range ExportSchema {
+			record[c] = exportCell(col.value(&quotes[i], voices))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func writeExportTSV(w *bufio.Writer, quotes []ParsedQuote) error {
+	for c, col := range ExportSchema {
+		if c > 0 {
+			w.WriteByte('\t')
+		}
+		w.WriteString(col.Name)
+	}
+	w.WriteByte('\n')
+	for i := range quotes {
+		voices := voiceIDs(&quotes[i])
+		for c, col := range ExportSchema {
+			if c > 0 {
+				w.WriteByte('\t')
+			}
+			tsvEscaper.WriteString(w, exportCell(col.value(&quotes[i], voices)))
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeExportColumnar(w *bufio.Writer, quotes []ParsedQuote) error {
+	schema, err := json.Marshal(ExportSchema)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, `{"schema":%s,"rows":%d,"columns":{`, schema, len(quotes))
+	voices := make([][]string, len(quotes))
+	for i := range quotes {
+		voices[i] = voiceIDs(&quotes[i])
+	}
+	for c, col := range ExportSchema {
+		if c > 0 {
+			w.WriteByte(',')
+		}
+		writeJSONValue(w, col.Name)
+		w.WriteString(":[")
+		for i := range quotes {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeJSONValue(w, col.value(&quotes[i], voices[i])); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	}
+	_, err = w.WriteString("}}\n")
+	return err
+}
+
+func writeJSONField(w *bufio.Writer, name string, value any) error {
+	if err := writeJSONValue(w, name); err != nil {
+		return err
+	}
+	w.WriteByte(':')
+	return writeJSONValue(w, value)
+}
+
+// writeJSONValue writes value as JSON, leaving HTML in the text unescaped.
+func writeJSONValue(w *bufio.Writer, value any) error {
+	data, err := marshalExportJSON(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func marshalExportJSON(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// exportCell formats a value for CSV or TSV, writing lists as JSON arrays.
+func exportCell(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := marshalExportJSON(value)
+	return string(data)
+}
@@ -0,0 +1,152 @@
+package quote
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func exportQuotes() []ParsedQuote {
+	return []ParsedQuote{
+		{
+			ID:           "1-1",
+			Text:         "Without love,\tit cannot be \"seen\".",
+			CharacterID:  "27",
+			Character:    "Beatrice",
+			AudioID:      "27100001, 10100002",
+			AudioCharMap: map[string]string{"10100002": "10"},
+			AudioTextMap: map[string]string{"27100001": "Without love,"},
+			AudioMeta:    map[string]AudioMeta{"10100002": {DurationMs: 1500}},
+			Episode:      1,
+			HasRedTruth:  true,
+			Present:      []string{"27", "10"},
+		},
+		{ID: "1-2", Text: "Narration\nover two lines.", CharacterID: "narrator", Episode: 1},
+	}
+}
+
+func writeTestExport(t *testing.T, format ExportFormat) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteExport(&buf, format, exportQuotes()); err != nil {
+		t.Fatalf("WriteExport(%s): %v", format, err)
+	}
+	return buf.String()
+}
+
+func TestWriteExport_JSONL(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(writeTestExport(t, ExportJSONL), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+
+	var row struct {
+		ID                string   `json:"id"`
+		VoiceAudioIDs     []string `json:"voice_audio_ids"`
+		VoiceCharacterIDs []string `json:"voice_character_ids"`
+		VoiceTexts        []string `json:"voice_texts"`
+		VoiceDurationMs   []int    `json:"voice_duration_ms"`
+		HasRedTruth       bool     `json:"has_red_truth"`
+		SoundEffects      []string `json:"sound_effects"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &row); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if row.ID != "1-1" || !row.HasRedTruth {
+		t.Errorf("got %+v", row)
+	}
+	if strings.Join(row.VoiceAudioIDs, ",") != "27100001,10100002" ||
+		strings.Join(row.VoiceCharacterIDs, ",") != "27,10" ||
+		strings.Join(row.VoiceTexts, "|") != "Without love,|" ||
+		len(row.VoiceDurationMs) != 2 || row.VoiceDurationMs[0] != 0 || row.VoiceDurationMs[1] != 1500 {
+		t.Errorf("voices not flattened in line: %+v", row)
+	}
+	if row.SoundEffects == nil {
+		t.Error("expected an empty list for no sound effects, got null")
+	}
+
+	if !strings.Contains(lines[1], `"voice_audio_ids":[]`) {
+		t.Errorf("expected empty voice lists without audio: %s", lines[1])
+	}
+}
+
+func TestWriteExport_CSV(t *testing.T) {
+	records, err := csv.NewReader(strings.NewReader(writeTestExport(t, ExportCSV))).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want header and 2 rows", len(records))
+	}
+	if len(records[0]) != len(ExportSchema) || records[0][0] != "id" {
+		t.Errorf("unexpected header %v", records[0])
+	}
+	row := make(map[string]string)
+	for i, name := range records[0] {
+		row[name] = records[1][i]
+	}
+	if row["text"] != "Without love,\tit cannot be \"seen\"." {
+		t.Errorf("text: got %q", row["text"])
+	}
+	if row["voice_character_ids"] != `["27","10"]` || row["episode"] != "1" || row["has_red_truth"] != "true" {
+		t.Errorf("got %v", row)
+	}
+}
+
+func TestWriteExport_TSV(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(writeTestExport(t, ExportTSV), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want header and 2 rows", len(lines))
+	}
+	for _, line := range lines {
+		if n := len(strings.Split(line, "\t")); n != len(ExportSchema) {
+			t.Errorf("got %d fields, want %d: %q", n, len(ExportSchema), line)
+		}
+	}
+	if !strings.Contains(lines[1], `Without love,\tit cannot be "seen".`) {
+		t.Errorf("tab not escaped: %q", lines[1])
+	}
+	if !strings.Contains(lines[2], `Narration\nover two lines.`) {
+		t.Errorf("line break not escaped: %q", lines[2])
+	}
+}
+
+func TestWriteExport_Columnar(t *testing.T) {
+	var export struct {
+		Schema  []ExportColumn             `json:"schema"`
+		Rows    int                        `json:"rows"`
+		Columns map[string]json.RawMessage `json:"columns"`
+	}
+	if err := json.Unmarshal([]byte(writeTestExport(t, ExportColumnar)), &export); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if export.Rows != 2 || len(export.Schema) != len(ExportSchema) || len(export.Columns) != len(ExportSchema) {
+		t.Fatalf("got %d rows, %d schema columns and %d columns", export.Rows, len(export.Schema), len(export.Columns))
+	}
+	for i, col := range export.Schema {
+		if col.Name != ExportSchema[i].Name || col.Type != ExportSchema[i].Type {
+			t.Errorf("schema column %d: got %+v", i, col)
+		}
+	}
+	var episodes []int
+	if err := json.Unmarshal(export.Columns["episode"], &episodes); err != nil || len(episodes) != 2 {
+		t.Errorf("episode column: %s", export.Columns["episode"])
+	}
+	var voices [][]string
+	if err := json.Unmarshal(export.Columns["voice_audio_ids"], &voices); err != nil || len(voices) != 2 || len(voices[1]) != 0 {
+		t.Errorf("voice_audio_ids column: %s", export.Columns["voice_audio_ids"])
+	}
+}
+
+func TestParseExportFormat(t *testing.T) {
+	for _, name := range []string{"jsonl", "csv", "tsv", "columnar"} {
+		if f, err := ParseExportFormat(name); err != nil || string(f) != name {
+			t.Errorf("ParseExportFormat(%q) = %q, %v", name, f, err)
+		}
+	}
+	if _, err := ParseExportFormat("xlsx"); err == nil {
+		t.Error("expected an error for xlsx")
+	}
+}
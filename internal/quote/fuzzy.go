@@ -0,0 +1,297 @@
+package quote
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// fuzzyMaxScore caps near-miss scores so they always rank below exact hits,
+	// which are rescaled into the range above it in fuzzy mode.
+	fuzzyMaxScore = 50
+	// maxSuggestions limits the "did you mean" list.
+	maxSuggestions = 3
+)
+
+// TermMatch is a vocabulary term within a small edit distance of a query token.
+type TermMatch struct {
+	Term     string
+	Distance int
+}
+
+// maxEditsFor returns how many edits a token of this length may tolerate.
+// Very short tokens must match exactly or everything becomes a match.
+func maxEditsFor(token string) int {
+	n := utf8.RuneCountInString(token)
+	switch {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// trigrams returns the distinct trigrams of a term padded with '$' on both
+// ends, so short terms and word boundaries still produce grams.
+func trigrams(term string) []string {
+	runes := []rune("$" + term + "$")
+	if len(runes) < 3 {
+		return nil
+	}
+	seen := make(map[string]bool, len(runes))
+	grams := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		g := string(runes[i : i+3])
+		if !seen[g] {
+			seen[g] = true
+			grams = append(grams, g)
+		}
+	}
+	return grams
+}
+
+// levenshtein returns the edit distance between a and b, counted in runes.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// termSimilarity converts an edit distance into a 0-1 weight relative to the
+// length of the query token.
+func termSimilarity(token string, m TermMatch) float64 {
+	n := max(utf8.RuneCountInString(token), utf8.RuneCountInString(m.Term))
+	if n == 0 {
+		return 0
+	}
+	return 1 - float64(m.Distance)/float64(n)
+}
+
+func (ti *tokenIndex) DocFreq(term string) int {
+	return len(ti.postings[term])
+}
+
+func (ti *tokenIndex) SimilarTerms(token string, maxEdits int) []TermMatch {
+	if maxEdits <= 0 {
+		if _, ok := ti.postings[token]; ok {
+			return []TermMatch{{Term: token}}
+		}
+		return nil
+	}
+
+	grams := trigrams(token)
+	shared := make(map[string]int)
+	for _, g := range grams {
+		for _, term := range ti.trigramIndex[g] {
+			shared[term]++
+		}
+	}
+
+	// Each edit can destroy at most three trigrams, so anything sharing fewer
+	// than this cannot be within maxEdits.
+	need := len(grams) - 3*maxEdits
+	tokenLen := utf8.RuneCountInString(token)
+
+	var matches []TermMatch
+	for term, count := range shared {
+		if count < need {
+			continue
+		}
+		if diff := utf8.RuneCountInString(term) - tokenLen; diff > maxEdits || -diff > maxEdits {
+			continue
+		}
+		if d := levenshtein(token, term); d <= maxEdits {
+			matches = append(matches, TermMatch{Term: term, Distance: d})
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b TermMatch) int {
+		if c := cmp.Compare(a.Distance, b.Distance); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(ti.DocFreq(b.Term), ti.DocFreq(a.Term)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Term, b.Term)
+	})
+	return matches
+}
+
+func (ti *tokenIndex) IndicesWithAny(terms []string) []int {
+	var result []int
+	for _, term := range terms {
+		list := ti.postings[term]
+		docs := make([]int, len(list))
+		for i, p := range list {
+			docs[i] = p.doc
+		}
+		result = mergeIndices(result, docs)
+	}
+	return result
+}
+
+// fuzzySearch finds quotes where every query token matches some vocabulary
+// term within its edit budget. Quotes listed in exclude (the exact hits) are
+// skipped. Scores are BM25 weighted by term similarity, scaled to at most
+// fuzzyMaxScore.
+func fuzzySearch(tokens []string, tokenIdx TokenIndex, quotes []ParsedQuote, exclude []int, matchesFilter func(ParsedQuote) bool) []SearchResult {
+	if len(tokens) == 0 || tokenIdx == nil {
+		return nil
+	}
+
+	expansions := make([][]TermMatch, len(tokens))
+	var candidates []int
+	for i, tok := range tokens {
+		expansions[i] = tokenIdx.SimilarTerms(tok, maxEditsFor(tok))
+		if len(expansions[i]) == 0 {
+			return nil
+		}
+		terms := make([]string, len(expansions[i]))
+		for j, m := range expansions[i] {
+			terms[j] = m.Term
+		}
+		docs := tokenIdx.IndicesWithAny(terms)
+		if i == 0 {
+			candidates = docs
+		} else {
+			candidates = intersectIndices(candidates, docs)
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+	}
+
+	excluded := make(map[int]bool, len(exclude))
+	for _, idx := range exclude {
+		excluded[idx] = true
+	}
+
+	type scored struct {
+		idx int
+		raw float64
+	}
+
+	var ranked []scored
+	var best float64
+	for _, idx := range candidates {
+		if excluded[idx] || !matchesFilter(quotes[idx]) {
+			continue
+		}
+		var raw float64
+		for i, tok := range tokens {
+			var tokenBest float64
+			for _, m := range expansions[i] {
+				if s := tokenIdx.Score(idx, []string{m.Term}) * termSimilarity(tok, m); s > tokenBest {
+					tokenBest = s
+				}
+			}
+			raw += tokenBest
+		}
+		ranked = append(ranked, scored{idx: idx, raw: raw})
+		best = max(best, raw)
+	}
+
+	slices.SortStableFunc(ranked, func(a, b scored) int {
+		return cmp.Compare(b.raw, a.raw)
+	})
+
+	results := make([]SearchResult, len(ranked))
+	for i, r := range ranked {
+		score := fuzzyMaxScore
+		if best > 0 {
+			score = max(1, int(math.Round(r.raw/best*fuzzyMaxScore)))
+		}
+		results[i] = NewSearchResult(quotes[r.idx], score)
+		results[i].index = r.idx
+	}
+	return results
+}
+
+// suggestQueries builds "did you mean" alternatives by replacing each token
+// that never occurs in the corpus with its closest vocabulary terms.
+func suggestQueries(tokens []string, tokenIdx TokenIndex) []string {
+	if tokenIdx == nil {
+		return nil
+	}
+	// Bigram tokens can't be rejoined into a readable query.
+	for _, tok := range tokens {
+		if r := []rune(tok); len(r) > 0 && isCJK(r[0]) {
+			return nil
+		}
+	}
+
+	alternatives := make([][]string, len(tokens))
+	hasUnknown := false
+	for i, tok := range tokens {
+		if tokenIdx.DocFreq(tok) > 0 {
+			alternatives[i] = []string{tok}
+			continue
+		}
+		matches := tokenIdx.SimilarTerms(tok, max(1, maxEditsFor(tok)))
+		if len(matches) == 0 {
+			alternatives[i] = []string{tok}
+			continue
+		}
+		hasUnknown = true
+		for j := 0; j < len(matches) && j < maxSuggestions; j++ {
+			alternatives[i] = append(alternatives[i], matches[j].Term)
+		}
+	}
+	if !hasUnknown {
+		return nil
+	}
+
+	var suggestions []string
+	seen := make(map[string]bool)
+	for k := 0; k < maxSuggestions; k++ {
+		words := make([]string, len(tokens))
+		for i, alts := range alternatives {
+			words[i] = alts[min(k, len(alts)-1)]
+		}
+		s := strings.Join(words, " ")
+		if !seen[s] {
+			seen[s] = true
+			suggestions = append(suggestions, s)
+		}
+	}
+	return suggestions
+}
+
+// intersectIndices returns the indices present in both ascending slices.
+func intersectIndices(a []int, b []int) []int {
+	var result []int
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
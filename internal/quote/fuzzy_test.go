@@ -0,0 +1,152 @@
+package quote
+
+import "testing"
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"witch", "witch", 0},
+		{"batler", "battler", 1},
+		{"beatrix", "beatrice", 2},
+		{"lamdadelta", "lambdadelta", 1},
+		{"ベアトリーチェ", "ベアトリチェ", 1},
+		{"abc", "", 3},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		got := levenshtein(tests[i].a, tests[i].b)
+		if got != tests[i].want {
+			t.Errorf("levenshtein(%q, %q): got %d, want %d", tests[i].a, tests[i].b, got, tests[i].want)
+		}
+	}
+}
+
+func TestMaxEditsFor(t *testing.T) {
+	tests := []struct {
+		token string
+		want  int
+	}{
+		{"ab", 0},
+		{"ange", 1},
+		{"batler", 2},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		got := maxEditsFor(tests[i].token)
+		if got != tests[i].want {
+			t.Errorf("maxEditsFor(%q): got %d, want %d", tests[i].token, got, tests[i].want)
+		}
+	}
+}
+
+func TestTrigrams(t *testing.T) {
+	got := trigrams("ab")
+	want := []string{"$ab", "ab$"}
+
+	if len(got) != len(want) {
+		t.Fatalf("trigrams(ab): got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("trigram %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTokenIndex_SimilarTerms(t *testing.T) {
+	ti := newTokenIndex([]string{
+		"battler laughed",
+		"battler and beatrice",
+		"butler service",
+	})
+
+	matches := ti.SimilarTerms("batler", 2)
+	if len(matches) == 0 {
+		t.Fatal("expected similar terms for 'batler'")
+	}
+	if matches[0].Term != "battler" && matches[0].Term != "butler" {
+		t.Errorf("closest term: got %q", matches[0].Term)
+	}
+	if matches[0].Distance != 1 {
+		t.Errorf("closest distance: got %d, want 1", matches[0].Distance)
+	}
+	// Equal distance ties go to the more frequent term.
+	if matches[0].Term != "battler" {
+		t.Errorf("tie should prefer more frequent term, got %q", matches[0].Term)
+	}
+
+	if got := ti.SimilarTerms("xyz", 1); len(got) != 0 {
+		t.Errorf("SimilarTerms(xyz): got %v, want none", got)
+	}
+	if got := ti.SimilarTerms("battler", 0); len(got) != 1 || got[0].Term != "battler" {
+		t.Errorf("SimilarTerms with zero edits: got %v", got)
+	}
+}
+
+func TestTokenIndex_IndicesWithAny(t *testing.T) {
+	ti := newTokenIndex([]string{"red truth", "blue truth", "gold text", "red text"})
+
+	got := ti.IndicesWithAny([]string{"red", "gold"})
+	if len(got) != 3 || got[0] != 0 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("IndicesWithAny(red, gold): got %v, want [0 2 3]", got)
+	}
+}
+
+func TestFuzzySearch_ScoresBelowExactRange(t *testing.T) {
+	quotes := []ParsedQuote{
+		{Text: "Beatrice laughs"},
+		{Text: "Battler shouts"},
+		{Text: "The golden witch"},
+	}
+	lowerTexts := []string{"beatrice laughs", "battler shouts", "the golden witch"}
+	ti := newTokenIndex(lowerTexts)
+
+	results := fuzzySearch([]string{"beatrix"}, ti, quotes, nil, func(ParsedQuote) bool { return true })
+
+	if len(results) != 1 {
+		t.Fatalf("expected 1 fuzzy match, got %d", len(results))
+	}
+	if results[0].Quote.Text != "Beatrice laughs" {
+		t.Errorf("fuzzy match: got %q", results[0].Quote.Text)
+	}
+	if results[0].Score > fuzzyMaxScore || results[0].Score < 1 {
+		t.Errorf("fuzzy score %d outside [1, %d]", results[0].Score, fuzzyMaxScore)
+	}
+}
+
+func TestFuzzySearch_ExcludesExactHits(t *testing.T) {
+	quotes := []ParsedQuote{{Text: "Battler"}, {Text: "Batler"}}
+	ti := newTokenIndex([]string{"battler", "batler"})
+
+	results := fuzzySearch([]string{"batler"}, ti, quotes, []int{1}, func(ParsedQuote) bool { return true })
+
+	if len(results) != 1 || results[0].Quote.Text != "Battler" {
+		t.Errorf("expected only the non-excluded near miss, got %v", results)
+	}
+}
+
+func TestSuggestQueries(t *testing.T) {
+	ti := newTokenIndex([]string{"lambdadelta smiles", "bernkastel sighs", "lambdadelta and bernkastel"})
+
+	got := suggestQueries([]string{"lamdadelta", "smiles"}, ti)
+	if len(got) == 0 {
+		t.Fatal("expected a suggestion")
+	}
+	if got[0] != "lambdadelta smiles" {
+		t.Errorf("suggestion: got %q, want %q", got[0], "lambdadelta smiles")
+	}
+
+	if got := suggestQueries([]string{"bernkastel"}, ti); got != nil {
+		t.Errorf("known words should not produce suggestions, got %v", got)
+	}
+}
+
+func TestIntersectIndices(t *testing.T) {
+	got := intersectIndices([]int{1, 2, 4, 7}, []int{2, 3, 4, 8})
+	if len(got) != 2 || got[0] != 2 || got[1] != 4 {
+		t.Errorf("intersectIndices: got %v, want [2 4]", got)
+	}
+}
@@ -0,0 +1,275 @@
+package quote
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// An index snapshot holds the parsed scripts and their indexes, so a server
+// can start without parsing. It is a header followed by a gob-encoded
+// indexSnapshot:
+//
+//	magic          "UQIDX\n"
+//	version        uint32, big endian
+//	source hash    SHA-256 of the scripts it was built from; see scriptsHash
+//	payload hash   SHA-256 of the rest of the file
+//
+// A snapshot is only used when every part of its header matches, so a stale
+// or damaged one is rebuilt from the scripts instead.
+const indexSnapshotMagic = "UQIDX\n"
+
+// indexSnapshotVersion must be bumped whenever parsing or indexing changes
+// what it produces, as the source hash only covers the scripts.
+const indexSnapshotVersion = 2
+
+const indexSnapshotHeaderLen = len(indexSnapshotMagic) + 4 + 2*sha256.Size
+
+type (
+	indexSnapshot struct {
+		Languages []Language
+		Scripts   map[string]scriptIndexRecord
+	}
+
+	// scriptIndexRecord is one language's quotes and indexes. Quotes keep
+	// only their exported fields; the rest are used while indexing and
+	// their results are recorded here.
+	scriptIndexRecord struct {
+		Quotes      []ParsedQuote
+		LowerTexts  []string
+		Tokens      tokenIndexRecord
+		Characters  map[string][]int
+		Episodes    map[int][]int
+		NonNarrator []int
+		AudioIndex  map[string]int
+		Scenes      []sceneRecord
+		// Alignment maps each other language to the counterpart of every
+		// quote.
+		Alignment map[string][]int
+	}
+
+	tokenIndexRecord struct {
+		Postings  map[string]postingsRecord
+		Trigrams  map[string][]string
+		DocLens   []int
+		AvgDocLen float64
+	}
+
+	postingsRecord struct {
+		Docs  []int
+		Freqs []int
+	}
+
+	sceneRecord struct {
+		Scene      Scene
+		Start, End int
+	}
+)
+
+// WriteIndexSnapshot parses and indexes the scripts, laid out as for
+// NewServiceFromDir, and writes a snapshot of them to w.
+func WriteIndexSnapshot(w io.Writer, scripts fs.FS) error {
+	sourceHash, err := scriptsHash(scripts)
+	if err != nil {
+		return err
+	}
+	quotes, langs, err := parseScripts(scripts)
+	if err != nil {
+		return err
+	}
+	data, err := encodeIndexSnapshot(indexScripts(quotes), langs, sourceHash)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// readIndexSnapshot restores the index from the snapshot at path, if it was
+// written for the scripts as they are now.
+func readIndexSnapshot(path string, scripts fs.FS) (*indexer, []Language, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	sourceHash, err := scriptsHash(scripts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeIndexSnapshot(data, sourceHash)
+}
+
+// scriptsHash hashes the language manifest, the BGM titles and every script
+// the manifest names, in that order, each as its path, length and contents.
+func scriptsHash(scripts fs.FS) ([sha256.Size]byte, error) {
+	langs, err := readLanguages(scripts)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	paths := []string{languagesPath, bgmTitlesPath}
+	for _, lang := range langs {
+		paths = append(paths, lang.File)
+	}
+
+	h := sha256.New()
+	for _, path := range paths {
+		data, err := fs.ReadFile(scripts, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(h, "%s\x00-1\x00", path)
+			continue
+		}
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", path, len(data))
+		h.Write(data)
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum, nil
+}
+
+func encodeIndexSnapshot(idx *indexer, langs []Language, sourceHash [sha256.Size]byte) ([]byte, error) {
+	snapshot := indexSnapshot{
+		Languages: langs,
+		Scripts:   make(map[string]scriptIndexRecord, len(idx.quotes)),
+	}
+	for lang, quotes := range idx.quotes {
+		scenes := make([]sceneRecord, len(idx.scenes[lang]))
+		for i, scene := range idx.scenes[lang] {
+			scenes[i] = sceneRecord{Scene: scene, Start: scene.start, End: scene.end}
+		}
+		snapshot.Scripts[lang] = scriptIndexRecord{
+			Quotes:      quotes,
+			LowerTexts:  idx.quoteLowerTexts[lang],
+			Tokens:      tokenIndexToRecord(idx.tokenIndex[lang].(*tokenIndex)),
+			Characters:  idx.characterIndex[lang],
+			Episodes:    idx.episodeIndex[lang],
+			NonNarrator: idx.nonNarratorIndex[lang],
+			AudioIndex:  idx.audioIndex[lang],
+			Scenes:      scenes,
+			Alignment:   idx.alignment[lang],
+		}
+	}
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(&snapshot); err != nil {
+		return nil, err
+	}
+	payloadHash := sha256.Sum256(payload.Bytes())
+
+	data := make([]byte, 0, indexSnapshotHeaderLen+payload.Len())
+	data = append(data, indexSnapshotMagic...)
+	data = binary.BigEndian.AppendUint32(data, indexSnapshotVersion)
+	data = append(data, sourceHash[:]...)
+	data = append(data, payloadHash[:]...)
+	return append(data, payload.Bytes()...), nil
+}
+
+func decodeIndexSnapshot(data []byte, sourceHash [sha256.Size]byte) (*indexer, []Language, error) {
+	if len(data) < indexSnapshotHeaderLen || string(data[:len(indexSnapshotMagic)]) != indexSnapshotMagic {
+		return nil, nil, errors.New("not an index snapshot")
+	}
+	header := data[len(indexSnapshotMagic):indexSnapshotHeaderLen]
+	if version := binary.BigEndian.Uint32(header); version != indexSnapshotVersion {
+		return nil, nil, fmt.Errorf("index snapshot is version %d, want %d", version, indexSnapshotVersion)
+	}
+	if !bytes.Equal(header[4:4+sha256.Size], sourceHash[:]) {
+		return nil, nil, errors.New("index snapshot was built from different scripts")
+	}
+	payload := data[indexSnapshotHeaderLen:]
+	if payloadHash := sha256.Sum256(payload); !bytes.Equal(header[4+sha256.Size:], payloadHash[:]) {
+		return nil, nil, errors.New("index snapshot is corrupt")
+	}
+
+	var snapshot indexSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&snapshot); err != nil {
+		return nil, nil, fmt.Errorf("decoding index snapshot: %w", err)
+	}
+	if len(snapshot.Scripts) == 0 {
+		return nil, nil, errors.New("index snapshot holds no scripts")
+	}
+
+	idx := &indexer{
+		quoteLowerTexts:  make(map[string][]string),
+		tokenIndex:       make(map[string]TokenIndex),
+		characterIndex:   make(map[string]map[string][]int),
+		episodeIndex:     make(map[string]map[int][]int),
+		nonNarratorIndex: make(map[string][]int),
+		audioIndex:       make(map[string]map[string]int),
+		alignment:        make(map[string]map[string][]int),
+		scenes:           make(map[string][]Scene),
+		sceneIndex:       make(map[string]map[string]int),
+		quotes:           make(map[string][]ParsedQuote),
+	}
+	for lang, record := range snapshot.Scripts {
+		if len(record.LowerTexts) != len(record.Quotes) || len(record.Tokens.DocLens) != len(record.Quotes) {
+			return nil, nil, fmt.Errorf("index snapshot is inconsistent for %q", lang)
+		}
+		idx.quotes[lang] = record.Quotes
+		idx.quoteLowerTexts[lang] = record.LowerTexts
+		idx.tokenIndex[lang] = tokenIndexFromRecord(record.Tokens)
+		idx.characterIndex[lang] = record.Characters
+		idx.episodeIndex[lang] = record.Episodes
+		idx.nonNarratorIndex[lang] = record.NonNarrator
+		idx.audioIndex[lang] = record.AudioIndex
+		idx.alignment[lang] = record.Alignment
+
+		var scenes []Scene
+		idx.sceneIndex[lang] = make(map[string]int, len(record.Scenes))
+		for i, r := range record.Scenes {
+			if r.Start < 0 || r.Start > r.End || r.End > len(record.Quotes) {
+				return nil, nil, fmt.Errorf("index snapshot is inconsistent for %q", lang)
+			}
+			scene := r.Scene
+			scene.start, scene.end = r.Start, r.End
+			scenes = append(scenes, scene)
+			idx.sceneIndex[lang][scene.ID] = i
+		}
+		idx.scenes[lang] = scenes
+	}
+	return idx, snapshot.Languages, nil
+}
+
+func tokenIndexToRecord(ti *tokenIndex) tokenIndexRecord {
+	postings := make(map[string]postingsRecord, len(ti.postings))
+	for term, list := range ti.postings {
+		r := postingsRecord{Docs: make([]int, len(list)), Freqs: make([]int, len(list))}
+		for i, p := range list {
+			r.Docs[i], r.Freqs[i] = p.doc, p.freq
+		}
+		postings[term] = r
+	}
+	return tokenIndexRecord{
+		Postings:  postings,
+		Trigrams:  ti.trigramIndex,
+		DocLens:   ti.docLens,
+		AvgDocLen: ti.avgDocLen,
+	}
+}
+
+func tokenIndexFromRecord(r tokenIndexRecord) *tokenIndex {
+	ti := &tokenIndex{
+		postings:     make(map[string][]posting, len(r.Postings)),
+		trigramIndex: r.Trigrams,
+		docLens:      r.DocLens,
+		avgDocLen:    r.AvgDocLen,
+	}
+	if ti.trigramIndex == nil {
+		ti.trigramIndex = make(map[string][]string)
+	}
+	for term, record := range r.Postings {
+		list := make([]posting, len(record.Docs))
+		for i := range list {
+			list[i] = posting{doc: record.Docs[i], freq: record.Freqs[i]}
+		}
+		ti.postings[term] = list
+	}
+	return ti
+}
@@ -0,0 +1,130 @@
+package quote
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func writeTestSnapshot(t *testing.T, scripts fstest.MapFS) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteIndexSnapshot(&buf, scripts); err != nil {
+		t.Fatalf("WriteIndexSnapshot: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestIndexSnapshot_RoundTrip(t *testing.T) {
+	scripts := testScripts("A line worth indexing.")
+	data := writeTestSnapshot(t, scripts)
+	hash, err := scriptsHash(scripts)
+	if err != nil {
+		t.Fatalf("scriptsHash: %v", err)
+	}
+
+	got, langs, err := decodeIndexSnapshot(data, hash)
+	if err != nil {
+		t.Fatalf("decodeIndexSnapshot: %v", err)
+	}
+	quotes, wantLangs, _ := parseScripts(scripts)
+	want := indexScripts(quotes)
+
+	if !reflect.DeepEqual(langs, wantLangs) {
+		t.Errorf("languages: got %+v, want %+v", langs, wantLangs)
+	}
+	for lang := range want.quotes {
+		for i, q := range want.quotes[lang] {
+			// Only exported fields are kept.
+			q.label, q.chapter, q.background = "", "", ""
+			if !reflect.DeepEqual(got.quotes[lang][i], q) {
+				t.Errorf("%s quote %d: got %+v, want %+v", lang, i, got.quotes[lang][i], q)
+			}
+		}
+		gotTokens, wantTokens := got.tokenIndex[lang].(*tokenIndex), want.tokenIndex[lang].(*tokenIndex)
+		if !reflect.DeepEqual(gotTokens.postings, wantTokens.postings) || !reflect.DeepEqual(gotTokens.docLens, wantTokens.docLens) {
+			t.Errorf("%s token index differs", lang)
+		}
+	}
+	for name, pair := range map[string][2]any{
+		"lower texts": {got.quoteLowerTexts, want.quoteLowerTexts},
+		"characters":  {got.characterIndex, want.characterIndex},
+		"episodes":    {got.episodeIndex, want.episodeIndex},
+		"audio IDs":   {got.audioIndex, want.audioIndex},
+		"alignment":   {got.alignment, want.alignment},
+		"scenes":      {got.scenes, want.scenes},
+	} {
+		if !reflect.DeepEqual(pair[0], pair[1]) {
+			t.Errorf("%s: got %+v, want %+v", name, pair[0], pair[1])
+		}
+	}
+}
+
+func TestIndexSnapshot_Rejected(t *testing.T) {
+	scripts := testScripts("The original line.")
+	data := writeTestSnapshot(t, scripts)
+	hash, _ := scriptsHash(scripts)
+
+	edited := testScripts("An edited line.")
+	editedHash, _ := scriptsHash(edited)
+	if _, _, err := decodeIndexSnapshot(data, editedHash); err == nil {
+		t.Error("stale snapshot: expected an error")
+	}
+
+	corrupt := bytes.Clone(data)
+	corrupt[len(corrupt)-1] ^= 0xff
+	if _, _, err := decodeIndexSnapshot(corrupt, hash); err == nil {
+		t.Error("corrupt snapshot: expected an error")
+	}
+
+	oldVersion := bytes.Clone(data)
+	oldVersion[len(indexSnapshotMagic)+3]++
+	if _, _, err := decodeIndexSnapshot(oldVersion, hash); err == nil {
+		t.Error("other version: expected an error")
+	}
+
+	if _, _, err := decodeIndexSnapshot([]byte("not a snapshot"), hash); err == nil {
+		t.Error("garbage: expected an error")
+	}
+}
+
+func TestScriptsHash_CoversBGMTitles(t *testing.T) {
+	scripts := testScripts("A line.")
+	before, _ := scriptsHash(scripts)
+	scripts[bgmTitlesPath] = &fstest.MapFile{Data: []byte("21\tDread of the Grave\n")}
+	after, _ := scriptsHash(scripts)
+	if before == after {
+		t.Error("adding BGM titles did not change the hash")
+	}
+}
+
+func TestService_LoadsIndexSnapshot(t *testing.T) {
+	scripts := testScripts("Loaded from the snapshot.")
+	path := filepath.Join(t.TempDir(), "index.snapshot")
+	if err := os.WriteFile(path, writeTestSnapshot(t, scripts), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := newService(scripts, nil, path)
+	if err != nil {
+		t.Fatalf("newService: %v", err)
+	}
+	if got := quoteText(t, svc); got != "Loaded from the snapshot." {
+		t.Errorf("got %q", got)
+	}
+	if resp := svc.Search("snapshot", "en", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact); resp.Total != 1 {
+		t.Errorf("search: got %d results, want 1", resp.Total)
+	}
+
+	// Once the scripts change, the snapshot no longer applies.
+	scripts["english.txt"].Data = []byte(testScript("Parsed after an edit."))
+	if err := svc.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := quoteText(t, svc); got != "Parsed after an edit." {
+		t.Errorf("after edit: got %q", got)
+	}
+}
@@ -1,45 +1,68 @@
 package quote
 
 import (
-	"os"
-	"path/filepath"
+	"fmt"
 	"strings"
 	"sync"
+
+	"umineko_quote/internal/audio"
 )
 
 type (
 	Indexer interface {
 		LowerTexts(lang string) []string
+		TokenIndex(lang string) TokenIndex
 		FilteredIndices(lang string, characterID string, episode int) []int
 		CharacterIndices(lang string, characterID string) []int
 		NonNarratorIndices(lang string) []int
-		AudioFilePath(characterId string, audioId string) string
+		OpenAudio(characterID string, audioID string) (audio.Clip, error)
+		AudioMeta(characterID string, audioID string) (AudioMeta, bool)
 		QuoteIndex(lang string, audioID string) (int, bool)
+		Counterpart(fromLang string, toLang string, idx int) (int, bool)
+		Scenes(lang string) []Scene
+		Scene(lang string, sceneID string) (Scene, bool)
 		HasAudio() bool
 	}
 
 	indexer struct {
 		quoteLowerTexts  map[string][]string
+		tokenIndex       map[string]TokenIndex
 		characterIndex   map[string]map[string][]int
 		episodeIndex     map[string]map[int][]int
 		nonNarratorIndex map[string][]int
 		audioIndex       map[string]map[string]int
+		alignment        map[string]map[string][]int
+		scenes           map[string][]Scene
+		sceneIndex       map[string]map[string]int
 		quotes           map[string][]ParsedQuote
-		audioDir         string
+		audioStore       audio.AudioStore
+		audioMeta        map[string]AudioMeta
 		hasAudio         bool
 	}
 
 	langIndexResult struct {
 		lang           string
 		lowerTexts     []string
+		tokenIdx       *tokenIndex
 		charIdx        map[string][]int
 		epIdx          map[int][]int
 		nonNarratorIdx []int
 		audioIdx       map[string]int
+		scenes         []Scene
 	}
 )
 
-func NewIndexer(quotes map[string][]ParsedQuote, audioDir string) Indexer {
+// NewIndexer indexes quotes and, when audioStore is not nil, the voice
+// clips it holds.
+func NewIndexer(quotes map[string][]ParsedQuote, audioStore audio.AudioStore) Indexer {
+	idx := indexScripts(quotes)
+	idx.attachAudio(scanAudioCatalog(audioStore))
+	return idx
+}
+
+// indexScripts builds every index over quotes. The audio catalog is
+// attached separately, as it is not part of an index snapshot.
+func indexScripts(quotes map[string][]ParsedQuote) *indexer {
 	results := make(chan langIndexResult, len(quotes))
 	var wg sync.WaitGroup
 
@@ -52,7 +75,7 @@ func NewIndexer(quotes map[string][]ParsedQuote, audioDir string) Indexer {
 			var nonNarratorIdx []int
 
 			for i := 0; i < len(parsed); i++ {
-				lowerTexts[i] = strings.ToLower(parsed[i].Text)
+				lowerTexts[i] = normalizeText(parsed[i].Text)
 				charIdx[parsed[i].CharacterID] = append(charIdx[parsed[i].CharacterID], i)
 				if parsed[i].Episode > 0 {
 					epIdx[parsed[i].Episode] = append(epIdx[parsed[i].Episode], i)
@@ -65,15 +88,20 @@ func NewIndexer(quotes map[string][]ParsedQuote, audioDir string) Indexer {
 						audioIdx[id] = i
 					}
 				}
+				if parsed[i].ID != "" {
+					audioIdx[parsed[i].ID] = i
+				}
 			}
 
 			results <- langIndexResult{
 				lang:           lang,
 				lowerTexts:     lowerTexts,
+				tokenIdx:       newTokenIndex(lowerTexts),
 				charIdx:        charIdx,
 				epIdx:          epIdx,
 				nonNarratorIdx: nonNarratorIdx,
 				audioIdx:       audioIdx,
+				scenes:         buildScenes(parsed),
 			}
 		})
 	}
@@ -83,47 +111,59 @@ func NewIndexer(quotes map[string][]ParsedQuote, audioDir string) Indexer {
 		close(results)
 	}()
 
-	hasAudio := false
-	if audioDir != "" {
-		if entries, err := os.ReadDir(audioDir); err == nil {
-			for _, entry := range entries {
-				if entry.IsDir() {
-					subPath := filepath.Join(audioDir, entry.Name())
-					if files, err := os.ReadDir(subPath); err == nil && len(files) > 0 {
-						hasAudio = true
-						break
-					}
-				}
-			}
-		}
-	}
-
 	idx := &indexer{
 		quoteLowerTexts:  make(map[string][]string),
+		tokenIndex:       make(map[string]TokenIndex),
 		characterIndex:   make(map[string]map[string][]int),
 		episodeIndex:     make(map[string]map[int][]int),
 		nonNarratorIndex: make(map[string][]int),
 		audioIndex:       make(map[string]map[string]int),
+		alignment:        make(map[string]map[string][]int),
+		scenes:           make(map[string][]Scene),
+		sceneIndex:       make(map[string]map[string]int),
 		quotes:           quotes,
-		audioDir:         audioDir,
-		hasAudio:         hasAudio,
 	}
 
 	for r := range results {
 		idx.quoteLowerTexts[r.lang] = r.lowerTexts
+		idx.tokenIndex[r.lang] = r.tokenIdx
 		idx.characterIndex[r.lang] = r.charIdx
 		idx.episodeIndex[r.lang] = r.epIdx
 		idx.nonNarratorIndex[r.lang] = r.nonNarratorIdx
 		idx.audioIndex[r.lang] = r.audioIdx
+		idx.scenes[r.lang] = r.scenes
+		idx.sceneIndex[r.lang] = make(map[string]int, len(r.scenes))
+		for i, scene := range r.scenes {
+			idx.sceneIndex[r.lang][scene.ID] = i
+		}
+	}
+
+	for from := range quotes {
+		idx.alignment[from] = make(map[string][]int)
+		for to := range quotes {
+			if from != to {
+				idx.alignment[from][to] = alignQuotes(quotes[from], quotes[to], idx.audioIndex[to])
+			}
+		}
 	}
 
 	return idx
 }
 
+func (idx *indexer) attachAudio(catalog *audioCatalog) {
+	idx.audioStore = catalog.store
+	idx.audioMeta = catalog.meta
+	idx.hasAudio = catalog.hasAudio
+}
+
 func (idx *indexer) LowerTexts(lang string) []string {
 	return idx.quoteLowerTexts[lang]
 }
 
+func (idx *indexer) TokenIndex(lang string) TokenIndex {
+	return idx.tokenIndex[lang]
+}
+
 func (idx *indexer) CharacterIndices(lang string, characterID string) []int {
 	langCharIdx := idx.characterIndex[lang]
 	if langCharIdx == nil {
@@ -132,15 +172,16 @@ func (idx *indexer) CharacterIndices(lang string, characterID string) []int {
 	return langCharIdx[characterID]
 }
 
-func (idx *indexer) AudioFilePath(characterId string, audioId string) string {
-	if idx.audioDir == "" {
-		return ""
+func (idx *indexer) OpenAudio(characterID string, audioID string) (audio.Clip, error) {
+	if idx.audioStore == nil {
+		return nil, fmt.Errorf("%w: %s/%s", audio.ErrAudioNotFound, characterID, audioID)
 	}
-	path := filepath.Join(idx.audioDir, characterId, audioId+".ogg")
-	if _, err := os.Stat(path); err != nil {
-		return ""
-	}
-	return path
+	return idx.audioStore.Open(characterID, audioID)
+}
+
+func (idx *indexer) AudioMeta(characterID string, audioID string) (AudioMeta, bool) {
+	m, ok := idx.audioMeta[audioMetaKey(characterID, audioID)]
+	return m, ok
 }
 
 func (idx *indexer) NonNarratorIndices(lang string) []int {
@@ -156,6 +197,28 @@ func (idx *indexer) QuoteIndex(lang string, audioID string) (int, bool) {
 	return i, ok
 }
 
+// Counterpart returns the index of the quote in toLang that lines up with
+// quote i in fromLang.
+func (idx *indexer) Counterpart(fromLang string, toLang string, i int) (int, bool) {
+	aligned := idx.alignment[fromLang][toLang]
+	if i < 0 || i >= len(aligned) || aligned[i] < 0 {
+		return 0, false
+	}
+	return aligned[i], true
+}
+
+func (idx *indexer) Scenes(lang string) []Scene {
+	return idx.scenes[lang]
+}
+
+func (idx *indexer) Scene(lang string, sceneID string) (Scene, bool) {
+	i, ok := idx.sceneIndex[lang][sceneID]
+	if !ok {
+		return Scene{}, false
+	}
+	return idx.scenes[lang][i], true
+}
+
 func (idx *indexer) FilteredIndices(lang string, characterID string, episode int) []int {
 	hasChar := characterID != ""
 	hasEp := episode > 0
@@ -1,9 +1,12 @@
 package quote
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"umineko_quote/internal/audio"
 )
 
 func buildTestIndexer() (Indexer, map[string][]ParsedQuote) {
@@ -16,7 +19,7 @@ func buildTestIndexer() (Indexer, map[string][]ParsedQuote) {
 			{Text: "Episode three line", CharacterID: "27", Episode: 3},
 		},
 	}
-	return NewIndexer(quotes, ""), quotes
+	return NewIndexer(quotes, nil), quotes
 }
 
 func TestIndexer_LowerTexts(t *testing.T) {
@@ -176,24 +179,22 @@ func TestIndexer_FilteredIndices_UnknownLang(t *testing.T) {
 	}
 }
 
-func TestIndexer_AudioFilePath_EmptyDir(t *testing.T) {
+func TestIndexer_OpenAudio_NoStore(t *testing.T) {
 	idx, _ := buildTestIndexer()
 
-	path := idx.AudioFilePath("10", "10100001")
-	if path != "" {
-		t.Errorf("AudioFilePath with empty dir: got %q, want empty", path)
+	if _, err := idx.OpenAudio("10", "10100001"); !errors.Is(err, audio.ErrAudioNotFound) {
+		t.Errorf("OpenAudio without a store: got %v, want ErrAudioNotFound", err)
 	}
 }
 
-func TestIndexer_AudioFilePath_NonexistentFile(t *testing.T) {
+func TestIndexer_OpenAudio_NonexistentFile(t *testing.T) {
 	quotes := map[string][]ParsedQuote{
 		"en": {{Text: "test", CharacterID: "10", Episode: 1}},
 	}
-	idx := NewIndexer(quotes, "/nonexistent/audio/dir")
+	idx := NewIndexer(quotes, audio.NewDirStore("/nonexistent/audio/dir"))
 
-	path := idx.AudioFilePath("10", "10100001")
-	if path != "" {
-		t.Errorf("AudioFilePath with nonexistent file: got %q, want empty", path)
+	if _, err := idx.OpenAudio("10", "10100001"); !errors.Is(err, audio.ErrAudioNotFound) {
+		t.Errorf("OpenAudio with nonexistent file: got %v, want ErrAudioNotFound", err)
 	}
 }
 
@@ -205,7 +206,7 @@ func TestIndexer_QuoteIndex_Found(t *testing.T) {
 			{Text: "Third", CharacterID: "10", AudioID: "10100002"},
 		},
 	}
-	idx := NewIndexer(quotes, "")
+	idx := NewIndexer(quotes, nil)
 
 	i, ok := idx.QuoteIndex("en", "12700001")
 	if !ok {
@@ -232,7 +233,7 @@ func TestIndexer_QuoteIndex_CompositeIDs(t *testing.T) {
 			{Text: "Line two", CharacterID: "27", AudioID: "12700001"},
 		},
 	}
-	idx := NewIndexer(quotes, "")
+	idx := NewIndexer(quotes, nil)
 
 	i1, ok1 := idx.QuoteIndex("en", "10100001")
 	if !ok1 {
@@ -270,7 +271,7 @@ func TestIndexer_MultipleLangs(t *testing.T) {
 			{Text: "別の行", CharacterID: "27", Episode: 2},
 		},
 	}
-	idx := NewIndexer(quotes, "")
+	idx := NewIndexer(quotes, nil)
 
 	enTexts := idx.LowerTexts("en")
 	if len(enTexts) != 1 {
@@ -293,6 +294,33 @@ func TestIndexer_MultipleLangs(t *testing.T) {
 	}
 }
 
+func TestIndexer_Counterpart(t *testing.T) {
+	quotes := map[string][]ParsedQuote{
+		"en": {
+			{Text: "Narration", CharacterID: "narrator", Episode: 1, label: "umi1_1"},
+			{Text: "Battler!", CharacterID: "04", AudioID: "10400001", Episode: 1, label: "umi1_1"},
+		},
+		"ja": {
+			{Text: "戦人！", CharacterID: "04", AudioID: "10400001", Episode: 1, label: "umi1_1"},
+			{Text: "地の文", CharacterID: "narrator", Episode: 1, label: "umi1_1"},
+		},
+	}
+	idx := NewIndexer(quotes, nil)
+
+	if j, ok := idx.Counterpart("en", "ja", 0); !ok || j != 1 {
+		t.Errorf("narration: got (%d, %v), want (1, true)", j, ok)
+	}
+	if j, ok := idx.Counterpart("ja", "en", 0); !ok || j != 1 {
+		t.Errorf("voiced: got (%d, %v), want (1, true)", j, ok)
+	}
+	if _, ok := idx.Counterpart("en", "fr", 0); ok {
+		t.Error("expected no counterpart for unknown language")
+	}
+	if _, ok := idx.Counterpart("en", "ja", 5); ok {
+		t.Error("expected no counterpart for out-of-range index")
+	}
+}
+
 func TestIndexer_HasAudio_EmptyDir(t *testing.T) {
 	idx, _ := buildTestIndexer()
 
@@ -306,7 +334,7 @@ func TestIndexer_HasAudio_NonexistentDir(t *testing.T) {
 	quotes := map[string][]ParsedQuote{
 		"en": {{Text: "test", CharacterID: "10", Episode: 1}},
 	}
-	idx := NewIndexer(quotes, "/nonexistent/audio/dir")
+	idx := NewIndexer(quotes, audio.NewDirStore("/nonexistent/audio/dir"))
 
 	// Non-existent directory means no audio
 	if idx.HasAudio() {
@@ -319,7 +347,7 @@ func TestIndexer_HasAudio_EmptyExistingDir(t *testing.T) {
 	quotes := map[string][]ParsedQuote{
 		"en": {{Text: "test", CharacterID: "10", Episode: 1}},
 	}
-	idx := NewIndexer(quotes, dir)
+	idx := NewIndexer(quotes, audio.NewDirStore(dir))
 
 	// Empty existing directory means no audio files available
 	if idx.HasAudio() {
@@ -341,9 +369,26 @@ func TestIndexer_HasAudio_WithFiles(t *testing.T) {
 	quotes := map[string][]ParsedQuote{
 		"en": {{Text: "test", CharacterID: "10", Episode: 1}},
 	}
-	idx := NewIndexer(quotes, dir)
+	idx := NewIndexer(quotes, audio.NewDirStore(dir))
 
 	if !idx.HasAudio() {
 		t.Error("HasAudio with files: expected true")
 	}
 }
+
+func TestIndexer_TokenIndex(t *testing.T) {
+	idx, _ := buildTestIndexer()
+
+	ti := idx.TokenIndex("en")
+	if ti == nil {
+		t.Fatal("TokenIndex for en: got nil")
+	}
+	got := ti.IndicesWithAll([]string{"battler"})
+	if len(got) != 1 || got[0] != 3 {
+		t.Errorf("IndicesWithAll(battler): got %v, want [3]", got)
+	}
+
+	if idx.TokenIndex("fr") != nil {
+		t.Error("TokenIndex for unknown lang should be nil")
+	}
+}
@@ -0,0 +1,102 @@
+package quote
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// languagesPath is an optional manifest in the script directory listing the
+// languages to load. Each line holds a language code, the script file, the
+// display name and, optionally, the code of a language to fall back to for
+// lines the script lacks, separated by tabs:
+//
+//	en	english.txt	English
+//	es	spanish.txt	Español	en
+//
+// Blank lines and lines starting with # are skipped. Without a manifest,
+// defaultLanguages are loaded.
+const languagesPath = "languages.txt"
+
+// Language is a script the service can load.
+type Language struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// File is the script's path relative to the script directory.
+	File string `json:"-"`
+	// Fallback is the language that stands in for lines missing from this
+	// one, if any.
+	Fallback string `json:"fallback,omitempty"`
+}
+
+var defaultLanguages = []Language{
+	{Code: "en", Name: "English", File: "english.txt"},
+	{Code: "ja", Name: "日本語", File: "japanese.txt"},
+}
+
+// readLanguages reads the manifest from scripts, or returns
+// defaultLanguages when there is none.
+func readLanguages(scripts fs.FS) ([]Language, error) {
+	data, err := fs.ReadFile(scripts, languagesPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return defaultLanguages, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseLanguages(string(data))
+}
+
+func parseLanguages(data string) ([]Language, error) {
+	var langs []Language
+	seen := make(map[string]bool)
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if len(fields) < 3 || len(fields) > 4 || fields[0] == "" || fields[1] == "" || fields[2] == "" {
+			return nil, fmt.Errorf("%s line %d: want code, file, name and optional fallback separated by tabs", languagesPath, n+1)
+		}
+		lang := Language{Code: fields[0], File: fields[1], Name: fields[2]}
+		if len(fields) == 4 {
+			lang.Fallback = fields[3]
+		}
+		if seen[lang.Code] {
+			return nil, fmt.Errorf("%s line %d: language %q listed twice", languagesPath, n+1, lang.Code)
+		}
+		seen[lang.Code] = true
+		langs = append(langs, lang)
+	}
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("%s lists no languages", languagesPath)
+	}
+
+	for _, lang := range langs {
+		if lang.Fallback != "" && !seen[lang.Fallback] {
+			return nil, fmt.Errorf("%s: %s falls back to unlisted language %q", languagesPath, lang.Code, lang.Fallback)
+		}
+	}
+	return langs, nil
+}
+
+// fallbackChain lists the languages to try, in order, for a line missing
+// from lang. It stops before any language it would visit twice.
+func fallbackChain(langs []Language, lang string) []string {
+	fallbacks := make(map[string]string, len(langs))
+	for _, l := range langs {
+		fallbacks[l.Code] = l.Fallback
+	}
+	var chain []string
+	visited := map[string]bool{lang: true}
+	for next := fallbacks[lang]; next != "" && !visited[next]; next = fallbacks[next] {
+		visited[next] = true
+		chain = append(chain, next)
+	}
+	return chain
+}
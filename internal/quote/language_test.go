@@ -0,0 +1,127 @@
+package quote
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseLanguages(t *testing.T) {
+	langs, err := parseLanguages("# code\tfile\tname\tfallback\nen\tenglish.txt\tEnglish\n\nes\t spanish.txt \tEspañol\ten\n")
+	if err != nil {
+		t.Fatalf("parseLanguages: %v", err)
+	}
+	want := []Language{
+		{Code: "en", Name: "English", File: "english.txt"},
+		{Code: "es", Name: "Español", File: "spanish.txt", Fallback: "en"},
+	}
+	if !slices.Equal(langs, want) {
+		t.Errorf("got %+v, want %+v", langs, want)
+	}
+}
+
+func TestParseLanguages_Invalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":             "# nothing here\n",
+		"missing name":      "en\tenglish.txt\n",
+		"duplicate":         "en\tenglish.txt\tEnglish\nen\tother.txt\tOther\n",
+		"unlisted fallback": "es\tspanish.txt\tEspañol\ten\n",
+	} {
+		if _, err := parseLanguages(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReadLanguages_Default(t *testing.T) {
+	langs, err := readLanguages(fstest.MapFS{})
+	if err != nil {
+		t.Fatalf("readLanguages: %v", err)
+	}
+	if !slices.Equal(langs, defaultLanguages) {
+		t.Errorf("got %+v, want the default languages", langs)
+	}
+}
+
+func TestFallbackChain(t *testing.T) {
+	langs := []Language{
+		{Code: "en"},
+		{Code: "pt", Fallback: "es"},
+		{Code: "es", Fallback: "en"},
+		{Code: "a", Fallback: "b"},
+		{Code: "b", Fallback: "a"},
+	}
+
+	if got := fallbackChain(langs, "pt"); !slices.Equal(got, []string{"es", "en"}) {
+		t.Errorf("pt: got %v", got)
+	}
+	if got := fallbackChain(langs, "en"); len(got) != 0 {
+		t.Errorf("en: got %v", got)
+	}
+	if got := fallbackChain(langs, "a"); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("cycle: got %v", got)
+	}
+}
+
+func TestService_LanguageFallback(t *testing.T) {
+	scripts := fstest.MapFS{
+		"languages.txt": {Data: []byte("en\tenglish.txt\tEnglish\nes\tspanish.txt\tEspañol\ten\nru\trussian.txt\tРусский\n")},
+		"english.txt": {Data: []byte("new_episode 1\n" +
+			`d [lv 0*"10"*"10100001"]"First line."[\]` + "\n" +
+			`d [lv 0*"10"*"10100002"]"Second line."[\]` + "\n")},
+		"spanish.txt": {Data: []byte("new_episode 1\n" +
+			`d [lv 0*"10"*"10100001"]"Primera línea."[\]` + "\n")},
+	}
+	svc, err := newService(scripts, nil, "")
+	if err == nil || !strings.Contains(err.Error(), "russian.txt") {
+		t.Errorf("newService: got %v, want an error naming the missing script", err)
+	}
+
+	var codes []string
+	for _, lang := range svc.Languages() {
+		codes = append(codes, lang.Code)
+	}
+	if !slices.Equal(codes, []string{"en", "es"}) {
+		t.Errorf("languages: got %v, want the manifest order without the missing script", codes)
+	}
+	if svc.HasLanguage("ru") || svc.HasLanguage("ja") {
+		t.Error("HasLanguage: languages without a script should not be loaded")
+	}
+
+	if q := svc.GetByAudioID("es", "10100001"); q == nil || q.Text != "Primera línea." || q.FallbackLang != "" {
+		t.Errorf("translated line: got %+v", q)
+	}
+	q := svc.GetByAudioID("es", "10100002")
+	if q == nil || q.Text != "Second line." || q.FallbackLang != "en" {
+		t.Fatalf("missing line: got %+v, want the English line", q)
+	}
+	if english := svc.GetByAudioID("en", "10100002"); english.FallbackLang != "" {
+		t.Error("fallback marked the English quote itself")
+	}
+
+	ctx := svc.GetContext("es", "10100002", 5)
+	if ctx == nil || ctx.Quote.Text != "Second line." || ctx.Quote.FallbackLang != "en" || len(ctx.Before) != 1 {
+		t.Errorf("missing line context: got %+v", ctx)
+	}
+}
+
+func TestService_DefaultLanguage(t *testing.T) {
+	scripts := fstest.MapFS{
+		"languages.txt": {Data: []byte("es\tspanish.txt\tEspañol\nja\tjapanese.txt\t日本語\n")},
+		"spanish.txt": {Data: []byte("new_episode 1\n" +
+			`d [lv 0*"10"*"10100001"]"Primera línea."[\]` + "\n")},
+		"japanese.txt": {Data: []byte("new_episode 1\n" +
+			`d [lv 0*"10"*"10100001"]"最初の台詞。"[\]` + "\n")},
+	}
+	svc, err := newService(scripts, nil, "")
+	if err != nil {
+		t.Fatalf("newService: %v", err)
+	}
+	if got := svc.DefaultLanguage(); got != "es" {
+		t.Errorf("DefaultLanguage: got %q, want the first language in the manifest", got)
+	}
+	if q := svc.GetByAudioID("", "10100001"); q == nil || q.Text != "Primera línea." {
+		t.Errorf("no language: got %+v, want the Spanish line", q)
+	}
+}
@@ -0,0 +1,47 @@
+package quote
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	katakanaStart    = 'ァ'
+	katakanaEnd      = 'ヶ'
+	katakanaToHira   = 'ァ' - 'ぁ'
+	prolongedSoundJa = 'ー'
+)
+
+// normalizeText folds text into the form used for matching:
+//   - NFKC, so full-width ASCII and digits become half-width and half-width
+//     katakana becomes full-width (ｶﾞ → ガ)
+//   - katakana folded to hiragana (ベアトリーチェ → べあとりーちぇ)
+//   - the long-vowel mark ー dropped, since it is spelled inconsistently
+//   - lower case
+//
+// The same function must be applied to indexed text and to queries.
+func normalizeText(text string) string {
+	text = norm.NFKC.String(text)
+
+	var sb strings.Builder
+	sb.Grow(len(text))
+	for _, r := range text {
+		switch {
+		case r == prolongedSoundJa:
+			continue
+		case r >= katakanaStart && r <= katakanaEnd:
+			sb.WriteRune(r - katakanaToHira)
+		default:
+			sb.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return sb.String()
+}
+
+// isCJK reports whether r belongs to a script written without spaces, which
+// the tokenizer splits into character bigrams instead of words.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
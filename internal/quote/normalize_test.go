@@ -0,0 +1,37 @@
+package quote
+
+import "testing"
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Beatrice", "beatrice"},
+		{"ＢＥＡＴＲＩＣＥ", "beatrice"},
+		{"１９８６年", "1986年"},
+		{"ベアトリーチェ", "べあとりちぇ"},
+		{"ﾍﾞｱﾄﾘｰﾁｪ", "べあとりちぇ"},
+		{"べあとりーちぇ", "べあとりちぇ"},
+		{"魔女", "魔女"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeText(tt.input); got != tt.want {
+			t.Errorf("normalizeText(%q): got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsCJK(t *testing.T) {
+	for _, r := range "魔あア" {
+		if !isCJK(r) {
+			t.Errorf("isCJK(%q): got false, want true", r)
+		}
+	}
+	for _, r := range "a1!" {
+		if isCJK(r) {
+			t.Errorf("isCJK(%q): got true, want false", r)
+		}
+	}
+}
@@ -6,6 +6,8 @@ type (
 	}
 
 	ParsedQuote struct {
+		// ID identifies the quote whether or not it is voiced; see quoteID.
+		ID           string            `json:"id"`
 		Text         string            `json:"text"`
 		TextHtml     string            `json:"textHtml"`
 		CharacterID  string            `json:"characterId"`
@@ -13,10 +15,28 @@ type (
 		AudioID      string            `json:"audioId"`
 		AudioCharMap map[string]string `json:"audioCharMap,omitempty"`
 		AudioTextMap map[string]string `json:"audioTextMap,omitempty"`
-		Episode      int               `json:"episode"`
-		ContentType  string            `json:"contentType"`
-		HasRedTruth  bool              `json:"hasRedTruth,omitempty"`
-		HasBlueTruth bool              `json:"hasBlueTruth,omitempty"`
+		// AudioMeta holds clip metadata keyed by audio ID.
+		AudioMeta    map[string]AudioMeta `json:"audioMeta,omitempty"`
+		Episode      int                  `json:"episode"`
+		ContentType  string               `json:"contentType"`
+		HasRedTruth  bool                 `json:"hasRedTruth,omitempty"`
+		HasBlueTruth bool                 `json:"hasBlueTruth,omitempty"`
+		SceneID      string               `json:"sceneId,omitempty"`
+		BGM          string               `json:"bgm,omitempty"`
+		BGMTitle     string               `json:"bgmTitle,omitempty"`
+		SoundEffects []string             `json:"soundEffects,omitempty"`
+		// Present lists the IDs of characters whose sprites are on screen.
+		Present []string `json:"present,omitempty"`
+		// FallbackLang names the language a quote came from when it stands in
+		// for a line missing from the one requested.
+		FallbackLang string `json:"fallbackLang,omitempty"`
+
+		// label is the script label the quote appears under.
+		label string
+		// chapter and background are the chapter title and background in
+		// effect when the quote appears, used to describe its scene.
+		chapter    string
+		background string
 	}
 )
 
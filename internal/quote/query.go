@@ -0,0 +1,309 @@
+package quote
+
+import (
+	"strings"
+	"unicode"
+)
+
+type (
+	// Query is a parsed search expression. The grammar is:
+	//
+	//	query   := or
+	//	or      := and ( "OR" and )*
+	//	and     := unary ( ["AND"] unary )*
+	//	unary   := "NOT" unary | "-" primary | primary
+	//	primary := "(" or ")" | "phrase" | field:value | field:"phrase" | word
+	//
+	// Operators are only recognised in upper case so ordinary words like
+	// "and" still search as text. Supported fields are char (ID or name),
+	// ep, truth (red/blue) and type (episode/tea/ura/omake).
+	Query struct {
+		root queryNode
+		// Simple is true when the input used no query syntax at all and
+		// should be searched as one literal string.
+		Simple bool
+		// Terms holds the words and phrases that are not negated, used to
+		// rank the matches.
+		Terms []string
+	}
+
+	queryNode interface {
+		eval(e *queryEval) []int
+	}
+
+	termNode struct {
+		text string
+	}
+
+	phraseNode struct {
+		text string
+	}
+
+	fieldNode struct {
+		field string
+		value string
+	}
+
+	andNode struct {
+		children []queryNode
+	}
+
+	orNode struct {
+		children []queryNode
+	}
+
+	notNode struct {
+		child queryNode
+	}
+
+	queryTokenType int
+
+	queryToken struct {
+		typ   queryTokenType
+		value string
+	}
+
+	queryParser struct {
+		tokens []queryToken
+		pos    int
+		terms  []string
+	}
+)
+
+const (
+	queryTokenWord queryTokenType = iota
+	queryTokenPhrase
+	queryTokenField
+	queryTokenAnd
+	queryTokenOr
+	queryTokenNot
+	queryTokenMinus
+	queryTokenLParen
+	queryTokenRParen
+)
+
+var queryFields = map[string]string{
+	"char":      "char",
+	"character": "char",
+	"ep":        "ep",
+	"episode":   "ep",
+	"truth":     "truth",
+	"type":      "type",
+}
+
+// ParseQuery parses a search string. Parsing is lenient: unbalanced quotes
+// run to the end of the input, stray parentheses and dangling operators are
+// ignored, and unknown field prefixes are searched as plain text.
+func ParseQuery(input string) Query {
+	tokens := lexQuery(input)
+
+	simple := true
+	for _, tok := range tokens {
+		if tok.typ != queryTokenWord {
+			simple = false
+			break
+		}
+	}
+	if simple {
+		return Query{Simple: true, Terms: []string{normalizeText(input)}}
+	}
+
+	p := &queryParser{tokens: tokens}
+	root := p.parseOr(false)
+	for p.pos < len(p.tokens) {
+		// Stray closing parentheses end parseOr early; skip and keep going.
+		p.pos++
+		if next := p.parseOr(false); next != nil {
+			root = combineAnd(root, next)
+		}
+	}
+
+	return Query{root: root, Terms: p.terms}
+}
+
+func lexQuery(input string) []queryToken {
+	var tokens []queryToken
+	runes := []rune(input)
+	i := 0
+
+	readPhrase := func() string {
+		i++ // opening quote
+		start := i
+		for i < len(runes) && runes[i] != '"' {
+			i++
+		}
+		value := string(runes[start:i])
+		if i < len(runes) {
+			i++ // closing quote
+		}
+		return value
+	}
+
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{typ: queryTokenLParen})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{typ: queryTokenRParen})
+			i++
+		case r == '"':
+			tokens = append(tokens, queryToken{typ: queryTokenPhrase, value: readPhrase()})
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && (i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '('):
+			tokens = append(tokens, queryToken{typ: queryTokenMinus})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '"' {
+				i++
+			}
+			word := string(runes[start:i])
+
+			if colon := strings.IndexByte(word, ':'); colon > 0 {
+				if field, ok := queryFields[strings.ToLower(word[:colon])]; ok {
+					value := word[colon+1:]
+					if value == "" && i < len(runes) && runes[i] == '"' {
+						value = readPhrase()
+					}
+					tokens = append(tokens, queryToken{typ: queryTokenField, value: field + ":" + value})
+					continue
+				}
+			}
+
+			switch word {
+			case "AND":
+				tokens = append(tokens, queryToken{typ: queryTokenAnd})
+			case "OR":
+				tokens = append(tokens, queryToken{typ: queryTokenOr})
+			case "NOT":
+				tokens = append(tokens, queryToken{typ: queryTokenNot})
+			default:
+				tokens = append(tokens, queryToken{typ: queryTokenWord, value: word})
+			}
+		}
+	}
+	return tokens
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *queryParser) parseOr(negated bool) queryNode {
+	var children []queryNode
+	if n := p.parseAnd(negated); n != nil {
+		children = append(children, n)
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.typ != queryTokenOr {
+			break
+		}
+		p.pos++
+		if n := p.parseAnd(negated); n != nil {
+			children = append(children, n)
+		}
+	}
+
+	switch len(children) {
+	case 0:
+		return nil
+	case 1:
+		return children[0]
+	default:
+		return &orNode{children: children}
+	}
+}
+
+func (p *queryParser) parseAnd(negated bool) queryNode {
+	var root queryNode
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.typ == queryTokenOr || tok.typ == queryTokenRParen {
+			break
+		}
+		if tok.typ == queryTokenAnd {
+			p.pos++
+			continue
+		}
+		if n := p.parseUnary(negated); n != nil {
+			root = combineAnd(root, n)
+		}
+	}
+	return root
+}
+
+func (p *queryParser) parseUnary(negated bool) queryNode {
+	tok, _ := p.peek()
+	switch tok.typ {
+	case queryTokenNot, queryTokenMinus:
+		p.pos++
+		var child queryNode
+		if tok.typ == queryTokenNot {
+			child = p.parseUnary(!negated)
+		} else {
+			child = p.parsePrimary(!negated)
+		}
+		if child == nil {
+			return nil
+		}
+		return &notNode{child: child}
+	default:
+		return p.parsePrimary(negated)
+	}
+}
+
+func (p *queryParser) parsePrimary(negated bool) queryNode {
+	tok, ok := p.peek()
+	if !ok {
+		return nil
+	}
+	p.pos++
+
+	switch tok.typ {
+	case queryTokenLParen:
+		n := p.parseOr(negated)
+		if next, ok := p.peek(); ok && next.typ == queryTokenRParen {
+			p.pos++
+		}
+		return n
+	case queryTokenPhrase:
+		text := normalizeText(strings.TrimSpace(tok.value))
+		if text == "" {
+			return nil
+		}
+		if !negated {
+			p.terms = append(p.terms, text)
+		}
+		return &phraseNode{text: text}
+	case queryTokenField:
+		field, value, _ := strings.Cut(tok.value, ":")
+		return &fieldNode{field: field, value: strings.ToLower(strings.TrimSpace(value))}
+	case queryTokenWord:
+		text := normalizeText(tok.value)
+		if !negated {
+			p.terms = append(p.terms, text)
+		}
+		return &termNode{text: text}
+	default:
+		// Operators in a position where a term was expected are ignored.
+		return nil
+	}
+}
+
+func combineAnd(left queryNode, right queryNode) queryNode {
+	if left == nil {
+		return right
+	}
+	if and, ok := left.(*andNode); ok {
+		and.children = append(and.children, right)
+		return and
+	}
+	return &andNode{children: []queryNode{left, right}}
+}
@@ -0,0 +1,189 @@
+package quote
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// queryEval holds the per-language data a Query is evaluated against. Every
+// node evaluates to an ascending slice of quote indices.
+type queryEval struct {
+	lang       string
+	quotes     []ParsedQuote
+	lowerTexts []string
+	tokenIdx   TokenIndex
+	indexer    Indexer
+	all        []int
+}
+
+func newQueryEval(lang string, quotes []ParsedQuote, lowerTexts []string, tokenIdx TokenIndex, indexer Indexer) *queryEval {
+	all := make([]int, len(quotes))
+	for i := range all {
+		all[i] = i
+	}
+	return &queryEval{
+		lang:       lang,
+		quotes:     quotes,
+		lowerTexts: lowerTexts,
+		tokenIdx:   tokenIdx,
+		indexer:    indexer,
+		all:        all,
+	}
+}
+
+// matches returns the indices of quotes matching the query, in script order.
+func (q Query) matches(e *queryEval) []int {
+	if q.root == nil {
+		return nil
+	}
+	return q.root.eval(e)
+}
+
+func (e *queryEval) substring(text string) []int {
+	return concurrentExactSearch(e.all, e.lowerTexts, e.quotes, text, func(ParsedQuote) bool { return true })
+}
+
+func (e *queryEval) scan(match func(ParsedQuote) bool) []int {
+	var result []int
+	for i := range e.quotes {
+		if match(e.quotes[i]) {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func (n *termNode) eval(e *queryEval) []int {
+	return e.substring(n.text)
+}
+
+// A phrase matches either verbatim or as a consecutive run of tokens, so
+// "without love it cannot be seen" still finds "Without love, it cannot be seen."
+func (n *phraseNode) eval(e *queryEval) []int {
+	verbatim := e.substring(n.text)
+	if e.tokenIdx == nil {
+		return verbatim
+	}
+
+	tokens := e.tokenIdx.Tokenize(n.text)
+	if len(tokens) < 2 {
+		return verbatim
+	}
+
+	var sequenced []int
+	for _, idx := range e.tokenIdx.IndicesWithAll(tokens) {
+		if containsTokenRun(e.tokenIdx.Tokenize(e.lowerTexts[idx]), tokens) {
+			sequenced = append(sequenced, idx)
+		}
+	}
+	return mergeIndices(verbatim, sequenced)
+}
+
+func (n *fieldNode) eval(e *queryEval) []int {
+	switch n.field {
+	case "char":
+		if _, ok := CharacterNames[n.value]; ok {
+			return slices.Clone(e.indexer.CharacterIndices(e.lang, n.value))
+		}
+		var result []int
+		for id, name := range CharacterNames {
+			if n.value != "" && strings.Contains(strings.ToLower(name), n.value) {
+				result = mergeIndices(result, e.indexer.CharacterIndices(e.lang, id))
+			}
+		}
+		return result
+	case "ep":
+		ep, err := strconv.Atoi(n.value)
+		if err != nil || ep <= 0 {
+			return nil
+		}
+		return slices.Clone(e.indexer.FilteredIndices(e.lang, "", ep))
+	case "truth":
+		switch TruthAll.Parse(n.value) {
+		case TruthRed:
+			return e.scan(func(q ParsedQuote) bool { return q.HasRedTruth })
+		case TruthBlue:
+			return e.scan(func(q ParsedQuote) bool { return q.HasBlueTruth })
+		default:
+			return nil
+		}
+	case "type":
+		contentType := n.value
+		if contentType == "episode" || contentType == "main" {
+			contentType = ""
+		}
+		return e.scan(func(q ParsedQuote) bool { return q.ContentType == contentType })
+	default:
+		return nil
+	}
+}
+
+func (n *andNode) eval(e *queryEval) []int {
+	// Evaluate positive children first and subtract negated ones, so a
+	// query like `witch -char:27` never has to materialise the complement.
+	var result []int
+	var excluded [][]int
+	first := true
+	for _, child := range n.children {
+		if not, ok := child.(*notNode); ok {
+			excluded = append(excluded, not.child.eval(e))
+			continue
+		}
+		matches := child.eval(e)
+		if first {
+			result = matches
+			first = false
+		} else {
+			result = intersectIndices(result, matches)
+		}
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	if first {
+		result = e.all
+	}
+	for _, ex := range excluded {
+		result = subtractIndices(result, ex)
+	}
+	return result
+}
+
+func (n *orNode) eval(e *queryEval) []int {
+	var result []int
+	for _, child := range n.children {
+		result = mergeIndices(result, child.eval(e))
+	}
+	return result
+}
+
+func (n *notNode) eval(e *queryEval) []int {
+	return subtractIndices(e.all, n.child.eval(e))
+}
+
+// subtractIndices returns the indices of a that are not in b. Both must be ascending.
+func subtractIndices(a []int, b []int) []int {
+	var result []int
+	j := 0
+	for _, v := range a {
+		for j < len(b) && b[j] < v {
+			j++
+		}
+		if j < len(b) && b[j] == v {
+			continue
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+// containsTokenRun reports whether needle appears as a consecutive run in haystack.
+func containsTokenRun(haystack []string, needle []string) bool {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if slices.Equal(haystack[i:i+len(needle)], needle) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package quote
+
+import (
+	"slices"
+	"testing"
+)
+
+func buildTestQueryEval() *queryEval {
+	quotes := []ParsedQuote{
+		{Text: "Without love, it cannot be seen.", CharacterID: "27", Episode: 1},
+		{Text: "The witch doesn't exist!", CharacterID: "10", Episode: 1},
+		{Text: "I am the witch of miracles.", CharacterID: "28", Episode: 2},
+		{Text: "Nobody was hiding in the room.", CharacterID: "narrator", Episode: 2, HasRedTruth: true},
+		{Text: "Love is seen without eyes.", CharacterID: "narrator", Episode: 3},
+		{Text: "Tea time, witch!", CharacterID: "27", Episode: 3, ContentType: "tea"},
+	}
+	idx := NewIndexer(map[string][]ParsedQuote{"en": quotes}, nil)
+	return newQueryEval("en", quotes, idx.LowerTexts("en"), idx.TokenIndex("en"), idx)
+}
+
+func TestParseQuery_Simple(t *testing.T) {
+	q := ParseQuery("Golden Witch")
+
+	if !q.Simple {
+		t.Fatal("plain words should parse as a simple query")
+	}
+	if len(q.Terms) != 1 || q.Terms[0] != "golden witch" {
+		t.Errorf("Terms: got %v, want [golden witch]", q.Terms)
+	}
+}
+
+func TestParseQuery_LowercaseOperatorsAreWords(t *testing.T) {
+	q := ParseQuery("love and peace or not")
+
+	if !q.Simple {
+		t.Error("lowercase and/or/not should not be treated as operators")
+	}
+}
+
+func TestParseQuery_HyphenInsideWordIsNotExclusion(t *testing.T) {
+	q := ParseQuery("Kinzo-san")
+
+	if !q.Simple {
+		t.Error("hyphen inside a word should not start an exclusion")
+	}
+}
+
+func TestParseQuery_TermsSkipNegated(t *testing.T) {
+	q := ParseQuery(`"without love" witch -miracles NOT room`)
+
+	if q.Simple {
+		t.Fatal("query with phrases and exclusions should not be simple")
+	}
+	want := []string{"without love", "witch"}
+	if !slices.Equal(q.Terms, want) {
+		t.Errorf("Terms: got %v, want %v", q.Terms, want)
+	}
+}
+
+func TestQuery_Matches(t *testing.T) {
+	e := buildTestQueryEval()
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{`"without love it cannot be seen"`, []int{0}},
+		{`"without love"`, []int{0}},
+		{`witch -char:27`, []int{1, 2}},
+		{`witch NOT char:27`, []int{1, 2}},
+		{`witch AND ep:1`, []int{1}},
+		{`miracles OR room`, []int{2, 3}},
+		{`(miracles OR room) truth:red`, []int{3}},
+		{`truth:red`, []int{3}},
+		{`type:tea`, []int{5}},
+		{`char:narrator`, []int{3, 4}},
+		{`char:beatrice ep:3`, []int{5}},
+		{`-witch -char:narrator`, []int{0}},
+		{`NOT witch`, []int{0, 3, 4}},
+		{`char:"Ushiromiya Battler"`, []int{1}},
+		{`ep:notanumber`, nil},
+		{`"unterminated witch`, nil},
+		{`witch)`, []int{1, 2, 5}},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		got := ParseQuery(tests[i].query).matches(e)
+		if !slices.Equal(got, tests[i].want) {
+			t.Errorf("%s: got %v, want %v", tests[i].query, got, tests[i].want)
+		}
+	}
+}
+
+func TestSubtractIndices(t *testing.T) {
+	got := subtractIndices([]int{1, 2, 3, 4, 5}, []int{2, 4, 6})
+	if !slices.Equal(got, []int{1, 3, 5}) {
+		t.Errorf("subtractIndices: got %v, want [1 3 5]", got)
+	}
+}
+
+func TestContainsTokenRun(t *testing.T) {
+	haystack := []string{"without", "love", "it", "cannot", "be", "seen"}
+
+	if !containsTokenRun(haystack, []string{"love", "it"}) {
+		t.Error("expected consecutive run to match")
+	}
+	if containsTokenRun(haystack, []string{"love", "cannot"}) {
+		t.Error("non-consecutive tokens should not match")
+	}
+}
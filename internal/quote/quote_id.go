@@ -0,0 +1,47 @@
+package quote
+
+import (
+	"fmt"
+	"strings"
+)
+
+// quoteIDKey groups quotes for numbering: one run of ordinals per episode and
+// script label.
+type quoteIDKey struct {
+	episode int
+	label   string
+}
+
+// quoteID builds the stable ID of a quote from its episode, the script label
+// it appears under and its 1-based position among that label's quotes, e.g.
+// "e1_umi1_1_12". IDs only use [a-z0-9_], so they are accepted anywhere an
+// audio ID is, and never collide with audio IDs, which are plain digits.
+func quoteID(episode int, label string, ordinal int) string {
+	return fmt.Sprintf("e%d_%s_%d", episode, sanitizeLabel(label), ordinal)
+}
+
+// sanitizeLabel lowercases a script label and replaces anything outside
+// [a-z0-9_] with an underscore.
+func sanitizeLabel(label string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '_'
+		}
+	}, label)
+}
+
+// assignQuoteIDs sets ID on every quote. Numbering only depends on the order
+// of quotes in the script, so reparsing the same script yields the same IDs.
+func assignQuoteIDs(quotes []ParsedQuote) {
+	ordinals := make(map[quoteIDKey]int)
+	for i := range quotes {
+		key := quoteIDKey{episode: quotes[i].Episode, label: quotes[i].label}
+		ordinals[key]++
+		quotes[i].ID = quoteID(key.episode, key.label, ordinals[key])
+	}
+}
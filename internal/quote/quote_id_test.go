@@ -0,0 +1,82 @@
+package quote
+
+import "testing"
+
+func TestQuoteID(t *testing.T) {
+	tests := []struct {
+		episode int
+		label   string
+		ordinal int
+		want    string
+	}{
+		{1, "umi1_1", 12, "e1_umi1_1_12"},
+		{4, "o4_1", 1, "e4_o4_1_1"},
+		{0, "", 3, "e0__3"},
+		{2, "Ura-2", 5, "e2_ura_2_5"},
+	}
+
+	for _, tt := range tests {
+		if got := quoteID(tt.episode, tt.label, tt.ordinal); got != tt.want {
+			t.Errorf("quoteID(%d, %q, %d): got %q, want %q", tt.episode, tt.label, tt.ordinal, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteID_FitsAudioIDPattern(t *testing.T) {
+	id := quoteID(8, "umi8_12", 340)
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			t.Fatalf("quoteID %q contains %q", id, r)
+		}
+	}
+}
+
+func TestAssignQuoteIDs(t *testing.T) {
+	quotes := []ParsedQuote{
+		{Episode: 1, label: "umi1_1"},
+		{Episode: 1, label: "umi1_1", AudioID: "10100001"},
+		{Episode: 1, label: "umi1_2"},
+		{Episode: 1, label: "umi1_1"},
+	}
+
+	assignQuoteIDs(quotes)
+
+	want := []string{"e1_umi1_1_1", "e1_umi1_1_2", "e1_umi1_2_1", "e1_umi1_1_3"}
+	for i := range want {
+		if quotes[i].ID != want[i] {
+			t.Errorf("quote %d: got %q, want %q", i, quotes[i].ID, want[i])
+		}
+	}
+}
+
+func TestParseAll_QuoteIDsStableAndUnique(t *testing.T) {
+	lines := []string{
+		"*umi1_1",
+		"new_episode 1",
+		"d `The sea was calm.`[\\]",
+		`d [lv 0*"10"*"10100001"]` + "`\"Hello!\"`" + `[\]`,
+		"d `{p:1:Nobody was hiding inside the room.}`[\\]",
+		"*umi1_2",
+		"d `The storm raged on.`[\\]",
+	}
+
+	first := NewParser().ParseAll(lines)
+	second := NewParser().ParseAll(lines)
+
+	seen := make(map[string]bool)
+	for i := range first {
+		if first[i].ID == "" {
+			t.Errorf("quote %d has no ID", i)
+		}
+		if first[i].ID != second[i].ID {
+			t.Errorf("quote %d: ID changed on reparse: %q then %q", i, first[i].ID, second[i].ID)
+		}
+		if seen[first[i].ID] {
+			t.Errorf("duplicate ID %q", first[i].ID)
+		}
+		seen[first[i].ID] = true
+	}
+	if first[2].ID != "e1_umi1_1_3" {
+		t.Errorf("red truth narration ID: got %q, want %q", first[2].ID, "e1_umi1_1_3")
+	}
+}
@@ -0,0 +1,101 @@
+package quote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxRegexLength caps the length of a regex search pattern.
+	MaxRegexLength = 256
+	// regexTimeBudget bounds how long one regex search may scan before it
+	// returns what it has found so far.
+	regexTimeBudget = 2 * time.Second
+	// regexCheckInterval is how many quotes a worker scans between deadline checks.
+	regexCheckInterval = 256
+)
+
+var (
+	ErrEmptyPattern   = errors.New("regex pattern is empty")
+	ErrPatternTooLong = fmt.Errorf("regex pattern exceeds %d characters", MaxRegexLength)
+)
+
+// CompileSearchRegex validates and compiles an RE2 pattern for regex search.
+func CompileSearchRegex(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, ErrEmptyPattern
+	}
+	if len(pattern) > MaxRegexLength {
+		return nil, ErrPatternTooLong
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	return re, nil
+}
+
+// concurrentRegexSearch matches re against the plain text (or HTML) of each
+// quote. It stops early when ctx is done, reporting truncated=true with the
+// matches found so far.
+func concurrentRegexSearch(ctx context.Context, indices []int, quotes []ParsedQuote, re *regexp.Regexp, html bool, matchesFilter func(ParsedQuote) bool) (matches []int, truncated bool) {
+	numWorkers := runtime.NumCPU()
+	total := len(indices)
+	if total == 0 {
+		return nil, false
+	}
+	if numWorkers > total {
+		numWorkers = total
+	}
+
+	type chunk struct {
+		start, end int
+	}
+	chunkSize := (total + numWorkers - 1) / numWorkers
+	chunks := make([]chunk, 0, numWorkers)
+	for i := 0; i < total; i += chunkSize {
+		end := i + chunkSize
+		if end > total {
+			end = total
+		}
+		chunks = append(chunks, chunk{i, end})
+	}
+
+	resultSlices := make([][]int, len(chunks))
+	stopped := make([]bool, len(chunks))
+	var wg sync.WaitGroup
+
+	for w, c := range chunks {
+		wg.Go(func() {
+			var local []int
+			for j := c.start; j < c.end; j++ {
+				if (j-c.start)%regexCheckInterval == 0 && ctx.Err() != nil {
+					stopped[w] = true
+					break
+				}
+				idx := indices[j]
+				text := quotes[idx].Text
+				if html {
+					text = quotes[idx].TextHtml
+				}
+				if re.MatchString(text) && matchesFilter(quotes[idx]) {
+					local = append(local, idx)
+				}
+			}
+			resultSlices[w] = local
+		})
+	}
+
+	wg.Wait()
+
+	for w, s := range resultSlices {
+		matches = append(matches, s...)
+		truncated = truncated || stopped[w]
+	}
+	return matches, truncated
+}
@@ -0,0 +1,82 @@
+package quote
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompileSearchRegex(t *testing.T) {
+	if _, err := CompileSearchRegex(`-(san|sama)\b`); err != nil {
+		t.Errorf("valid pattern: unexpected error %v", err)
+	}
+
+	if _, err := CompileSearchRegex(""); !errors.Is(err, ErrEmptyPattern) {
+		t.Errorf("empty pattern: got %v, want ErrEmptyPattern", err)
+	}
+
+	if _, err := CompileSearchRegex(strings.Repeat("a", MaxRegexLength+1)); !errors.Is(err, ErrPatternTooLong) {
+		t.Errorf("long pattern: got %v, want ErrPatternTooLong", err)
+	}
+
+	_, err := CompileSearchRegex(`(unclosed`)
+	if err == nil {
+		t.Fatal("invalid pattern: expected error")
+	}
+	if !strings.Contains(err.Error(), "invalid regex pattern") {
+		t.Errorf("invalid pattern error: got %q", err.Error())
+	}
+}
+
+func TestConcurrentRegexSearch(t *testing.T) {
+	quotes := []ParsedQuote{
+		{Text: "Battler-san!", TextHtml: "Battler-san!"},
+		{Text: "Shannon-chan.", TextHtml: "Shannon-chan."},
+		{Text: "Welcome, Battler-sama.", TextHtml: `<span class="red-truth">Welcome</span>, Battler-sama.`, CharacterID: "15"},
+	}
+	re, _ := CompileSearchRegex(`-(san|sama)\b`)
+
+	matches, truncated := concurrentRegexSearch(context.Background(), []int{0, 1, 2}, quotes, re, false, func(ParsedQuote) bool { return true })
+	if truncated {
+		t.Error("search should not be truncated")
+	}
+	if len(matches) != 2 || matches[0] != 0 || matches[1] != 2 {
+		t.Errorf("matches: got %v, want [0 2]", matches)
+	}
+
+	matches, _ = concurrentRegexSearch(context.Background(), []int{0, 1, 2}, quotes, re, false, func(q ParsedQuote) bool { return q.CharacterID == "15" })
+	if len(matches) != 1 || matches[0] != 2 {
+		t.Errorf("filtered matches: got %v, want [2]", matches)
+	}
+}
+
+func TestConcurrentRegexSearch_HTML(t *testing.T) {
+	quotes := []ParsedQuote{
+		{Text: "Welcome", TextHtml: `<span class="red-truth">Welcome</span>`},
+		{Text: "Welcome", TextHtml: "Welcome"},
+	}
+	re, _ := CompileSearchRegex(`red-truth`)
+
+	if matches, _ := concurrentRegexSearch(context.Background(), []int{0, 1}, quotes, re, false, func(ParsedQuote) bool { return true }); len(matches) != 0 {
+		t.Errorf("plain text should not contain markup, got %v", matches)
+	}
+	if matches, _ := concurrentRegexSearch(context.Background(), []int{0, 1}, quotes, re, true, func(ParsedQuote) bool { return true }); len(matches) != 1 {
+		t.Errorf("HTML matches: got %v, want [0]", matches)
+	}
+}
+
+func TestConcurrentRegexSearch_ExpiredBudget(t *testing.T) {
+	quotes := []ParsedQuote{{Text: "a"}, {Text: "a"}}
+	re, _ := CompileSearchRegex(`a`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	matches, truncated := concurrentRegexSearch(ctx, []int{0, 1}, quotes, re, false, func(ParsedQuote) bool { return true })
+	if !truncated {
+		t.Error("expected truncated search when the budget is spent")
+	}
+	if len(matches) != 0 {
+		t.Errorf("expected no matches after cancellation, got %v", matches)
+	}
+}
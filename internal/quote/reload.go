@@ -0,0 +1,251 @@
+package quote
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"umineko_quote/internal/audio"
+)
+
+type (
+	// ReloadableService is a Service whose scripts can be reparsed while it
+	// serves. Its own methods answer from whichever snapshot is current at
+	// the time of each call.
+	ReloadableService interface {
+		Service
+		// Snapshot returns the scripts as currently loaded. Its answers never
+		// change, so a request making several calls should make them all on
+		// one snapshot.
+		Snapshot() Service
+		// Reload reparses and reindexes the scripts, then swaps them in. On
+		// error the current snapshot is kept.
+		Reload() error
+		// Watch reloads the scripts whenever their files change, checking
+		// every interval until ctx is done.
+		Watch(ctx context.Context, interval time.Duration)
+	}
+
+	service struct {
+		scripts fs.FS
+		// snapshotPath is the index snapshot to try before parsing scripts.
+		snapshotPath string
+		catalog      *audioCatalog
+		current      atomic.Pointer[snapshot]
+		// reloading serialises reloads; readers never wait on it.
+		reloading sync.Mutex
+		// attempted stamps the script files as last parsed, whether or
+		// not that parse succeeded. It is guarded by reloading.
+		attempted map[string]scriptStamp
+	}
+)
+
+// NewServiceFromDir is NewService for scripts read from dir, which holds
+// english.txt, japanese.txt and optionally bgm.txt. Unlike the embedded
+// scripts these can be fixed without a rebuild and picked up by Reload or
+// Watch.
+func NewServiceFromDir(dir string, audioStore audio.AudioStore, snapshotPath string) (ReloadableService, error) {
+	return newService(os.DirFS(dir), audioStore, snapshotPath)
+}
+
+// newService indexes scripts while scanning the audio store. It returns a
+// usable service even on error, holding whatever was parsed.
+func newService(scripts fs.FS, audioStore audio.AudioStore, snapshotPath string) (*service, error) {
+	s := &service{scripts: scripts, snapshotPath: snapshotPath}
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		s.catalog = scanAudioCatalog(audioStore)
+	})
+	s.attempted = s.stampScripts()
+	indexer, langs, err := loadScripts(scripts, snapshotPath)
+	wg.Wait()
+
+	if s.catalog.hasAudio {
+		log.Printf("[audio] audio features enabled")
+	} else {
+		log.Printf("[audio] no audio files found, disabling audio features")
+	}
+
+	s.current.Store(newSnapshot(indexer, langs, s.catalog))
+	return s, err
+}
+
+func (s *service) Snapshot() Service {
+	return s.current.Load()
+}
+
+func (s *service) Reload() error {
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+
+	start := time.Now()
+	s.attempted = s.stampScripts()
+	indexer, langs, err := loadScripts(s.scripts, s.snapshotPath)
+	if err != nil {
+		return err
+	}
+	// A script that fails to read mid-edit would otherwise drop its
+	// language until the next reload. Languages taken out of the manifest
+	// are dropped on purpose.
+	current := s.current.Load()
+	for _, lang := range langs {
+		if current.HasLanguage(lang.Code) && len(indexer.quotes[lang.Code]) == 0 {
+			return fmt.Errorf("%s script is missing or empty", lang.File)
+		}
+	}
+
+	s.current.Store(newSnapshot(indexer, langs, s.catalog))
+	log.Printf("[scripts] reloaded in %v", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// scriptStamp identifies a version of a script file.
+type scriptStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// stampScripts records the size and modification time of the manifest and
+// every file it names, so a change to any of them can be noticed.
+func (s *service) stampScripts() map[string]scriptStamp {
+	paths := []string{languagesPath, bgmTitlesPath}
+	// A broken manifest is reported by the reload its change triggers.
+	langs, _ := readLanguages(s.scripts)
+	for _, lang := range langs {
+		paths = append(paths, lang.File)
+	}
+
+	stamps := make(map[string]scriptStamp, len(paths))
+	for _, path := range paths {
+		if info, err := fs.Stat(s.scripts, path); err == nil {
+			stamps[path] = scriptStamp{info.Size(), info.ModTime()}
+		}
+	}
+	return stamps
+}
+
+func (s *service) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var previous map[string]scriptStamp
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		stamps := s.stampScripts()
+		// Wait for the files to hold still for a whole interval, so a save
+		// in progress is not parsed half written.
+		settled := mapsEqual(stamps, previous)
+		previous = stamps
+		if !settled || !s.changedSince(stamps) {
+			continue
+		}
+		if err := s.Reload(); err != nil {
+			log.Printf("[scripts] reload failed, keeping the previous scripts: %v", err)
+		}
+	}
+}
+
+// changedSince reports whether stamps differ from the scripts as last
+// parsed.
+func (s *service) changedSince(stamps map[string]scriptStamp) bool {
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+	return !mapsEqual(stamps, s.attempted)
+}
+
+func mapsEqual(a, b map[string]scriptStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !v.modTime.Equal(w.modTime) || v.size != w.size {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *service) Search(query string, lang string, limit int, offset int, characterID string, episode int, truth Truth, bgm string, present []string, mode SearchMode) SearchResponse {
+	return s.current.Load().Search(query, lang, limit, offset, characterID, episode, truth, bgm, present, mode)
+}
+
+func (s *service) SearchRegex(pattern string, lang string, limit int, offset int, characterID string, episode int, truth Truth, bgm string, present []string, html bool) (SearchResponse, error) {
+	return s.current.Load().SearchRegex(pattern, lang, limit, offset, characterID, episode, truth, bgm, present, html)
+}
+
+func (s *service) Browse(lang string, characterID string, limit int, offset int, episode int, truth Truth) CharacterResponse {
+	return s.current.Load().Browse(lang, characterID, limit, offset, episode, truth)
+}
+
+func (s *service) GetByCharacter(lang string, characterID string, limit int, offset int, episode int, truth Truth) CharacterResponse {
+	return s.current.Load().GetByCharacter(lang, characterID, limit, offset, episode, truth)
+}
+
+func (s *service) GetByAudioID(lang string, audioID string) *ParsedQuote {
+	return s.current.Load().GetByAudioID(lang, audioID)
+}
+
+func (s *service) GetContext(lang string, audioID string, lines int) *ContextResponse {
+	return s.current.Load().GetContext(lang, audioID, lines)
+}
+
+func (s *service) GetParallel(audioID string) map[string]ParsedQuote {
+	return s.current.Load().GetParallel(audioID)
+}
+
+func (s *service) GetScenes(lang string, episode int) []Scene {
+	return s.current.Load().GetScenes(lang, episode)
+}
+
+func (s *service) GetScene(lang string, sceneID string) *SceneResponse {
+	return s.current.Load().GetScene(lang, sceneID)
+}
+
+func (s *service) WithParallel(lang string, results []SearchResult) []SearchResult {
+	return s.current.Load().WithParallel(lang, results)
+}
+
+func (s *service) Random(lang string, characterID string, episode int, truth Truth) *ParsedQuote {
+	return s.current.Load().Random(lang, characterID, episode, truth)
+}
+
+func (s *service) GetCharacters() map[string]string {
+	return s.current.Load().GetCharacters()
+}
+
+func (s *service) OpenAudio(characterID string, audioID string) (audio.Clip, error) {
+	return s.current.Load().OpenAudio(characterID, audioID)
+}
+
+func (s *service) GetAudioMeta(characterID string, audioID string) *AudioMeta {
+	return s.current.Load().GetAudioMeta(characterID, audioID)
+}
+
+func (s *service) GetStats() Stats {
+	return s.current.Load().GetStats()
+}
+
+func (s *service) HasAudio() bool {
+	return s.current.Load().HasAudio()
+}
+
+func (s *service) Languages() []Language {
+	return s.current.Load().Languages()
+}
+
+func (s *service) HasLanguage(lang string) bool {
+	return s.current.Load().HasLanguage(lang)
+}
+
+func (s *service) DefaultLanguage() string {
+	return s.current.Load().DefaultLanguage()
+}
@@ -0,0 +1,110 @@
+package quote
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testScript(text string) string {
+	return "new_episode 1\n" + `d [lv 0*"10"*"10100001"]"` + text + `"[\]` + "\n"
+}
+
+func testScripts(en string) fstest.MapFS {
+	return fstest.MapFS{
+		"english.txt":  {Data: []byte(testScript(en))},
+		"japanese.txt": {Data: []byte(testScript("Japanese line."))},
+	}
+}
+
+func quoteText(t *testing.T, svc Service) string {
+	t.Helper()
+	q := svc.GetByAudioID("en", "10100001")
+	if q == nil {
+		t.Fatal("expected quote 10100001")
+	}
+	return q.Text
+}
+
+func TestService_Reload(t *testing.T) {
+	scripts := testScripts("A typo in the original.")
+	svc, err := newService(scripts, nil, "")
+	if err != nil {
+		t.Fatalf("newService: %v", err)
+	}
+	before := svc.Snapshot()
+
+	scripts["english.txt"].Data = []byte(testScript("The corrected line."))
+	if err := svc.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if got := quoteText(t, svc); got != "The corrected line." {
+		t.Errorf("after reload: got %q", got)
+	}
+	if got := quoteText(t, before); got != "A typo in the original." {
+		t.Errorf("earlier snapshot changed: got %q", got)
+	}
+	if resp := svc.Search("corrected", "en", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact); resp.Total != 1 {
+		t.Errorf("search after reload: got %d results, want 1", resp.Total)
+	}
+}
+
+func TestService_Reload_KeepsSnapshotOnError(t *testing.T) {
+	scripts := testScripts("The original line.")
+	svc, err := newService(scripts, nil, "")
+	if err != nil {
+		t.Fatalf("newService: %v", err)
+	}
+
+	delete(scripts, "english.txt")
+	if err := svc.Reload(); err == nil {
+		t.Fatal("expected an error when a script goes missing")
+	}
+	if got := quoteText(t, svc); got != "The original line." {
+		t.Errorf("after failed reload: got %q", got)
+	}
+}
+
+func TestNewServiceFromDir_NoScripts(t *testing.T) {
+	if _, err := NewServiceFromDir(t.TempDir(), nil, ""); err == nil {
+		t.Fatal("expected an error for a directory without scripts")
+	}
+}
+
+func TestService_Watch(t *testing.T) {
+	dir := t.TempDir()
+	for name, file := range testScripts("Before the edit.") {
+		if err := os.WriteFile(filepath.Join(dir, name), file.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc, err := NewServiceFromDir(dir, nil, "")
+	if err != nil {
+		t.Fatalf("NewServiceFromDir: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go svc.Watch(ctx, 10*time.Millisecond)
+
+	path := filepath.Join(dir, "english.txt")
+	if err := os.WriteFile(path, []byte(testScript("After the edit, longer.")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Make the change visible even where mtimes are coarse.
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for quoteText(t, svc) != "After the edit, longer." {
+		if time.Now().After(deadline) {
+			t.Fatal("watch did not pick up the edited script")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
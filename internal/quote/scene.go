@@ -0,0 +1,77 @@
+package quote
+
+import "fmt"
+
+type (
+	// Scene is a run of consecutive quotes under one script label.
+	Scene struct {
+		ID          string   `json:"id"`
+		Episode     int      `json:"episode"`
+		ContentType string   `json:"contentType"`
+		Chapter     string   `json:"chapter,omitempty"`
+		Backgrounds []string `json:"backgrounds,omitempty"`
+		QuoteCount  int      `json:"quoteCount"`
+
+		// start and end bound the scene's quotes in the script, end exclusive.
+		start int
+		end   int
+	}
+
+	SceneResponse struct {
+		Scene
+		Quotes []ParsedQuote `json:"quotes"`
+	}
+)
+
+// assignSceneIDs sets SceneID on every quote that appears under a label.
+// The ID is the label itself, so it survives reparsing; a label that comes
+// back after another one gets a numeric suffix so IDs stay unique.
+func assignSceneIDs(quotes []ParsedQuote) {
+	seen := make(map[string]int)
+	current := ""
+	for i := range quotes {
+		label := quotes[i].label
+		if label == "" {
+			continue
+		}
+		if i == 0 || quotes[i-1].label != label {
+			base := sanitizeLabel(label)
+			seen[base]++
+			current = base
+			if seen[base] > 1 {
+				current = fmt.Sprintf("%s_%d", base, seen[base])
+			}
+		}
+		quotes[i].SceneID = current
+	}
+}
+
+// buildScenes groups quotes into scenes by SceneID. Quotes before the first
+// label belong to no scene.
+func buildScenes(quotes []ParsedQuote) []Scene {
+	var scenes []Scene
+	for i := range quotes {
+		q := &quotes[i]
+		if q.SceneID == "" {
+			continue
+		}
+		if len(scenes) == 0 || scenes[len(scenes)-1].ID != q.SceneID {
+			scenes = append(scenes, Scene{
+				ID:          q.SceneID,
+				Episode:     q.Episode,
+				ContentType: q.ContentType,
+				start:       i,
+			})
+		}
+		scene := &scenes[len(scenes)-1]
+		scene.end = i + 1
+		scene.QuoteCount++
+		if scene.Chapter == "" {
+			scene.Chapter = q.chapter
+		}
+		if q.background != "" && (len(scene.Backgrounds) == 0 || scene.Backgrounds[len(scene.Backgrounds)-1] != q.background) {
+			scene.Backgrounds = append(scene.Backgrounds, q.background)
+		}
+	}
+	return scenes
+}
@@ -0,0 +1,86 @@
+package quote
+
+import "testing"
+
+func TestAssignSceneIDs(t *testing.T) {
+	quotes := []ParsedQuote{
+		{},
+		{label: "umi1_1"},
+		{label: "umi1_1"},
+		{label: "umi1_2"},
+		{label: "umi1_1"},
+	}
+
+	assignSceneIDs(quotes)
+
+	want := []string{"", "umi1_1", "umi1_1", "umi1_2", "umi1_1_2"}
+	for i := range want {
+		if quotes[i].SceneID != want[i] {
+			t.Errorf("quote %d: got %q, want %q", i, quotes[i].SceneID, want[i])
+		}
+	}
+}
+
+func TestBuildScenes(t *testing.T) {
+	quotes := []ParsedQuote{
+		{Text: "before any label"},
+		{SceneID: "umi1_1", Episode: 1, chapter: "Legend", background: "black"},
+		{SceneID: "umi1_1", Episode: 1, chapter: "Legend", background: "black"},
+		{SceneID: "umi1_1", Episode: 1, chapter: "Legend", background: "ship_deck"},
+		{SceneID: "umi1_2", Episode: 1, ContentType: "tea", background: "ship_deck"},
+	}
+
+	scenes := buildScenes(quotes)
+
+	if len(scenes) != 2 {
+		t.Fatalf("expected 2 scenes, got %d", len(scenes))
+	}
+
+	first := scenes[0]
+	if first.ID != "umi1_1" || first.Episode != 1 || first.Chapter != "Legend" {
+		t.Errorf("first scene: got %+v", first)
+	}
+	if first.QuoteCount != 3 || first.start != 1 || first.end != 4 {
+		t.Errorf("first scene range: got count %d, [%d, %d)", first.QuoteCount, first.start, first.end)
+	}
+	if len(first.Backgrounds) != 2 || first.Backgrounds[0] != "black" || first.Backgrounds[1] != "ship_deck" {
+		t.Errorf("first scene backgrounds: got %v", first.Backgrounds)
+	}
+
+	if scenes[1].ContentType != "tea" || scenes[1].QuoteCount != 1 {
+		t.Errorf("second scene: got %+v", scenes[1])
+	}
+}
+
+func TestParseAll_Scenes(t *testing.T) {
+	lines := []string{
+		"*umi1_1",
+		"new_episode 1",
+		`chapter_title "Legend of the Golden Witch"`,
+		"bg black,22",
+		"d `October 4th, 1986.`[\\]",
+		"bg ship_deck,22",
+		"d `The sea was calm.`[\\]",
+		"*umi1_2",
+		"d `A letter lay upon the table.`[\\]",
+	}
+
+	quotes := NewParser().ParseAll(lines)
+	if len(quotes) != 3 {
+		t.Fatalf("expected 3 quotes, got %d", len(quotes))
+	}
+
+	scenes := buildScenes(quotes)
+	if len(scenes) != 2 {
+		t.Fatalf("expected 2 scenes, got %d", len(scenes))
+	}
+	if scenes[0].Chapter != "Legend of the Golden Witch" {
+		t.Errorf("chapter: got %q", scenes[0].Chapter)
+	}
+	if len(scenes[0].Backgrounds) != 2 {
+		t.Errorf("backgrounds: got %v, want [black ship_deck]", scenes[0].Backgrounds)
+	}
+	if quotes[2].SceneID != "umi1_2" {
+		t.Errorf("SceneID: got %q, want %q", quotes[2].SceneID, "umi1_2")
+	}
+}
@@ -17,7 +17,8 @@ type scriptParser struct {
 
 // ParseAll parses all lines and returns quotes.
 func (p *scriptParser) ParseAll(lines []string) []ParsedQuote {
-	// Pre-filter to only relevant lines (dialogue, presets, episode markers, labels)
+	// Pre-filter to only relevant lines (dialogue, presets, episode markers,
+	// labels, scene, sound and sprite commands)
 	filtered := make([]string, 0, len(lines)/8)
 	for _, line := range lines {
 		if len(line) < 2 {
@@ -41,8 +42,21 @@ func (p *scriptParser) ParseAll(lines []string) []ParsedQuote {
 				filtered = append(filtered, line)
 			}
 		case '*':
-			// labels (for omake detection)
+			// labels (for omake detection and scenes)
 			filtered = append(filtered, line)
+		case 'b', 'm', 's':
+			// bg background changes and bgm/se/me sound commands
+			command, _, _ := strings.Cut(line, " ")
+			if command == lexar.BackgroundCommand || lexar.SoundCommandKind(command) != lexar.SoundNone {
+				filtered = append(filtered, line)
+			}
+		case 'c', 'l':
+			// chapter titles and sprite load/clear
+			command, _, _ := strings.Cut(line, " ")
+			switch command {
+			case lexar.ChapterTitleCommand, lexar.SpriteLoadCommand, lexar.SpriteClearCommand:
+				filtered = append(filtered, line)
+			}
 		}
 	}
 
@@ -92,12 +106,21 @@ func (p *scriptParser) ParseAll(lines []string) []ParsedQuote {
 					ContentType:  eq.ContentType,
 					HasRedTruth:  eq.Truth.HasRed,
 					HasBlueTruth: eq.Truth.HasBlue,
+					label:        eq.Label,
+					chapter:      eq.Chapter,
+					background:   eq.Background,
+					BGM:          eq.BGM,
+					SoundEffects: eq.SoundEffects,
+					Present:      presentCharacters(eq.Sprites),
 				}
 			}
 		})
 	}
 	wg.Wait()
 
+	assignQuoteIDs(quotes)
+	assignSceneIDs(quotes)
+
 	return quotes
 }
 
@@ -1,12 +1,44 @@
 package quote
 
 import (
+	"math"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 )
 
-func concurrentExactSearch(indices []int, lowerTexts []string, quotes []ParsedQuote, queryLower string, matchesFilter func(ParsedQuote) bool) []SearchResult {
+// phraseBoost rewards quotes containing a query phrase verbatim over quotes
+// that merely contain all of its words.
+const phraseBoost = 1.5
+
+// searchFilter returns a predicate applying the character, episode, truth,
+// BGM and on-screen presence filters shared by every search mode.
+func searchFilter(characterID string, episode int, truth Truth, bgm string, present []string) func(ParsedQuote) bool {
+	return func(q ParsedQuote) bool {
+		if characterID != "" && q.CharacterID != characterID {
+			return false
+		}
+		if episode > 0 && q.Episode != episode {
+			return false
+		}
+		if truth == TruthRed && !q.HasRedTruth {
+			return false
+		}
+		if truth == TruthBlue && !q.HasBlueTruth {
+			return false
+		}
+		if bgm != "" && !matchesBGM(q, bgm) {
+			return false
+		}
+		if len(present) > 0 && !matchesPresent(q, present) {
+			return false
+		}
+		return true
+	}
+}
+
+func concurrentExactSearch(indices []int, lowerTexts []string, quotes []ParsedQuote, queryLower string, matchesFilter func(ParsedQuote) bool) []int {
 	numWorkers := runtime.NumCPU()
 	total := len(indices)
 	if total == 0 {
@@ -29,17 +61,17 @@ func concurrentExactSearch(indices []int, lowerTexts []string, quotes []ParsedQu
 		chunks = append(chunks, chunk{i, end})
 	}
 
-	resultSlices := make([][]SearchResult, len(chunks))
+	resultSlices := make([][]int, len(chunks))
 	var wg sync.WaitGroup
 
 	for w, c := range chunks {
 		wg.Go(func() {
-			var local []SearchResult
+			var local []int
 			for j := c.start; j < c.end; j++ {
 				idx := indices[j]
 				if strings.Contains(lowerTexts[idx], queryLower) {
 					if matchesFilter(quotes[idx]) {
-						local = append(local, NewSearchResult(quotes[idx], 100))
+						local = append(local, idx)
 					}
 				}
 			}
@@ -49,9 +81,94 @@ func concurrentExactSearch(indices []int, lowerTexts []string, quotes []ParsedQu
 
 	wg.Wait()
 
-	var merged []SearchResult
+	var merged []int
 	for _, s := range resultSlices {
 		merged = append(merged, s...)
 	}
 	return merged
 }
+
+// mergeIndices returns the sorted union of two ascending index slices.
+func mergeIndices(a []int, b []int) []int {
+	merged := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			merged = append(merged, a[i])
+			i++
+		case a[i] > b[j]:
+			merged = append(merged, b[j])
+			j++
+		default:
+			merged = append(merged, a[i])
+			i++
+			j++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	merged = append(merged, b[j:]...)
+	return merged
+}
+
+func containsAny(text string, phrases []string) bool {
+	for _, p := range phrases {
+		if p != "" && strings.Contains(text, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// rankResults scores each candidate with BM25 over the query tokens, boosts
+// quotes containing any of the phrases verbatim, and returns results
+// best-first with scores scaled so the top hit is 100. Ties keep script order.
+func rankResults(candidates []int, quotes []ParsedQuote, lowerTexts []string, phrases []string, tokens []string, tokenIdx TokenIndex) []SearchResult {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	type scored struct {
+		idx int
+		raw float64
+	}
+
+	ranked := make([]scored, len(candidates))
+	var best float64
+	for i, idx := range candidates {
+		var raw float64
+		if tokenIdx != nil {
+			raw = tokenIdx.Score(idx, tokens)
+		}
+		if containsAny(lowerTexts[idx], phrases) {
+			if raw == 0 {
+				raw = 1
+			}
+			raw *= phraseBoost
+		}
+		ranked[i] = scored{idx: idx, raw: raw}
+		best = max(best, raw)
+	}
+
+	slices.SortStableFunc(ranked, func(a, b scored) int {
+		switch {
+		case a.raw > b.raw:
+			return -1
+		case a.raw < b.raw:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	results := make([]SearchResult, len(ranked))
+	for i, r := range ranked {
+		score := 100
+		if best > 0 {
+			score = max(1, int(math.Round(r.raw/best*100)))
+		}
+		results[i] = NewSearchResult(quotes[r.idx], score)
+		results[i].index = r.idx
+	}
+	return results
+}
@@ -0,0 +1,17 @@
+package quote
+
+type SearchMode string
+
+const (
+	SearchModeExact SearchMode = ""
+	SearchModeFuzzy SearchMode = "fuzzy"
+)
+
+func (SearchMode) Parse(s string) SearchMode {
+	switch s {
+	case "fuzzy":
+		return SearchModeFuzzy
+	default:
+		return SearchModeExact
+	}
+}
@@ -0,0 +1,24 @@
+package quote
+
+import "testing"
+
+func TestSearchModeParse(t *testing.T) {
+	var m SearchMode
+
+	tests := []struct {
+		input string
+		want  SearchMode
+	}{
+		{"fuzzy", SearchModeFuzzy},
+		{"", SearchModeExact},
+		{"exact", SearchModeExact},
+		{"FUZZY", SearchModeExact},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		got := m.Parse(tests[i].input)
+		if got != tests[i].want {
+			t.Errorf("Parse(%q): got %q, want %q", tests[i].input, got, tests[i].want)
+		}
+	}
+}
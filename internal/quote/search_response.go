@@ -5,6 +5,12 @@ type SearchResponse struct {
 	Total   int            `json:"total"`
 	Limit   int            `json:"limit"`
 	Offset  int            `json:"offset"`
+	// Suggestions holds "did you mean" rewrites of the query when some of its
+	// words never occur in the script.
+	Suggestions []string `json:"suggestions,omitempty"`
+	// Truncated is set when a regex search ran out of time before scanning
+	// every quote.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 func NewSearchResponse(results []SearchResult, limit int, offset int) SearchResponse {
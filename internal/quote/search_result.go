@@ -3,6 +3,12 @@ package quote
 type SearchResult struct {
 	Quote ParsedQuote `json:"quote"`
 	Score int         `json:"score"`
+	// Parallel holds the same quote in the other languages, keyed by
+	// language, when a parallel view was requested.
+	Parallel map[string]ParsedQuote `json:"parallel,omitempty"`
+
+	// index is the position of Quote in its language's script.
+	index int
 }
 
 func NewSearchResult(quote ParsedQuote, score int) SearchResult {
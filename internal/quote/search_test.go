@@ -42,10 +42,8 @@ func TestConcurrentExactSearch_FindsMatches(t *testing.T) {
 	if len(results) != 2 {
 		t.Fatalf("expected 2 matches, got %d", len(results))
 	}
-	for i := 0; i < len(results); i++ {
-		if results[i].Score != 100 {
-			t.Errorf("result %d score: got %d, want 100", i, results[i].Score)
-		}
+	if results[0] != 0 || results[1] != 2 {
+		t.Errorf("matches: got %v, want [0 2]", results)
 	}
 }
 
@@ -74,8 +72,8 @@ func TestConcurrentExactSearch_RespectsFilter(t *testing.T) {
 		t.Fatalf("expected 2 filtered matches, got %d", len(results))
 	}
 	for i := 0; i < len(results); i++ {
-		if results[i].Quote.CharacterID != "10" {
-			t.Errorf("result %d CharacterID: got %q, want %q", i, results[i].Quote.CharacterID, "10")
+		if quotes[results[i]].CharacterID != "10" {
+			t.Errorf("result %d CharacterID: got %q, want %q", i, quotes[results[i]].CharacterID, "10")
 		}
 	}
 }
@@ -151,3 +149,80 @@ func TestConcurrentExactSearch_CaseInsensitive(t *testing.T) {
 		t.Fatalf("expected 1 case-insensitive match, got %d", len(results))
 	}
 }
+
+func TestMergeIndices(t *testing.T) {
+	got := mergeIndices([]int{1, 3, 5}, []int{2, 3, 6})
+	want := []int{1, 2, 3, 5, 6}
+
+	if len(got) != len(want) {
+		t.Fatalf("mergeIndices: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("mergeIndices[%d]: got %d, want %d", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRankResults_BestMatchFirst(t *testing.T) {
+	quotes := []ParsedQuote{
+		{Text: "The witch laughed at the sea."},
+		{Text: "Witch, witch, witch! The golden witch!"},
+		{Text: "A witch."},
+	}
+	lowerTexts := []string{
+		"the witch laughed at the sea.",
+		"witch, witch, witch! the golden witch!",
+		"a witch.",
+	}
+	ti := newTokenIndex(lowerTexts)
+	tokens := ti.Tokenize("golden witch")
+
+	results := rankResults([]int{0, 1, 2}, quotes, lowerTexts, []string{"golden witch"}, tokens, ti)
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if results[0].Quote.Text != quotes[1].Text {
+		t.Errorf("top result: got %q, want %q", results[0].Quote.Text, quotes[1].Text)
+	}
+	if results[0].Score != 100 {
+		t.Errorf("top score: got %d, want 100", results[0].Score)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("results not sorted by score: %d > %d at %d", results[i].Score, results[i-1].Score, i)
+		}
+		if results[i].Score < 1 {
+			t.Errorf("result %d score: got %d, want >= 1", i, results[i].Score)
+		}
+	}
+}
+
+func TestRankResults_PhraseBeatsScatteredTokens(t *testing.T) {
+	quotes := []ParsedQuote{
+		{Text: "Seen without love, it cannot be."},
+		{Text: "Without love, it cannot be seen."},
+	}
+	lowerTexts := []string{
+		"seen without love, it cannot be.",
+		"without love, it cannot be seen.",
+	}
+	ti := newTokenIndex(lowerTexts)
+	query := "without love, it cannot be seen"
+
+	results := rankResults([]int{0, 1}, quotes, lowerTexts, []string{query}, ti.Tokenize(query), ti)
+
+	if results[0].Quote.Text != quotes[1].Text {
+		t.Errorf("verbatim phrase should rank first, got %q", results[0].Quote.Text)
+	}
+	if results[1].Score >= results[0].Score {
+		t.Errorf("scattered match score %d should be below phrase score %d", results[1].Score, results[0].Score)
+	}
+}
+
+func TestRankResults_Empty(t *testing.T) {
+	if results := rankResults(nil, nil, nil, []string{"x"}, nil, nil); results != nil {
+		t.Errorf("expected nil for no candidates, got %v", results)
+	}
+}
@@ -1,58 +1,106 @@
 package quote
 
 import (
+	"context"
 	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
 	"math/rand/v2"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+
+	"umineko_quote/internal/audio"
 )
 
-const audioDir = "internal/quote/data/audio"
+//go:generate go run ../../cmd/indexsnapshot -scripts data -out data/index.snapshot
 
 //go:embed data/*.txt
 var dataFS embed.FS
 
 type (
 	Service interface {
-		Search(query string, lang string, limit int, offset int, characterID string, episode int, truth Truth) SearchResponse
+		Search(query string, lang string, limit int, offset int, characterID string, episode int, truth Truth, bgm string, present []string, mode SearchMode) SearchResponse
+		SearchRegex(pattern string, lang string, limit int, offset int, characterID string, episode int, truth Truth, bgm string, present []string, html bool) (SearchResponse, error)
 		Browse(lang string, characterID string, limit int, offset int, episode int, truth Truth) CharacterResponse
 		GetByCharacter(lang string, characterID string, limit int, offset int, episode int, truth Truth) CharacterResponse
 		GetByAudioID(lang string, audioID string) *ParsedQuote
 		GetContext(lang string, audioID string, lines int) *ContextResponse
+		GetParallel(audioID string) map[string]ParsedQuote
+		GetScenes(lang string, episode int) []Scene
+		GetScene(lang string, sceneID string) *SceneResponse
+		WithParallel(lang string, results []SearchResult) []SearchResult
 		Random(lang string, characterID string, episode int, truth Truth) *ParsedQuote
 		GetCharacters() map[string]string
-		AudioFilePath(characterId string, audioId string) string
+		OpenAudio(characterID string, audioID string) (audio.Clip, error)
+		GetAudioMeta(characterID string, audioID string) *AudioMeta
 		GetStats() Stats
 		HasAudio() bool
-	}
-
-	service struct {
-		quotes  map[string][]ParsedQuote
-		indexer Indexer
-		stats   Stats
+		// Languages lists the loaded languages in manifest order.
+		Languages() []Language
+		// HasLanguage reports whether lang is one of Languages.
+		HasLanguage(lang string) bool
+		// DefaultLanguage is the language used when a request names none,
+		// the first of Languages.
+		DefaultLanguage() string
+	}
+
+	// snapshot is one parse of the scripts with its index. It is never
+	// changed after it is built; a reload builds a new one.
+	snapshot struct {
+		quotes    map[string][]ParsedQuote
+		indexer   Indexer
+		stats     Stats
+		languages []Language
+		// defaultLang is the code of the first loaded language.
+		defaultLang string
+		// fallbacks lists, for each language, the languages to look in for
+		// lines it lacks.
+		fallbacks map[string][]string
 	}
 
 	langParseResult struct {
 		lang   string
 		parsed []ParsedQuote
+		err    error
 	}
 )
 
-func NewService() Service {
-	langFiles := map[string]string{
-		"en": "data/english.txt",
-		"ja": "data/japanese.txt",
-	}
+// NewService parses the embedded scripts and indexes them along with the
+// voice clips in audioStore, which may be nil when there is no audio. When
+// snapshotPath names an index snapshot written for these scripts, the index
+// is read from it instead.
+func NewService(audioStore audio.AudioStore, snapshotPath string) ReloadableService {
+	scripts, _ := fs.Sub(dataFS, "data")
+	s, err := newService(scripts, audioStore, snapshotPath)
+	if err != nil {
+		log.Printf("[scripts] %v", err)
+	}
+	return s
+}
 
-	results := make(chan langParseResult, len(langFiles))
+// parseScripts parses the script of every language in the manifest, and
+// applies the BGM titles if there are any. It returns the whole manifest
+// along with the scripts it could read, and fails if any script in the
+// manifest cannot be read.
+func parseScripts(scripts fs.FS) (map[string][]ParsedQuote, []Language, error) {
+	langs, err := readLanguages(scripts)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make(chan langParseResult, len(langs))
 	var wg sync.WaitGroup
 
-	for lang, path := range langFiles {
+	for _, l := range langs {
+		lang := l.Code
 		wg.Go(func() {
-			data, err := dataFS.ReadFile(path)
+			data, err := fs.ReadFile(scripts, l.File)
 			if err != nil {
+				log.Printf("[%s] failed to read %s: %v", lang, l.File, err)
+				results <- langParseResult{lang: lang, err: fmt.Errorf("%s script: %w", lang, err)}
 				return
 			}
 			lines := strings.Split(string(data), "\n")
@@ -75,27 +123,87 @@ func NewService() Service {
 	}()
 
 	quotes := make(map[string][]ParsedQuote)
+	var errs []error
 
 	for r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
 		quotes[r.lang] = r.parsed
 	}
+	if len(quotes) == 0 {
+		return quotes, langs, errors.Join(append([]error{errors.New("no scripts found")}, errs...)...)
+	}
 
-	indexer := NewIndexer(quotes, audioDir)
+	if data, err := fs.ReadFile(scripts, bgmTitlesPath); err == nil {
+		titles := parseBGMTitles(string(data))
+		log.Printf("[bgm] loaded %d track titles", len(titles))
+		for _, parsed := range quotes {
+			applyBGMTitles(parsed, titles)
+		}
+	}
+	return quotes, langs, errors.Join(errs...)
+}
+
+// loadScripts indexes the scripts, restoring the index from the snapshot at
+// snapshotPath instead when one was written for these same scripts. Like
+// parseScripts, it returns the manifest and fails if no script is found.
+func loadScripts(scripts fs.FS, snapshotPath string) (*indexer, []Language, error) {
+	if snapshotPath != "" {
+		start := time.Now()
+		idx, langs, err := readIndexSnapshot(snapshotPath, scripts)
+		if err == nil {
+			log.Printf("[index] loaded snapshot %s in %v", snapshotPath, time.Since(start).Round(time.Millisecond))
+			return idx, langs, nil
+		}
+		log.Printf("[index] rebuilding from the scripts: %v", err)
+	}
+	quotes, langs, err := parseScripts(scripts)
+	return indexScripts(quotes), langs, err
+}
 
+// newSnapshot serves freshly indexed quotes along with the audio catalog.
+// Only the languages in langs that have quotes are served.
+func newSnapshot(indexer *indexer, langs []Language, catalog *audioCatalog) *snapshot {
+	quotes := indexer.quotes
+	indexer.attachAudio(catalog)
 	if indexer.HasAudio() {
-		log.Printf("[audio] audio features enabled")
-	} else {
-		log.Printf("[audio] no audio files found, disabling audio features")
+		for _, parsed := range quotes {
+			applyAudioMeta(parsed, indexer)
+		}
 	}
 
-	return &service{
-		quotes:  quotes,
-		indexer: indexer,
-		stats:   NewStats(quotes["en"]),
+	loaded := make([]Language, 0, len(quotes))
+	for _, lang := range langs {
+		if quotes[lang.Code] != nil {
+			loaded = append(loaded, lang)
+		}
+	}
+	fallbacks := make(map[string][]string, len(loaded))
+	for _, lang := range loaded {
+		for _, code := range fallbackChain(langs, lang.Code) {
+			if quotes[code] != nil {
+				fallbacks[lang.Code] = append(fallbacks[lang.Code], code)
+			}
+		}
+	}
+
+	var defaultLang string
+	if len(loaded) > 0 {
+		defaultLang = loaded[0].Code
+	}
+	return &snapshot{
+		quotes:      quotes,
+		indexer:     indexer,
+		stats:       NewStats(quotes[defaultLang]),
+		languages:   loaded,
+		defaultLang: defaultLang,
+		fallbacks:   fallbacks,
 	}
 }
 
-func (s *service) Search(query string, lang string, limit int, offset int, characterID string, episode int, truth Truth) SearchResponse {
+func (s *snapshot) Search(query string, lang string, limit int, offset int, characterID string, episode int, truth Truth, bgm string, present []string, mode SearchMode) SearchResponse {
 	if limit <= 0 {
 		limit = 30
 	}
@@ -103,7 +211,7 @@ func (s *service) Search(query string, lang string, limit int, offset int, chara
 		offset = 0
 	}
 	if lang == "" {
-		lang = "en"
+		lang = s.defaultLang
 	}
 
 	quotes := s.quotes[lang]
@@ -112,35 +220,112 @@ func (s *service) Search(query string, lang string, limit int, offset int, chara
 		return NewSearchResponse(nil, limit, offset)
 	}
 
-	matchesFilter := func(q ParsedQuote) bool {
-		if characterID != "" && q.CharacterID != characterID {
-			return false
+	matchesFilter := searchFilter(characterID, episode, truth, bgm, present)
+
+	parsed := ParseQuery(query)
+	tokenIdx := s.indexer.TokenIndex(lang)
+
+	var candidates []int
+	if parsed.Simple {
+		candidates = s.literalMatches(lang, query, characterID, episode, matchesFilter)
+	} else {
+		eval := newQueryEval(lang, quotes, lowerTexts, tokenIdx, s.indexer)
+		for _, idx := range parsed.matches(eval) {
+			if matchesFilter(quotes[idx]) {
+				candidates = append(candidates, idx)
+			}
 		}
-		if episode > 0 && q.Episode != episode {
-			return false
+	}
+
+	var tokens []string
+	if tokenIdx != nil {
+		for _, term := range parsed.Terms {
+			tokens = append(tokens, tokenIdx.Tokenize(term)...)
 		}
-		if truth == TruthRed && !q.HasRedTruth {
-			return false
+	}
+
+	results := rankResults(candidates, quotes, lowerTexts, parsed.Terms, tokens, tokenIdx)
+
+	if mode == SearchModeFuzzy && parsed.Simple {
+		// Exact hits move above fuzzyMaxScore so near misses always rank below them.
+		for i := range results {
+			results[i].Score = fuzzyMaxScore + (results[i].Score+1)/2
 		}
-		if truth == TruthBlue && !q.HasBlueTruth {
-			return false
+		results = append(results, fuzzySearch(tokens, tokenIdx, quotes, candidates, matchesFilter)...)
+	}
+
+	response := NewSearchResponse(results, limit, offset)
+	response.Suggestions = suggestQueries(tokens, tokenIdx)
+	return response
+}
+
+// SearchRegex matches an RE2 pattern against each quote's plain text, or its
+// HTML when html is set. Results are in script order. An invalid or overlong
+// pattern returns an error; a search that runs past regexTimeBudget returns
+// the matches found so far with Truncated set.
+func (s *snapshot) SearchRegex(pattern string, lang string, limit int, offset int, characterID string, episode int, truth Truth, bgm string, present []string, html bool) (SearchResponse, error) {
+	if limit <= 0 {
+		limit = 30
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if lang == "" {
+		lang = s.defaultLang
+	}
+
+	re, err := CompileSearchRegex(pattern)
+	if err != nil {
+		return SearchResponse{}, err
+	}
+
+	quotes := s.quotes[lang]
+	if quotes == nil {
+		return NewSearchResponse(nil, limit, offset), nil
+	}
+
+	indices := s.indexer.FilteredIndices(lang, characterID, episode)
+	if indices == nil {
+		indices = make([]int, len(quotes))
+		for i := range indices {
+			indices[i] = i
 		}
-		return true
 	}
 
-	queryLower := strings.ToLower(query)
+	ctx, cancel := context.WithTimeout(context.Background(), regexTimeBudget)
+	defer cancel()
+
+	matches, truncated := concurrentRegexSearch(ctx, indices, quotes, re, html, searchFilter(characterID, episode, truth, bgm, present))
+
+	results := make([]SearchResult, len(matches))
+	for i, idx := range matches {
+		results[i] = NewSearchResult(quotes[idx], 100)
+		results[i].index = idx
+	}
+
+	response := NewSearchResponse(results, limit, offset)
+	response.Truncated = truncated
+	return response, nil
+}
+
+// literalMatches finds quotes containing the query as a substring, or
+// containing every word of it, restricted by the character/episode indices.
+func (s *snapshot) literalMatches(lang string, query string, characterID string, episode int, matchesFilter func(ParsedQuote) bool) []int {
+	quotes := s.quotes[lang]
+	lowerTexts := s.indexer.LowerTexts(lang)
+	queryLower := normalizeText(query)
 
 	searchIndices := s.indexer.FilteredIndices(lang, characterID, episode)
 
-	var exactMatches []SearchResult
+	var substringMatches []int
 	if searchIndices != nil {
 		if len(searchIndices) > 5000 {
-			exactMatches = concurrentExactSearch(searchIndices, lowerTexts, quotes, queryLower, matchesFilter)
+			substringMatches = concurrentExactSearch(searchIndices, lowerTexts, quotes, queryLower, matchesFilter)
 		} else {
 			for _, idx := range searchIndices {
 				if strings.Contains(lowerTexts[idx], queryLower) {
 					if matchesFilter(quotes[idx]) {
-						exactMatches = append(exactMatches, NewSearchResult(quotes[idx], 100))
+						substringMatches = append(substringMatches, idx)
 					}
 				}
 			}
@@ -150,13 +335,22 @@ func (s *service) Search(query string, lang string, limit int, offset int, chara
 		for i := range allIndices {
 			allIndices[i] = i
 		}
-		exactMatches = concurrentExactSearch(allIndices, lowerTexts, quotes, queryLower, matchesFilter)
+		substringMatches = concurrentExactSearch(allIndices, lowerTexts, quotes, queryLower, matchesFilter)
 	}
 
-	return NewSearchResponse(exactMatches, limit, offset)
+	var tokenMatches []int
+	if tokenIdx := s.indexer.TokenIndex(lang); tokenIdx != nil {
+		for _, idx := range tokenIdx.IndicesWithAll(tokenIdx.Tokenize(query)) {
+			if matchesFilter(quotes[idx]) {
+				tokenMatches = append(tokenMatches, idx)
+			}
+		}
+	}
+
+	return mergeIndices(substringMatches, tokenMatches)
 }
 
-func (s *service) Browse(lang string, characterID string, limit int, offset int, episode int, truth Truth) CharacterResponse {
+func (s *snapshot) Browse(lang string, characterID string, limit int, offset int, episode int, truth Truth) CharacterResponse {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -164,7 +358,7 @@ func (s *service) Browse(lang string, characterID string, limit int, offset int,
 		offset = 0
 	}
 	if lang == "" {
-		lang = "en"
+		lang = s.defaultLang
 	}
 
 	quotes := s.quotes[lang]
@@ -198,7 +392,7 @@ func (s *service) Browse(lang string, characterID string, limit int, offset int,
 	return NewCharacterResponse(characterID, all, limit, offset)
 }
 
-func (s *service) GetByCharacter(lang string, characterID string, limit int, offset int, episode int, truth Truth) CharacterResponse {
+func (s *snapshot) GetByCharacter(lang string, characterID string, limit int, offset int, episode int, truth Truth) CharacterResponse {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -206,7 +400,7 @@ func (s *service) GetByCharacter(lang string, characterID string, limit int, off
 		offset = 0
 	}
 	if lang == "" {
-		lang = "en"
+		lang = s.defaultLang
 	}
 
 	quotes := s.quotes[lang]
@@ -237,9 +431,9 @@ func (s *service) GetByCharacter(lang string, characterID string, limit int, off
 	return NewCharacterResponse(characterID, all, limit, offset)
 }
 
-func (s *service) Random(lang string, characterID string, episode int, truth Truth) *ParsedQuote {
+func (s *snapshot) Random(lang string, characterID string, episode int, truth Truth) *ParsedQuote {
 	if lang == "" {
-		lang = "en"
+		lang = s.defaultLang
 	}
 
 	quotes := s.quotes[lang]
@@ -332,11 +526,27 @@ func (s *service) Random(lang string, characterID string, episode int, truth Tru
 	return &quotes[pick]
 }
 
-func (s *service) GetByAudioID(lang string, audioID string) *ParsedQuote {
+// GetByAudioID finds a quote by audio ID or quote ID. A line missing from
+// lang is looked up in its fallback languages, and returned with
+// FallbackLang set.
+func (s *snapshot) GetByAudioID(lang string, audioID string) *ParsedQuote {
 	if lang == "" {
-		lang = "en"
+		lang = s.defaultLang
 	}
+	if q := s.findByAudioID(lang, audioID); q != nil {
+		return q
+	}
+	for _, fallback := range s.fallbacks[lang] {
+		if q := s.findByAudioID(fallback, audioID); q != nil {
+			stand := *q
+			stand.FallbackLang = fallback
+			return &stand
+		}
+	}
+	return nil
+}
 
+func (s *snapshot) findByAudioID(lang string, audioID string) *ParsedQuote {
 	quotes := s.quotes[lang]
 	if quotes == nil {
 		return nil
@@ -352,12 +562,17 @@ func (s *service) GetByAudioID(lang string, audioID string) *ParsedQuote {
 			}
 		}
 	}
+
+	// Not an audio ID; it may be a quote ID.
+	if idx, ok := s.indexer.QuoteIndex(lang, audioID); ok {
+		return &quotes[idx]
+	}
 	return nil
 }
 
-func (s *service) GetContext(lang string, audioID string, lines int) *ContextResponse {
+func (s *snapshot) GetContext(lang string, audioID string, lines int) *ContextResponse {
 	if lang == "" {
-		lang = "en"
+		lang = s.defaultLang
 	}
 	if lines <= 0 {
 		lines = 5
@@ -373,6 +588,14 @@ func (s *service) GetContext(lang string, audioID string, lines int) *ContextRes
 
 	idx, ok := s.indexer.QuoteIndex(lang, audioID)
 	if !ok {
+		// Show the line among its fallback language's dialogue instead.
+		for _, fallback := range s.fallbacks[lang] {
+			if _, ok := s.indexer.QuoteIndex(fallback, audioID); ok {
+				resp := s.GetContext(fallback, audioID, lines)
+				resp.Quote.FallbackLang = fallback
+				return resp
+			}
+		}
 		return nil
 	}
 
@@ -392,18 +615,127 @@ func (s *service) GetContext(lang string, audioID string, lines int) *ContextRes
 	}
 }
 
-func (s *service) GetCharacters() map[string]string {
+// GetScenes lists the scenes of the script in order, optionally limited to
+// one episode.
+func (s *snapshot) GetScenes(lang string, episode int) []Scene {
+	if lang == "" {
+		lang = s.defaultLang
+	}
+
+	scenes := []Scene{}
+	for _, scene := range s.indexer.Scenes(lang) {
+		if episode > 0 && scene.Episode != episode {
+			continue
+		}
+		scenes = append(scenes, scene)
+	}
+	return scenes
+}
+
+// GetScene returns a whole scene with all of its quotes.
+func (s *snapshot) GetScene(lang string, sceneID string) *SceneResponse {
+	if lang == "" {
+		lang = s.defaultLang
+	}
+
+	scene, ok := s.indexer.Scene(lang, sceneID)
+	if !ok {
+		return nil
+	}
+	return &SceneResponse{
+		Scene:  scene,
+		Quotes: s.quotes[lang][scene.start:scene.end],
+	}
+}
+
+// GetParallel returns the quote with this audio ID in every language that has
+// it, keyed by language. Each counterpart comes from the script alignment,
+// so a line whose translation was split or merged differently still pairs up.
+func (s *snapshot) GetParallel(audioID string) map[string]ParsedQuote {
+	for _, lang := range s.langCodes() {
+		idx, ok := s.indexer.QuoteIndex(lang, audioID)
+		if !ok {
+			continue
+		}
+		return s.parallelQuotes(lang, idx)
+	}
+	return nil
+}
+
+// WithParallel fills in Parallel on each search result with the aligned quote
+// from every other language.
+func (s *snapshot) WithParallel(lang string, results []SearchResult) []SearchResult {
+	if lang == "" {
+		lang = s.defaultLang
+	}
+	for i := range results {
+		parallel := s.parallelQuotes(lang, results[i].index)
+		delete(parallel, lang)
+		if len(parallel) > 0 {
+			results[i].Parallel = parallel
+		}
+	}
+	return results
+}
+
+func (s *snapshot) parallelQuotes(lang string, idx int) map[string]ParsedQuote {
+	quotes := s.quotes[lang]
+	if idx < 0 || idx >= len(quotes) {
+		return nil
+	}
+	parallel := map[string]ParsedQuote{lang: quotes[idx]}
+	for _, other := range s.langCodes() {
+		if other == lang {
+			continue
+		}
+		if j, ok := s.indexer.Counterpart(lang, other, idx); ok {
+			parallel[other] = s.quotes[other][j]
+		}
+	}
+	return parallel
+}
+
+// langCodes returns the loaded language codes in manifest order.
+func (s *snapshot) langCodes() []string {
+	codes := make([]string, len(s.languages))
+	for i, lang := range s.languages {
+		codes[i] = lang.Code
+	}
+	return codes
+}
+
+func (s *snapshot) Languages() []Language {
+	return slices.Clone(s.languages)
+}
+
+func (s *snapshot) HasLanguage(lang string) bool {
+	return s.quotes[lang] != nil
+}
+
+func (s *snapshot) DefaultLanguage() string {
+	return s.defaultLang
+}
+
+func (s *snapshot) GetCharacters() map[string]string {
 	return CharacterNames.GetAllCharacters()
 }
 
-func (s *service) AudioFilePath(characterId string, audioId string) string {
-	return s.indexer.AudioFilePath(characterId, audioId)
+func (s *snapshot) OpenAudio(characterID string, audioID string) (audio.Clip, error) {
+	return s.indexer.OpenAudio(characterID, audioID)
+}
+
+func (s *snapshot) GetAudioMeta(characterID string, audioID string) *AudioMeta {
+	m, ok := s.indexer.AudioMeta(characterID, audioID)
+	if !ok {
+		return nil
+	}
+	return &m
 }
 
-func (s *service) GetStats() Stats {
+func (s *snapshot) GetStats() Stats {
 	return s.stats
 }
 
-func (s *service) HasAudio() bool {
+func (s *snapshot) HasAudio() bool {
 	return s.indexer.HasAudio()
 }
@@ -1,16 +1,18 @@
 package quote
 
 import (
+	"slices"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
-var testService = NewService()
+var testService = NewService(nil, "")
 
 func TestService_Search_ExactMatch(t *testing.T) {
 	svc := testService
 
-	resp := svc.Search("Beatrice", "en", 10, 0, "", 0, TruthAll)
+	resp := svc.Search("Beatrice", "en", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact)
 
 	if resp.Total == 0 {
 		t.Fatal("expected search results for 'Beatrice'")
@@ -26,7 +28,7 @@ func TestService_Search_ExactMatch(t *testing.T) {
 func TestService_Search_DefaultValues(t *testing.T) {
 	svc := testService
 
-	resp := svc.Search("witch", "", 0, -1, "", 0, TruthAll)
+	resp := svc.Search("witch", "", 0, -1, "", 0, TruthAll, "", nil, SearchModeExact)
 
 	if resp.Limit != 30 {
 		t.Errorf("default limit: got %d, want 30", resp.Limit)
@@ -39,7 +41,7 @@ func TestService_Search_DefaultValues(t *testing.T) {
 func TestService_Search_WithCharacterFilter(t *testing.T) {
 	svc := testService
 
-	resp := svc.Search("witch", "en", 10, 0, "10", 0, TruthAll)
+	resp := svc.Search("witch", "en", 10, 0, "10", 0, TruthAll, "", nil, SearchModeExact)
 
 	for i := 0; i < len(resp.Results); i++ {
 		if resp.Results[i].Quote.CharacterID != "10" {
@@ -51,7 +53,7 @@ func TestService_Search_WithCharacterFilter(t *testing.T) {
 func TestService_Search_WithEpisodeFilter(t *testing.T) {
 	svc := testService
 
-	resp := svc.Search("witch", "en", 10, 0, "", 1, TruthAll)
+	resp := svc.Search("witch", "en", 10, 0, "", 1, TruthAll, "", nil, SearchModeExact)
 
 	for i := 0; i < len(resp.Results); i++ {
 		if resp.Results[i].Quote.Episode != 1 {
@@ -63,7 +65,7 @@ func TestService_Search_WithEpisodeFilter(t *testing.T) {
 func TestService_Search_RedTruthFilter(t *testing.T) {
 	svc := testService
 
-	resp := svc.Search("truth", "en", 10, 0, "", 0, TruthRed)
+	resp := svc.Search("truth", "en", 10, 0, "", 0, TruthRed, "", nil, SearchModeExact)
 
 	for i := 0; i < len(resp.Results); i++ {
 		if !strings.Contains(resp.Results[i].Quote.TextHtml, "red-truth") {
@@ -75,7 +77,7 @@ func TestService_Search_RedTruthFilter(t *testing.T) {
 func TestService_Search_NoResults(t *testing.T) {
 	svc := testService
 
-	resp := svc.Search("xyzzyxyzzyxyzzy", "en", 10, 0, "", 0, TruthAll)
+	resp := svc.Search("xyzzyxyzzyxyzzy", "en", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact)
 
 	if resp.Total != 0 {
 		t.Errorf("Total: got %d, want 0", resp.Total)
@@ -88,17 +90,42 @@ func TestService_Search_NoResults(t *testing.T) {
 func TestService_Search_Japanese(t *testing.T) {
 	svc := testService
 
-	resp := svc.Search("ベアトリーチェ", "ja", 10, 0, "", 0, TruthAll)
+	resp := svc.Search("ベアトリーチェ", "ja", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact)
 
 	if resp.Total == 0 {
 		t.Fatal("expected Japanese search results")
 	}
 }
 
+func TestService_Search_JapaneseKanaVariants(t *testing.T) {
+	svc := testService
+
+	want := svc.Search("ベアトリーチェ", "ja", 1000, 0, "", 0, TruthAll, "", nil, SearchModeExact).Total
+	for _, query := range []string{"べあとりーちぇ", "ﾍﾞｱﾄﾘｰﾁｪ", "ベアトリチェ"} {
+		if got := svc.Search(query, "ja", 1000, 0, "", 0, TruthAll, "", nil, SearchModeExact).Total; got != want {
+			t.Errorf("Search(%q): got %d results, want %d", query, got, want)
+		}
+	}
+}
+
+func TestService_Search_FullWidthDigits(t *testing.T) {
+	svc := testService
+
+	fullWidth := svc.Search("１９８６", "ja", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact)
+	halfWidth := svc.Search("1986", "ja", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact)
+
+	if fullWidth.Total == 0 {
+		t.Fatal("expected results for full-width digits")
+	}
+	if fullWidth.Total != halfWidth.Total {
+		t.Errorf("full-width: got %d results, half-width got %d", fullWidth.Total, halfWidth.Total)
+	}
+}
+
 func TestService_Search_UnknownLang(t *testing.T) {
 	svc := testService
 
-	resp := svc.Search("test", "fr", 10, 0, "", 0, TruthAll)
+	resp := svc.Search("test", "fr", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact)
 
 	if resp.Total != 0 {
 		t.Errorf("Total for unknown lang: got %d, want 0", resp.Total)
@@ -351,7 +378,7 @@ func TestService_GetContext(t *testing.T) {
 	svc := testService
 
 	// Use an audio ID that is not at the very start of the quotes slice
-	resp := svc.Search("Beatrice", "en", 10, 0, "", 0, TruthAll)
+	resp := svc.Search("Beatrice", "en", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact)
 	if resp.Total == 0 {
 		t.Fatal("need search results to find a mid-slice audio ID")
 	}
@@ -541,3 +568,354 @@ func TestService_GetByAudioID_CompositeAudioID(t *testing.T) {
 		t.Errorf("both audio IDs should resolve to same character: %q vs %q", q1.CharacterID, q2.CharacterID)
 	}
 }
+
+func TestService_Search_RankedByScore(t *testing.T) {
+	svc := testService
+
+	resp := svc.Search("witch", "en", 50, 0, "", 0, TruthAll, "", nil, SearchModeExact)
+
+	if resp.Total < 2 {
+		t.Fatalf("expected multiple results for 'witch', got %d", resp.Total)
+	}
+	if resp.Results[0].Score != 100 {
+		t.Errorf("top score: got %d, want 100", resp.Results[0].Score)
+	}
+	for i := 1; i < len(resp.Results); i++ {
+		if resp.Results[i].Score > resp.Results[i-1].Score {
+			t.Errorf("results not sorted by score at %d: %d > %d", i, resp.Results[i].Score, resp.Results[i-1].Score)
+		}
+	}
+}
+
+func TestService_Search_MatchesWordsAcrossPunctuation(t *testing.T) {
+	svc := testService
+
+	resp := svc.Search("without love it cannot be seen", "en", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact)
+
+	if resp.Total == 0 {
+		t.Fatal("expected token matches ignoring punctuation")
+	}
+	if !strings.Contains(resp.Results[0].Quote.Text, "Without love, it cannot be seen") {
+		t.Errorf("top result: got %q", resp.Results[0].Quote.Text)
+	}
+}
+
+func TestService_Search_FuzzyFindsMisspelling(t *testing.T) {
+	svc := testService
+
+	exact := svc.Search("Lamdadelta", "en", 10, 0, "", 0, TruthAll, "", nil, SearchModeExact)
+	if exact.Total != 0 {
+		t.Fatalf("misspelling should not match exactly, got %d", exact.Total)
+	}
+	if len(exact.Suggestions) == 0 || exact.Suggestions[0] != "lambdadelta" {
+		t.Errorf("suggestions: got %v, want lambdadelta first", exact.Suggestions)
+	}
+
+	fuzzy := svc.Search("Lamdadelta", "en", 10, 0, "", 0, TruthAll, "", nil, SearchModeFuzzy)
+	if fuzzy.Total == 0 {
+		t.Fatal("expected fuzzy results for 'Lamdadelta'")
+	}
+	for i := 0; i < len(fuzzy.Results); i++ {
+		if fuzzy.Results[i].Score > fuzzyMaxScore {
+			t.Errorf("fuzzy-only result %d score %d should be <= %d", i, fuzzy.Results[i].Score, fuzzyMaxScore)
+		}
+	}
+}
+
+func TestService_Search_FuzzyRanksExactFirst(t *testing.T) {
+	svc := testService
+
+	resp := svc.Search("Battler", "en", 100, 0, "", 0, TruthAll, "", nil, SearchModeFuzzy)
+	if resp.Total == 0 {
+		t.Fatal("expected results for 'Battler'")
+	}
+	if resp.Results[0].Score <= fuzzyMaxScore {
+		t.Errorf("exact hit score %d should exceed %d in fuzzy mode", resp.Results[0].Score, fuzzyMaxScore)
+	}
+}
+
+func TestService_Search_QueryLanguage(t *testing.T) {
+	svc := testService
+
+	resp := svc.Search(`"without love it cannot be seen" -char:narrator`, "en", 50, 0, "", 0, TruthAll, "", nil, SearchModeExact)
+	if resp.Total == 0 {
+		t.Fatal("expected results for phrase query")
+	}
+	for i := 0; i < len(resp.Results); i++ {
+		if resp.Results[i].Quote.CharacterID == "narrator" {
+			t.Errorf("result %d should exclude narrator", i)
+		}
+	}
+
+	resp = svc.Search("witch ep:2 truth:red", "en", 50, 0, "", 0, TruthAll, "", nil, SearchModeExact)
+	for i := 0; i < len(resp.Results); i++ {
+		q := resp.Results[i].Quote
+		if q.Episode != 2 || !q.HasRedTruth {
+			t.Errorf("result %d: episode %d red %v, want episode 2 with red truth", i, q.Episode, q.HasRedTruth)
+		}
+	}
+}
+
+func TestService_SearchRegex(t *testing.T) {
+	svc := testService
+
+	resp, err := svc.SearchRegex(`^Beatrice!`, "en", 10, 0, "", 0, TruthAll, "", nil, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Total == 0 {
+		t.Fatal("expected regex matches")
+	}
+	for i := 0; i < len(resp.Results); i++ {
+		if !strings.HasPrefix(resp.Results[i].Quote.Text, "Beatrice!") {
+			t.Errorf("result %d does not match pattern: %q", i, resp.Results[i].Quote.Text)
+		}
+	}
+}
+
+func TestService_SearchRegex_HTML(t *testing.T) {
+	svc := testService
+
+	resp, err := svc.SearchRegex(`class="red-truth"`, "en", 10, 0, "", 0, TruthAll, "", nil, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < len(resp.Results); i++ {
+		if !resp.Results[i].Quote.HasRedTruth {
+			t.Errorf("result %d should have red truth", i)
+		}
+	}
+}
+
+func TestService_SearchRegex_InvalidPattern(t *testing.T) {
+	svc := testService
+
+	if _, err := svc.SearchRegex(`[`, "en", 10, 0, "", 0, TruthAll, "", nil, false); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestService_GetParallel(t *testing.T) {
+	svc := testService
+
+	result := svc.GetParallel("10100001")
+
+	if result == nil {
+		t.Fatal("expected parallel quotes")
+	}
+	en, ok := result["en"]
+	if !ok || !strings.Contains(en.Text, "Beatrice") {
+		t.Errorf("en: got %q, want text containing Beatrice", en.Text)
+	}
+	ja, ok := result["ja"]
+	if !ok || !strings.Contains(ja.Text, "ベアトリーチェ") {
+		t.Errorf("ja: got %q, want text containing ベアトリーチェ", ja.Text)
+	}
+}
+
+func TestService_GetParallel_NotFound(t *testing.T) {
+	svc := testService
+
+	if result := svc.GetParallel("99999999"); result != nil {
+		t.Errorf("expected nil, got %v", result)
+	}
+}
+
+func TestService_WithParallel_Narration(t *testing.T) {
+	svc := testService
+
+	resp := svc.Search("Rokkenjima", "en", 10, 0, "narrator", 1, TruthAll, "", nil, SearchModeExact)
+	if len(resp.Results) == 0 {
+		t.Fatal("expected narration results")
+	}
+
+	results := svc.WithParallel("en", resp.Results)
+
+	ja, ok := results[0].Parallel["ja"]
+	if !ok {
+		t.Fatal("expected a Japanese counterpart")
+	}
+	if ja.CharacterID != "narrator" || ja.Episode != 1 {
+		t.Errorf("counterpart: got character %q episode %d, want narrator in episode 1", ja.CharacterID, ja.Episode)
+	}
+	if _, ok := results[0].Parallel["en"]; ok {
+		t.Error("parallel should not repeat the search language")
+	}
+}
+
+func TestService_GetByAudioID_QuoteID(t *testing.T) {
+	svc := testService
+
+	narration := svc.Browse("en", "narrator", 1, 0, 1, TruthAll)
+	if len(narration.Quotes) == 0 {
+		t.Fatal("expected narrator quotes in episode 1")
+	}
+	want := narration.Quotes[0]
+
+	q := svc.GetByAudioID("en", want.ID)
+	if q == nil {
+		t.Fatalf("expected quote for ID %q", want.ID)
+	}
+	if q.Text != want.Text {
+		t.Errorf("Text: got %q, want %q", q.Text, want.Text)
+	}
+}
+
+func TestService_GetContext_QuoteID(t *testing.T) {
+	svc := testService
+
+	narration := svc.Browse("en", "narrator", 1, 0, 1, TruthAll)
+	if len(narration.Quotes) == 0 {
+		t.Fatal("expected narrator quotes in episode 1")
+	}
+	id := narration.Quotes[0].ID
+
+	result := svc.GetContext("en", id, 2)
+	if result == nil {
+		t.Fatalf("expected context for ID %q", id)
+	}
+	if result.Quote.ID != id {
+		t.Errorf("Quote.ID: got %q, want %q", result.Quote.ID, id)
+	}
+}
+
+func TestService_GetScenes(t *testing.T) {
+	svc := testService
+
+	all := svc.GetScenes("en", 0)
+	if len(all) == 0 {
+		t.Fatal("expected scenes")
+	}
+
+	ep1 := svc.GetScenes("en", 1)
+	if len(ep1) == 0 {
+		t.Fatal("expected scenes in episode 1")
+	}
+	for _, scene := range ep1 {
+		if scene.Episode != 1 {
+			t.Errorf("scene %q: episode %d, want 1", scene.ID, scene.Episode)
+		}
+	}
+	if len(ep1) >= len(all) {
+		t.Errorf("episode filter did not narrow scenes: %d of %d", len(ep1), len(all))
+	}
+}
+
+func TestService_GetScene(t *testing.T) {
+	svc := testService
+
+	scenes := svc.GetScenes("en", 1)
+	if len(scenes) == 0 {
+		t.Fatal("expected scenes in episode 1")
+	}
+
+	result := svc.GetScene("en", scenes[0].ID)
+	if result == nil {
+		t.Fatalf("expected scene %q", scenes[0].ID)
+	}
+	if len(result.Quotes) != result.QuoteCount {
+		t.Errorf("Quotes: got %d, want %d", len(result.Quotes), result.QuoteCount)
+	}
+	for _, q := range result.Quotes {
+		if q.SceneID != scenes[0].ID {
+			t.Errorf("quote %q: SceneID %q, want %q", q.ID, q.SceneID, scenes[0].ID)
+		}
+	}
+}
+
+func TestService_GetScene_NotFound(t *testing.T) {
+	svc := testService
+
+	if result := svc.GetScene("en", "no_such_scene"); result != nil {
+		t.Errorf("expected nil, got %+v", result)
+	}
+	if result := svc.GetScene("fr", "umi1_1"); result != nil {
+		t.Errorf("expected nil for unknown language, got %+v", result)
+	}
+}
+
+func TestService_Search_BGMFilter(t *testing.T) {
+	svc := testService
+
+	var track string
+	for _, q := range svc.Browse("en", "", 1000, 0, 0, TruthAll).Quotes {
+		if q.BGM != "" && q.CharacterID != "narrator" {
+			track = q.BGM
+			break
+		}
+	}
+	if track == "" {
+		t.Skip("script has no voiced lines with BGM")
+	}
+
+	resp := svc.Search("a", "en", 1000, 0, "", 0, TruthAll, track, nil, SearchModeExact)
+	if resp.Total == 0 {
+		t.Fatalf("expected results during track %q", track)
+	}
+	for _, r := range resp.Results {
+		if r.Quote.BGM != track {
+			t.Errorf("quote %q: BGM %q, want %q", r.Quote.ID, r.Quote.BGM, track)
+		}
+	}
+
+	unfiltered := svc.Search("a", "en", 1000, 0, "", 0, TruthAll, "", nil, SearchModeExact)
+	if unfiltered.Total <= resp.Total {
+		t.Errorf("bgm filter did not narrow results: %d of %d", resp.Total, unfiltered.Total)
+	}
+}
+
+func TestService_Search_PresentFilter(t *testing.T) {
+	svc := testService
+
+	var present string
+	for _, q := range svc.Browse("en", "", 1000, 0, 0, TruthAll).Quotes {
+		if len(q.Present) > 0 {
+			present = q.Present[0]
+			break
+		}
+	}
+	if present == "" {
+		t.Skip("script has no lines with sprites on screen")
+	}
+
+	resp := svc.Search("a", "en", 1000, 0, "", 0, TruthAll, "", []string{present}, SearchModeExact)
+	if resp.Total == 0 {
+		t.Fatalf("expected results with %q on screen", present)
+	}
+	for _, r := range resp.Results {
+		if !slices.Contains(r.Quote.Present, present) {
+			t.Errorf("quote %q: Present %v, want %q", r.Quote.ID, r.Quote.Present, present)
+		}
+	}
+}
+
+func TestService_Search_BGMTitle(t *testing.T) {
+	script := "new_episode 1\n" +
+		"bgm1 21\n" +
+		`d [lv 0*"10"*"10100001"]"Battler hears the grave."[\]` + "\n" +
+		"bgm1 2\n" +
+		`d [lv 0*"10"*"10100002"]"Battler hears hope."[\]` + "\n"
+	svc, err := newService(fstest.MapFS{
+		"english.txt":  {Data: []byte(script)},
+		"japanese.txt": {Data: []byte(script)},
+		bgmTitlesPath:  {Data: []byte("# track\ttitle\n21\tDread of the Grave\n")},
+	}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := svc.Search("Battler", "en", 1000, 0, "", 0, TruthAll, "Dread of the Grave", nil, SearchModeExact)
+	if resp.Total != 1 {
+		t.Fatalf("got %d results while Dread of the Grave plays, want 1", resp.Total)
+	}
+	if q := resp.Results[0].Quote; q.BGM != "21" || q.BGMTitle != "Dread of the Grave" {
+		t.Errorf("quote %q: BGM %q titled %q, want 21 titled Dread of the Grave", q.ID, q.BGM, q.BGMTitle)
+	}
+
+	if partial := svc.Search("Battler", "en", 1000, 0, "", 0, TruthAll, "grave", nil, SearchModeExact); partial.Total != 1 {
+		t.Errorf("part of the title: got %d results, want 1", partial.Total)
+	}
+	if untitled := svc.GetByAudioID("en", "10100002"); untitled == nil || untitled.BGM != "2" || untitled.BGMTitle != "" {
+		t.Errorf("a track bgm.txt does not name: got %+v", untitled)
+	}
+}
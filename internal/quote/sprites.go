@@ -0,0 +1,72 @@
+package quote
+
+import (
+	"slices"
+	"strings"
+)
+
+// spriteCharacters maps the prefix of a sprite name (the part before the
+// first underscore, e.g. "bea" in "bea_a11_warai1") to a character ID.
+// Sprites with other prefixes are not counted as anyone being present.
+var spriteCharacters = map[string]string{
+	"kin": "01",
+	"kla": "02",
+	"nat": "03",
+	"jes": "04",
+	"eva": "05",
+	"hid": "06",
+	"geo": "07",
+	"rud": "08",
+	"kir": "09",
+	"but": "10",
+	"ang": "11",
+	"ros": "12",
+	"mar": "13",
+	"gen": "14",
+	"sha": "15",
+	"kan": "16",
+	"goh": "17",
+	"kum": "18",
+	"nan": "19",
+	"ama": "20",
+	"oko": "21",
+	"kas": "22",
+	"bea": "27",
+	"ber": "28",
+	"lam": "29",
+	"vir": "30",
+	"ron": "31",
+	"gap": "32",
+	"sak": "33",
+	"eri": "46",
+	"dla": "47",
+	"ger": "48",
+	"cor": "49",
+	"fea": "50",
+	"wil": "54",
+	"cla": "55",
+}
+
+// presentCharacters returns the sorted, distinct character IDs shown by the
+// given sprites.
+func presentCharacters(sprites []string) []string {
+	var present []string
+	for _, sprite := range sprites {
+		prefix, _, _ := strings.Cut(sprite, "_")
+		if id, ok := spriteCharacters[strings.ToLower(prefix)]; ok && !slices.Contains(present, id) {
+			present = append(present, id)
+		}
+	}
+	slices.Sort(present)
+	return present
+}
+
+// matchesPresent reports whether every character in want was on screen.
+func matchesPresent(q ParsedQuote, want []string) bool {
+	for _, id := range want {
+		if !slices.Contains(q.Present, id) {
+			return false
+		}
+	}
+	return true
+}
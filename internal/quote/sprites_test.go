@@ -0,0 +1,54 @@
+package quote
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPresentCharacters(t *testing.T) {
+	got := presentCharacters([]string{"but_a11_def1", "bea_a11_warai1", "bea_b22_ikari1", "xyz_a11_def1"})
+	want := []string{"10", "27"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("presentCharacters: got %v, want %v", got, want)
+	}
+	if got := presentCharacters(nil); got != nil {
+		t.Errorf("presentCharacters(nil): got %v, want nil", got)
+	}
+}
+
+func TestMatchesPresent(t *testing.T) {
+	q := ParsedQuote{Present: []string{"10", "27"}}
+
+	if !matchesPresent(q, []string{"27"}) {
+		t.Error("expected Beatrice to be present")
+	}
+	if !matchesPresent(q, []string{"10", "27"}) {
+		t.Error("expected Battler and Beatrice to be present")
+	}
+	if matchesPresent(q, []string{"10", "04"}) {
+		t.Error("Jessica is not on screen")
+	}
+}
+
+func TestParseAll_Present(t *testing.T) {
+	lines := []string{
+		"new_episode 1",
+		"ld l,kin_a11_ikari1,22",
+		"ld c,nan_a11_def1,22",
+		`d [lv 0*"19"*"11900003"]` + "`\"Kinzo-san, your health is failing.\"`" + `[\]`,
+		"cl a,22",
+		"d `The room fell silent.`[\\]",
+	}
+
+	quotes := NewParser().ParseAll(lines)
+	if len(quotes) != 2 {
+		t.Fatalf("expected 2 quotes, got %d", len(quotes))
+	}
+	if !slices.Equal(quotes[0].Present, []string{"01", "19"}) {
+		t.Errorf("Present: got %v, want [01 19]", quotes[0].Present)
+	}
+	if len(quotes[1].Present) != 0 {
+		t.Errorf("Present after cl a: got %v, want none", quotes[1].Present)
+	}
+}
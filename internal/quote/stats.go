@@ -49,6 +49,7 @@ type (
 		LinesPerEpisode   []episodeCharacterLines `json:"linesPerEpisode"`
 		TruthPerEpisode   []episodeTruth          `json:"truthPerEpisode"`
 		Interactions      []interactionPair       `json:"interactions"`
+		CoPresence        []interactionPair       `json:"coPresence"`
 		CharacterPresence []characterPresence     `json:"characterPresence"`
 		CharacterNames    map[string]string       `json:"characterNames"`
 		EpisodeNames      map[int]string          `json:"episodeNames"`
@@ -64,6 +65,7 @@ type (
 		charEpCounts map[string]map[int]int
 		epTruth      map[int][2]int
 		interactions map[string]int
+		coPresence   map[string]int
 	}
 
 	rankedChar struct {
@@ -109,6 +111,7 @@ func (s *statsComputer) compute(episode int) *statsResult {
 	result := &statsResult{
 		TopSpeakers:    s.topSpeakers(ranked, 20),
 		Interactions:   s.topInteractions(t.interactions, 25),
+		CoPresence:     s.topInteractions(t.coPresence, 25),
 		CharacterNames: s.buildNameMap(t.charCounts),
 		EpisodeNames:   episodeNames,
 	}
@@ -128,6 +131,7 @@ func (s *statsComputer) tally(episode int) tallies {
 		charEpCounts: make(map[string]map[int]int),
 		epTruth:      make(map[int][2]int),
 		interactions: make(map[string]int),
+		coPresence:   make(map[string]int),
 	}
 
 	var prevCharID string
@@ -150,6 +154,13 @@ func (s *statsComputer) tally(episode int) tallies {
 			t.epTruth[q.Episode] = counts
 		}
 
+		// Every pair of characters on screen together counts once per line.
+		for i, a := range q.Present {
+			for _, b := range q.Present[i+1:] {
+				t.coPresence[fmt.Sprintf("%s|%s", a, b)]++
+			}
+		}
+
 		if q.CharacterID == "narrator" {
 			prevCharID = ""
 			continue
@@ -145,6 +145,32 @@ func TestStats_Interactions(t *testing.T) {
 	}
 }
 
+func TestStats_CoPresence(t *testing.T) {
+	quotes := []ParsedQuote{
+		{CharacterID: "10", Episode: 1, Present: []string{"10", "27"}},
+		{CharacterID: "narrator", Episode: 1, Present: []string{"04", "10", "27"}},
+		{CharacterID: "04", Episode: 1},
+		{CharacterID: "27", Episode: 2, Present: []string{"10", "27"}},
+	}
+	result := NewStats(quotes).Compute(AllEpisodes).(*statsResult)
+
+	counts := make(map[string]int)
+	for _, pair := range result.CoPresence {
+		counts[pair.CharA+"|"+pair.CharB] = pair.Count
+	}
+	if counts["10|27"] != 3 {
+		t.Errorf("Battler-Beatrice: got %d, want 3", counts["10|27"])
+	}
+	if counts["04|10"] != 1 || counts["04|27"] != 1 {
+		t.Errorf("Jessica pairs: got %d and %d, want 1 each", counts["04|10"], counts["04|27"])
+	}
+
+	ep2 := NewStats(quotes).Compute(2).(*statsResult)
+	if len(ep2.CoPresence) != 1 || ep2.CoPresence[0].Count != 1 {
+		t.Errorf("episode 2 co-presence: got %+v", ep2.CoPresence)
+	}
+}
+
 func TestStats_CharacterPresence(t *testing.T) {
 	quotes := buildTestQuotes()
 	s := NewStats(quotes)
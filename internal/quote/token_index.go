@@ -0,0 +1,205 @@
+package quote
+
+import (
+	"math"
+	"slices"
+	"unicode"
+)
+
+// BM25 tuning constants. These are the usual defaults for short documents.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type (
+	// TokenIndex is an inverted index from normalized word tokens to the
+	// quotes that contain them, used to rank search results.
+	TokenIndex interface {
+		// Tokenize splits text into the same tokens the index was built with.
+		Tokenize(text string) []string
+		// IndicesWithAll returns the indices of quotes containing every token, in script order.
+		IndicesWithAll(tokens []string) []int
+		// IndicesWithAny returns the indices of quotes containing at least one term, in script order.
+		IndicesWithAny(terms []string) []int
+		// Score returns the BM25 relevance of the quote at idx for the given tokens.
+		Score(idx int, tokens []string) float64
+		// DocFreq returns how many quotes contain the term.
+		DocFreq(term string) int
+		// SimilarTerms returns indexed terms within maxEdits of token, closest first.
+		SimilarTerms(token string, maxEdits int) []TermMatch
+	}
+
+	tokenIndex struct {
+		postings     map[string][]posting
+		trigramIndex map[string][]string
+		docLens      []int
+		avgDocLen    float64
+	}
+
+	posting struct {
+		doc  int
+		freq int
+	}
+)
+
+func newTokenIndex(lowerTexts []string) *tokenIndex {
+	ti := &tokenIndex{
+		postings:     make(map[string][]posting),
+		trigramIndex: make(map[string][]string),
+		docLens:      make([]int, len(lowerTexts)),
+	}
+
+	totalLen := 0
+	for doc, text := range lowerTexts {
+		tokens := ti.Tokenize(text)
+		ti.docLens[doc] = len(tokens)
+		totalLen += len(tokens)
+
+		freqs := make(map[string]int, len(tokens))
+		for _, tok := range tokens {
+			freqs[tok]++
+		}
+		for tok, freq := range freqs {
+			ti.postings[tok] = append(ti.postings[tok], posting{doc: doc, freq: freq})
+		}
+	}
+
+	for term := range ti.postings {
+		for _, g := range trigrams(term) {
+			ti.trigramIndex[g] = append(ti.trigramIndex[g], term)
+		}
+	}
+
+	if len(lowerTexts) > 0 {
+		ti.avgDocLen = float64(totalLen) / float64(len(lowerTexts))
+	}
+
+	return ti
+}
+
+// Tokenize normalizes text and splits it on anything that is not a letter or
+// digit. Apostrophes inside words are kept so "it's" stays a single token.
+// Runs of Japanese or Chinese characters, which have no spaces between
+// words, are split into overlapping character bigrams so partial words match.
+func (*tokenIndex) Tokenize(text string) []string {
+	runes := []rune(normalizeText(text))
+
+	var tokens []string
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = appendSegment(tokens, runes[start:end])
+			start = -1
+		}
+	}
+
+	for i, r := range runes {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if !inWord && (r == '\'' || r == '’') && start >= 0 && i+1 < len(runes) && unicode.IsLetter(runes[i+1]) {
+			inWord = true
+		}
+		if !inWord {
+			flush(i)
+			continue
+		}
+		if start >= 0 && isCJK(r) != isCJK(runes[start]) {
+			flush(i)
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	flush(len(runes))
+
+	return tokens
+}
+
+// appendSegment adds one word run to tokens, as bigrams if it is CJK.
+func appendSegment(tokens []string, segment []rune) []string {
+	if len(segment) < 2 || !isCJK(segment[0]) {
+		return append(tokens, string(segment))
+	}
+	for i := 0; i+2 <= len(segment); i++ {
+		tokens = append(tokens, string(segment[i:i+2]))
+	}
+	return tokens
+}
+
+func (ti *tokenIndex) IndicesWithAll(tokens []string) []int {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	lists := make([][]posting, 0, len(tokens))
+	for _, tok := range tokens {
+		list, ok := ti.postings[tok]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, list)
+	}
+
+	// Intersect starting from the rarest token to keep the working set small.
+	slices.SortFunc(lists, func(a, b []posting) int {
+		return len(a) - len(b)
+	})
+
+	result := make([]int, len(lists[0]))
+	for i, p := range lists[0] {
+		result[i] = p.doc
+	}
+	for _, list := range lists[1:] {
+		kept := result[:0]
+		for _, doc := range result {
+			if _, ok := findPosting(list, doc); ok {
+				kept = append(kept, doc)
+			}
+		}
+		result = kept
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+func (ti *tokenIndex) Score(idx int, tokens []string) float64 {
+	if idx < 0 || idx >= len(ti.docLens) || ti.avgDocLen == 0 {
+		return 0
+	}
+
+	n := float64(len(ti.docLens))
+	docLen := float64(ti.docLens[idx])
+	seen := make(map[string]bool, len(tokens))
+
+	var score float64
+	for _, tok := range tokens {
+		if seen[tok] {
+			continue
+		}
+		seen[tok] = true
+
+		list := ti.postings[tok]
+		p, ok := findPosting(list, idx)
+		if !ok {
+			continue
+		}
+		df := float64(len(list))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		tf := float64(p.freq)
+		score += idf * (tf * (bm25K1 + 1)) / (tf + bm25K1*(1-bm25B+bm25B*docLen/ti.avgDocLen))
+	}
+	return score
+}
+
+// findPosting binary searches a posting list, which is always sorted by doc.
+func findPosting(list []posting, doc int) (posting, bool) {
+	i, ok := slices.BinarySearchFunc(list, doc, func(p posting, target int) int {
+		return p.doc - target
+	})
+	if !ok {
+		return posting{}, false
+	}
+	return list[i], true
+}
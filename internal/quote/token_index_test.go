@@ -0,0 +1,112 @@
+package quote
+
+import "testing"
+
+func TestTokenIndex_Tokenize(t *testing.T) {
+	ti := newTokenIndex(nil)
+
+	got := ti.Tokenize(`"Without love, it CAN'T be seen..." -- Beatrice`)
+	want := []string{"without", "love", "it", "can't", "be", "seen", "beatrice"}
+
+	if len(got) != len(want) {
+		t.Fatalf("Tokenize: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTokenIndex_Tokenize_Empty(t *testing.T) {
+	ti := newTokenIndex(nil)
+
+	if got := ti.Tokenize("...!?"); len(got) != 0 {
+		t.Errorf("Tokenize punctuation: got %v, want empty", got)
+	}
+}
+
+func TestTokenIndex_Tokenize_JapaneseBigrams(t *testing.T) {
+	ti := newTokenIndex(nil)
+
+	got := ti.Tokenize("黄金の魔女、ベアトリーチェ！ Battler")
+	want := []string{"黄金", "金の", "の魔", "魔女", "べあ", "あと", "とり", "りち", "ちぇ", "battler"}
+
+	if len(got) != len(want) {
+		t.Fatalf("Tokenize: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTokenIndex_Tokenize_SingleCJKCharacter(t *testing.T) {
+	ti := newTokenIndex(nil)
+
+	got := ti.Tokenize("魔")
+	if len(got) != 1 || got[0] != "魔" {
+		t.Errorf("Tokenize: got %v, want [魔]", got)
+	}
+}
+
+func TestTokenIndex_IndicesWithAll(t *testing.T) {
+	ti := newTokenIndex([]string{
+		"the golden witch",
+		"the witch of miracles",
+		"golden land",
+		"a golden witch appears",
+	})
+
+	got := ti.IndicesWithAll([]string{"golden", "witch"})
+	if len(got) != 2 || got[0] != 0 || got[1] != 3 {
+		t.Errorf("IndicesWithAll(golden, witch): got %v, want [0 3]", got)
+	}
+
+	if got := ti.IndicesWithAll([]string{"golden", "missing"}); got != nil {
+		t.Errorf("IndicesWithAll with unknown token: got %v, want nil", got)
+	}
+	if got := ti.IndicesWithAll(nil); got != nil {
+		t.Errorf("IndicesWithAll(nil): got %v, want nil", got)
+	}
+}
+
+func TestTokenIndex_Score_RareTermsWeighMore(t *testing.T) {
+	ti := newTokenIndex([]string{
+		"the witch",
+		"the witch",
+		"the witch",
+		"the eagle",
+	})
+
+	common := ti.Score(0, []string{"the"})
+	rare := ti.Score(3, []string{"eagle"})
+	if rare <= common {
+		t.Errorf("rare term score %f should exceed common term score %f", rare, common)
+	}
+}
+
+func TestTokenIndex_Score_ShorterDocumentsWin(t *testing.T) {
+	ti := newTokenIndex([]string{
+		"witch",
+		"witch of the golden land who lives in the forest",
+	})
+
+	short := ti.Score(0, []string{"witch"})
+	long := ti.Score(1, []string{"witch"})
+	if short <= long {
+		t.Errorf("short doc score %f should exceed long doc score %f", short, long)
+	}
+}
+
+func TestTokenIndex_Score_NoMatch(t *testing.T) {
+	ti := newTokenIndex([]string{"the witch"})
+
+	if got := ti.Score(0, []string{"eagle"}); got != 0 {
+		t.Errorf("Score for absent token: got %f, want 0", got)
+	}
+	if got := ti.Score(5, []string{"witch"}); got != 0 {
+		t.Errorf("Score for out-of-range index: got %f, want 0", got)
+	}
+}
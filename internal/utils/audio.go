@@ -1,67 +1,245 @@
 package utils
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func ServeAudio(ctx *fiber.Ctx, data []byte) error {
-	ctx.Set("Content-Type", "audio/ogg")
+const (
+	audioCacheControl = "public, max-age=86400"
+	// maxRanges caps the ranges honoured in one request; larger requests
+	// get the whole file instead.
+	maxRanges = 16
+)
+
+// ServeAudioAs serves audio held in memory. Its ETag is derived from the
+// content.
+func ServeAudioAs(ctx *fiber.Ctx, data []byte, contentType string) error {
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	return serveContent(ctx, bytes.NewReader(data), int64(len(data)), contentType, etag, time.Time{}, nil)
+}
+
+// AudioSource is audio that can be read at any offset, such as a clip
+// opened from an audio store.
+type AudioSource interface {
+	io.ReaderAt
+	io.Closer
+	Size() int64
+	ModTime() time.Time
+}
+
+// ServeAudioSource streams src, which it closes once the response is sent.
+// Its ETag is derived from the source's size and modification time.
+func ServeAudioSource(ctx *fiber.Ctx, src AudioSource, contentType string) error {
+	etag := fmt.Sprintf(`"%x-%x"`, src.ModTime().UnixNano(), src.Size())
+	return serveContent(ctx, src, src.Size(), contentType, etag, src.ModTime(), src)
+}
+
+// ServeCachedAudio streams a file from the audio cache, which it closes once
+// the response is sent. The cache rewrites modification times as it tracks
+// use, so the ETag is derived from the cache key, which determines the
+// content, and no Last-Modified is sent.
+func ServeCachedAudio(ctx *fiber.Ctx, f *os.File, contentType string, key string) error {
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to read audio file",
+		})
+	}
+	sum := sha256.Sum256([]byte(key))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	return serveContent(ctx, f, info.Size(), contentType, etag, time.Time{}, f)
+}
+
+type byteRange struct {
+	start, end int64 // inclusive
+}
+
+func (r byteRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size)
+}
+
+// readCloser pairs a reader over part of a file with the file, so the
+// response closes the file once it has been streamed.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// serveContent answers a GET for content, honouring conditional and range
+// headers. closer, if set, is closed once the body has been sent.
+func serveContent(ctx *fiber.Ctx, content io.ReaderAt, size int64, contentType string, etag string, modTime time.Time, closer io.Closer) error {
+	done := func() {
+		if closer != nil {
+			closer.Close()
+		}
+	}
+
 	ctx.Set("Accept-Ranges", "bytes")
+	ctx.Set("Cache-Control", audioCacheControl)
+	ctx.Set("ETag", etag)
+	if !modTime.IsZero() {
+		ctx.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(ctx, etag, modTime) {
+		done()
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
 
-	total := len(data)
 	rangeHeader := ctx.Get("Range")
+	if rangeHeader != "" && !ifRangeMatches(ctx.Get("If-Range"), etag, modTime) {
+		rangeHeader = ""
+	}
 	if rangeHeader == "" {
-		return ctx.Send(data)
+		ctx.Set("Content-Type", contentType)
+		return ctx.SendStream(readCloser{io.NewSectionReader(content, 0, size), closerOrNop(closer)}, int(size))
 	}
 
-	if !strings.HasPrefix(rangeHeader, "bytes=") {
-		ctx.Set("Content-Range", fmt.Sprintf("bytes */%d", total))
+	ranges, ok := parseRanges(rangeHeader, size)
+	if !ok {
+		done()
+		ctx.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
 		return ctx.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
 	}
-
-	rangeParts := strings.SplitN(rangeHeader[6:], "-", 2)
-	if len(rangeParts) != 2 {
-		ctx.Set("Content-Range", fmt.Sprintf("bytes */%d", total))
-		return ctx.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+	if len(ranges) > maxRanges {
+		ctx.Set("Content-Type", contentType)
+		return ctx.SendStream(readCloser{io.NewSectionReader(content, 0, size), closerOrNop(closer)}, int(size))
 	}
 
-	var start, end int
-	var err error
+	ctx.Status(fiber.StatusPartialContent)
+	if len(ranges) == 1 {
+		r := ranges[0]
+		ctx.Set("Content-Type", contentType)
+		ctx.Set("Content-Range", r.contentRange(size))
+		return ctx.SendStream(readCloser{io.NewSectionReader(content, r.start, r.length()), closerOrNop(closer)}, int(r.length()))
+	}
 
-	if rangeParts[0] == "" {
-		suffixLen, parseErr := strconv.Atoi(rangeParts[1])
-		if parseErr != nil || suffixLen <= 0 {
-			ctx.Set("Content-Range", fmt.Sprintf("bytes */%d", total))
-			return ctx.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+	boundary := randomBoundary()
+	var parts []io.Reader
+	var length int64
+	for i, r := range ranges {
+		header := fmt.Sprintf("--%s\r\nContent-Type: %s\r\nContent-Range: %s\r\n\r\n", boundary, contentType, r.contentRange(size))
+		if i > 0 {
+			header = "\r\n" + header
 		}
-		start = total - suffixLen
-		if start < 0 {
-			start = 0
+		parts = append(parts, strings.NewReader(header), io.NewSectionReader(content, r.start, r.length()))
+		length += int64(len(header)) + r.length()
+	}
+	trailer := "\r\n--" + boundary + "--\r\n"
+	parts = append(parts, strings.NewReader(trailer))
+	length += int64(len(trailer))
+
+	ctx.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+	return ctx.SendStream(readCloser{io.MultiReader(parts...), closerOrNop(closer)}, int(length))
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func closerOrNop(c io.Closer) io.Closer {
+	if c == nil {
+		return nopCloser{}
+	}
+	return c
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since
+// only when no entity tag was sent.
+func notModified(ctx *fiber.Ctx, etag string, modTime time.Time) bool {
+	if match := ctx.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
 		}
-		end = total - 1
-	} else {
-		start, err = strconv.Atoi(rangeParts[0])
-		if err != nil || start < 0 || start >= total {
-			ctx.Set("Content-Range", fmt.Sprintf("bytes */%d", total))
-			return ctx.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+		return false
+	}
+	if since := ctx.Get("If-Modified-Since"); since != "" && !modTime.IsZero() {
+		t, err := http.ParseTime(since)
+		return err == nil && !modTime.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// ifRangeMatches reports whether a Range request may be served partially:
+// If-Range must be absent or name the current entity tag or modification
+// time exactly.
+func ifRangeMatches(ifRange string, etag string, modTime time.Time) bool {
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) {
+		return ifRange == etag
+	}
+	t, err := http.ParseTime(ifRange)
+	return err == nil && !modTime.IsZero() && modTime.Truncate(time.Second).Equal(t)
+}
+
+// parseRanges parses a bytes Range header against a body of size bytes.
+// Ranges past the end are dropped and ends are clamped; ok is false when
+// the header is malformed or nothing is left to satisfy.
+func parseRanges(header string, size int64) ([]byteRange, bool) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return nil, false
+	}
+	var ranges []byteRange
+	for _, spec := range strings.Split(header[6:], ",") {
+		spec = strings.TrimSpace(spec)
+		startStr, endStr, found := strings.Cut(spec, "-")
+		if !found {
+			return nil, false
 		}
-		if rangeParts[1] == "" {
-			end = total - 1
+
+		var r byteRange
+		if startStr == "" {
+			suffix, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || suffix <= 0 {
+				return nil, false
+			}
+			r = byteRange{max(size-suffix, 0), size - 1}
 		} else {
-			end, err = strconv.Atoi(rangeParts[1])
-			if err != nil || end < start || end >= total {
-				ctx.Set("Content-Range", fmt.Sprintf("bytes */%d", total))
-				return ctx.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || start < 0 {
+				return nil, false
+			}
+			end := size - 1
+			if endStr != "" {
+				end, err = strconv.ParseInt(endStr, 10, 64)
+				if err != nil || end < start {
+					return nil, false
+				}
 			}
+			r = byteRange{start, min(end, size-1)}
+		}
+		if r.start < size {
+			ranges = append(ranges, r)
 		}
 	}
+	return ranges, len(ranges) > 0
+}
 
-	ctx.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, total))
-	ctx.Set("Content-Length", strconv.Itoa(end-start+1))
-	ctx.Status(fiber.StatusPartialContent)
-	return ctx.Send(data[start : end+1])
+func randomBoundary() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
 }
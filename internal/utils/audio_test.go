@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	testAudio   = []byte("0123456789abcdefghij")
+	testModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+type testSource struct {
+	*bytes.Reader
+}
+
+func (testSource) Close() error       { return nil }
+func (testSource) ModTime() time.Time { return testModTime }
+func (s testSource) Size() int64      { return s.Reader.Size() }
+
+// serveTestAudio sends a request with the given headers to a handler that
+// serves testAudio through ServeAudioSource.
+func serveTestAudio(t *testing.T, header map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/audio", func(ctx *fiber.Ctx) error {
+		return ServeAudioSource(ctx, testSource{bytes.NewReader(testAudio)}, "audio/ogg")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/audio", nil)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp, body
+}
+
+func testETag(t *testing.T) string {
+	t.Helper()
+	resp, _ := serveTestAudio(t, nil)
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	return etag
+}
+
+func TestServeContent_Whole(t *testing.T) {
+	resp, body := serveTestAudio(t, nil)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, testAudio) {
+		t.Fatalf("got %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" || resp.Header.Get("Last-Modified") != testModTime.Format(http.TimeFormat) {
+		t.Errorf("unexpected headers %v", resp.Header)
+	}
+}
+
+func TestServeContent_Range(t *testing.T) {
+	for _, tc := range []struct {
+		header       string
+		body         string
+		contentRange string
+	}{
+		{"bytes=2-5", "2345", "bytes 2-5/20"},
+		{"bytes=15-", "fghij", "bytes 15-19/20"},
+		{"bytes=-3", "hij", "bytes 17-19/20"},
+		{"bytes=18-100", "ij", "bytes 18-19/20"},
+	} {
+		resp, body := serveTestAudio(t, map[string]string{"Range": tc.header})
+		if resp.StatusCode != http.StatusPartialContent {
+			t.Errorf("%s: got status %d", tc.header, resp.StatusCode)
+			continue
+		}
+		if string(body) != tc.body || resp.Header.Get("Content-Range") != tc.contentRange {
+			t.Errorf("%s: got %q with %q, want %q with %q", tc.header, body, resp.Header.Get("Content-Range"), tc.body, tc.contentRange)
+		}
+	}
+}
+
+func TestServeContent_MultipleRanges(t *testing.T) {
+	resp, body := serveTestAudio(t, map[string]string{"Range": "bytes=0-1, 10-12, -2"})
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("got status %d", resp.StatusCode)
+	}
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("got Content-Type %q", resp.Header.Get("Content-Type"))
+	}
+	if resp.ContentLength != int64(len(body)) {
+		t.Errorf("Content-Length %d, body is %d bytes", resp.ContentLength, len(body))
+	}
+
+	want := []struct{ body, contentRange string }{
+		{"01", "bytes 0-1/20"},
+		{"abc", "bytes 10-12/20"},
+		{"ij", "bytes 18-19/20"},
+	}
+	r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for i, w := range want {
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		data, _ := io.ReadAll(part)
+		if string(data) != w.body || part.Header.Get("Content-Range") != w.contentRange || part.Header.Get("Content-Type") != "audio/ogg" {
+			t.Errorf("part %d: got %q with %v", i, data, part.Header)
+		}
+	}
+	if _, err := r.NextPart(); err != io.EOF {
+		t.Errorf("expected %d parts, got more: %v", len(want), err)
+	}
+}
+
+func TestServeContent_Unsatisfiable(t *testing.T) {
+	for _, header := range []string{"bytes=20-", "bytes=30-40", "bytes=5-2", "items=0-1"} {
+		resp, _ := serveTestAudio(t, map[string]string{"Range": header})
+		if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+			t.Errorf("%s: got status %d, want 416", header, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Content-Range"); got != "bytes */20" {
+			t.Errorf("%s: got Content-Range %q", header, got)
+		}
+	}
+}
+
+func TestServeContent_IfRange(t *testing.T) {
+	etag := testETag(t)
+	for _, tc := range []struct {
+		ifRange string
+		status  int
+	}{
+		{etag, http.StatusPartialContent},
+		{testModTime.Format(http.TimeFormat), http.StatusPartialContent},
+		{`"stale"`, http.StatusOK},
+		{testModTime.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+	} {
+		resp, body := serveTestAudio(t, map[string]string{"Range": "bytes=0-3", "If-Range": tc.ifRange})
+		if resp.StatusCode != tc.status {
+			t.Errorf("If-Range %s: got status %d, want %d", tc.ifRange, resp.StatusCode, tc.status)
+		}
+		if tc.status == http.StatusOK && !bytes.Equal(body, testAudio) {
+			t.Errorf("If-Range %s: expected the whole body, got %q", tc.ifRange, body)
+		}
+	}
+}
+
+func TestServeContent_NotModified(t *testing.T) {
+	etag := testETag(t)
+	for _, tc := range []struct {
+		header map[string]string
+		status int
+	}{
+		{map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": testModTime.Format(http.TimeFormat)}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": testModTime.Add(-time.Second).Format(http.TimeFormat)}, http.StatusOK},
+		// An entity tag that does not match wins over a matching date.
+		{map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": testModTime.Format(http.TimeFormat)}, http.StatusOK},
+	} {
+		resp, body := serveTestAudio(t, tc.header)
+		if resp.StatusCode != tc.status {
+			t.Errorf("%v: got status %d, want %d", tc.header, resp.StatusCode, tc.status)
+		}
+		if tc.status == http.StatusNotModified && len(body) != 0 {
+			t.Errorf("%v: expected no body, got %q", tc.header, body)
+		}
+	}
+}
+
+func TestServeCachedAudio_ETagFromKey(t *testing.T) {
+	path := t.TempDir() + "/cached.wav"
+	if err := os.WriteFile(path, testAudio, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	etags := make(map[string]string)
+	for _, key := range []string{"clip:10/1@1.1:wav", "clip:10/1@2.2:wav"} {
+		app := fiber.New()
+		app.Get("/audio", func(ctx *fiber.Ctx) error {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			return ServeCachedAudio(ctx, f, "audio/wav", key)
+		})
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/audio", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.Get("Last-Modified") != "" {
+			t.Errorf("cached audio should not send Last-Modified")
+		}
+		etags[key] = resp.Header.Get("ETag")
+	}
+	if etags["clip:10/1@1.1:wav"] == etags["clip:10/1@2.2:wav"] {
+		t.Errorf("different keys gave the same ETag %s", etags["clip:10/1@1.1:wav"])
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"time"
 	"umineko_quote/internal/audio"
 	"umineko_quote/internal/controllers"
 	"umineko_quote/internal/og"
@@ -19,6 +23,82 @@ import (
 //go:embed static/*
 var staticFiles embed.FS
 
+// audioCacheBytes caps the disk space used by transcoded audio.
+const audioCacheBytes = 512 << 20
+
+// defaultAudioDir is where setup_audio.sh unpacks the voice archive.
+const defaultAudioDir = "internal/quote/data/audio"
+
+// newAudioStore opens the store picked by openAudioStore, keeping the
+// metadata of its clips in the manifest at VOICE_META or its default.
+func newAudioStore() (audio.AudioStore, error) {
+	store, err := openAudioStore()
+	if err != nil {
+		return nil, err
+	}
+	manifest := os.Getenv("VOICE_META")
+	if manifest == "" {
+		manifest = filepath.Join(os.TempDir(), "umineko_quote", "audio_meta.json")
+	}
+	return audio.WithMetaManifest(store, manifest), nil
+}
+
+// openAudioStore picks where voice clips are read from: an S3-compatible
+// bucket when VOICE_S3_BUCKET is set, the archive at VOICE_ZIP when that is
+// set, and otherwise the unpacked directory at VOICE_DIR or its default.
+func openAudioStore() (audio.AudioStore, error) {
+	if bucket := os.Getenv("VOICE_S3_BUCKET"); bucket != "" {
+		return audio.NewS3Store(audio.S3Config{
+			Endpoint:  os.Getenv("VOICE_S3_ENDPOINT"),
+			Bucket:    bucket,
+			Region:    os.Getenv("VOICE_S3_REGION"),
+			Prefix:    os.Getenv("VOICE_S3_PREFIX"),
+			AccessKey: os.Getenv("VOICE_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("VOICE_S3_SECRET_KEY"),
+		})
+	}
+	if archive := os.Getenv("VOICE_ZIP"); archive != "" {
+		return audio.NewZipStore(archive)
+	}
+	dir := os.Getenv("VOICE_DIR")
+	if dir == "" {
+		dir = defaultAudioDir
+	}
+	return audio.NewDirStore(dir), nil
+}
+
+// defaultIndexSnapshot is where go generate writes the index snapshot for
+// the embedded scripts.
+const defaultIndexSnapshot = "internal/quote/data/index.snapshot"
+
+// scriptWatchInterval is how often SCRIPT_DIR is checked for changes.
+const scriptWatchInterval = 2 * time.Second
+
+// newQuoteService loads the scripts from SCRIPT_DIR when it is set, watching
+// it for changes, and otherwise the scripts embedded in the binary. Either
+// way the index is read from the snapshot at INDEX_SNAPSHOT when it matches
+// the scripts; by default that is index.snapshot in SCRIPT_DIR, or
+// defaultIndexSnapshot.
+func newQuoteService(audioStore audio.AudioStore) (quote.ReloadableService, error) {
+	dir := os.Getenv("SCRIPT_DIR")
+	snapshot := os.Getenv("INDEX_SNAPSHOT")
+	if dir == "" {
+		if snapshot == "" {
+			snapshot = defaultIndexSnapshot
+		}
+		return quote.NewService(audioStore, snapshot), nil
+	}
+	if snapshot == "" {
+		snapshot = filepath.Join(dir, "index.snapshot")
+	}
+	quoteService, err := quote.NewServiceFromDir(dir, audioStore, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	go quoteService.Watch(context.Background(), scriptWatchInterval)
+	return quoteService, nil
+}
+
 func main() {
 	app := fiber.New()
 
@@ -27,14 +107,25 @@ func main() {
 		TimeFormat: "2006-01-02 15:04:05",
 	}))
 
-	quoteService := quote.NewService()
+	audioStore, err := newAudioStore()
+	if err != nil {
+		log.Fatalf("failed to open audio store: %v", err)
+	}
+	quoteService, err := newQuoteService(audioStore)
+	if err != nil {
+		log.Fatalf("failed to load scripts: %v", err)
+	}
 	ogGen := og.NewImageGenerator()
 	audioCombiner, err := audio.NewCombiner()
 	if err != nil {
 		log.Fatalf("failed to initialize audio combiner: %v", err)
 	}
+	audioCache, err := audio.NewDiskCache(filepath.Join(os.TempDir(), "umineko_quote", "audio"), audioCacheBytes)
+	if err != nil {
+		log.Fatalf("failed to initialize audio cache: %v", err)
+	}
 	htmlBytes, _ := staticFiles.ReadFile("static/index.html")
-	service := controllers.NewService(quoteService, ogGen, audioCombiner, string(htmlBytes))
+	service := controllers.NewService(quoteService, ogGen, audioCombiner, audioCache, string(htmlBytes), os.Getenv("ADMIN_TOKEN"))
 	routes.PublicRoutes(service, app)
 
 	app.Use("/", filesystem.New(filesystem.Config{